@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Pin every thread of the process to the CPUs, such as those of one NUMA
+// node. Threads started later inherit it from the thread starting them, so
+// threads are pinned until no new one turns up.
+func setAffinity(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	pinned := make(map[int]bool)
+	for {
+		tasks, err := os.ReadDir("/proc/self/task")
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, task := range tasks {
+			tid, err := strconv.Atoi(task.Name())
+			if err != nil || pinned[tid] {
+				continue
+			}
+			// Threads may end in between
+			if err := unix.SchedSetaffinity(tid, &set); err != nil && !errors.Is(err, unix.ESRCH) {
+				return err
+			}
+			pinned[tid] = true
+			found = true
+		}
+		if !found {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Pinning to CPUs is only implemented for Linux
+func setAffinity(cpus []int) error {
+	return errors.New("only supported on Linux")
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// An aggregation reduces a group of samples to one or more rows of an
+// aggregate chart
+type aggregation struct {
+	name string
+	// Number of rows it fills for groups of the given size
+	rows func(size int) int
+	// Compute the rows of the group
+	apply func(data []float64) []float64
+	// Whether its rows count lost samples rather than show latencies
+	loss bool
+}
+
+// The aggregations used unless configured otherwise, which show a set of
+// order statistics followed by the number of lost samples
+var defaultAggregations = []string{"order_statistics", "loss"}
+
+// Names of the aggregations that can be selected, besides p<percentile>
+var aggregationNames = []string{"order_statistics", "min", "max", "mean", "median", "trimmed_mean", "stddev", "loss"}
+
+func single(compute func(data []float64) float64) aggregation {
+	return aggregation{
+		rows:  func(int) int { return 1 },
+		apply: func(data []float64) []float64 { return []float64{compute(data)} },
+	}
+}
+
+// Parse the name of an aggregation
+func parseAggregation(name string) (aggregation, error) {
+	var a aggregation
+	switch name {
+	case "order_statistics":
+		a = aggregation{rows: orderStatisticCount, apply: orderStatistics}
+	case "min":
+		a = single(func(data []float64) float64 { return percentile(replies(data), 0) })
+	case "max":
+		a = single(func(data []float64) float64 { return percentile(replies(data), 100) })
+	case "mean":
+		a = single(func(data []float64) float64 { return summarize(data).mean })
+	case "median":
+		a = single(func(data []float64) float64 { return percentile(replies(data), 50) })
+	case "trimmed_mean":
+		a = single(trimmedMean)
+	case "stddev":
+		a = single(standardDeviation)
+	case "loss":
+		a = single(func(data []float64) float64 { return float64(summarize(data).lost) })
+		a.loss = true
+	default:
+		p, err := strconv.ParseFloat(strings.TrimPrefix(name, "p"), 64)
+		if !strings.HasPrefix(name, "p") || err != nil || p < 0 || p > 100 {
+			return a, fmt.Errorf("unknown aggregation %q, expected one of %s or p<percentile>", name, strings.Join(aggregationNames, ", "))
+		}
+		a = single(func(data []float64) float64 { return percentile(replies(data), p) })
+	}
+	a.name = name
+	return a, nil
+}
+
+func parseAggregations(names []string) ([]aggregation, error) {
+	aggregations := make([]aggregation, len(names))
+	for i, name := range names {
+		var err error
+		if aggregations[i], err = parseAggregation(strings.TrimSpace(name)); err != nil {
+			return nil, err
+		}
+	}
+	return aggregations, nil
+}
+
+// Number of rows the aggregations fill for groups of the given size
+func aggregationRows(aggregations []aggregation, size int) int {
+	rows := 0
+	for _, a := range aggregations {
+		rows += a.rows(size)
+	}
+	return rows
+}
+
+// Compute the rows of every aggregation of the group
+func aggregate(aggregations []aggregation, data []float64) []float64 {
+	var result []float64
+	for _, a := range aggregations {
+		result = append(result, a.apply(data)...)
+	}
+	return result
+}
+
+// Name the rows of the aggregations, numbering those of aggregations with
+// several rows
+func aggregationRowNames(aggregations []aggregation, size int) []string {
+	var names []string
+	for _, a := range aggregations {
+		rows := a.rows(size)
+		for i := range rows {
+			if rows == 1 {
+				names = append(names, a.name)
+			} else {
+				names = append(names, fmt.Sprintf("%s %d", a.name, i))
+			}
+		}
+	}
+	return names
+}
+
+// Label the rows of the aggregations briefly, naming order statistics by the
+// percentile they are closest to
+func aggregationRowLabels(aggregations []aggregation, size int) []string {
+	var labels []string
+	for _, a := range aggregations {
+		switch a.name {
+		case "order_statistics":
+			samples := math.Log2(float64(size))
+			for i := range a.rows(size) {
+				index := math.Round(float64(i) / ((samples - 1) / (float64(size) - 1)))
+				labels = append(labels, percentileLabel(index/float64(size-1)*100))
+			}
+		case "trimmed_mean":
+			labels = append(labels, "trim")
+		default:
+			labels = append(labels, a.name)
+		}
+	}
+	return labels
+}
+
+func percentileLabel(p float64) string {
+	switch {
+	case p <= 0:
+		return "min"
+	case p >= 100:
+		return "max"
+	}
+	return fmt.Sprintf("p%.0f", p)
+}
+
+var aggregateLabelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#8a8a8a"))
+
+// Width of the label column of the aggregate charts, fitting the longest
+// label of any of them
+func (m *model) aggregateLabelWidth() int {
+	width := 0
+	for _, size := range m.aggregateCounts {
+		for _, label := range aggregationRowLabels(m.aggregations, size) {
+			width = max(width, len(label)+1)
+		}
+	}
+	return width
+}
+
+// Tell which of the rows of the aggregations count lost samples
+func lossRows(aggregations []aggregation, size int) []bool {
+	var loss []bool
+	for _, a := range aggregations {
+		for range a.rows(size) {
+			loss = append(loss, a.loss)
+		}
+	}
+	return loss
+}
+
+// Number of order statistics for groups of the given size, which grows with
+// its log2
+func orderStatisticCount(size int) int {
+	return int(math.Log2(float64(size)))
+}
+
+// Compute evenly spaced order statistics of the group, where lost samples
+// sort last
+func orderStatistics(data []float64) []float64 {
+	innerData := make([]float64, len(data))
+	copy(innerData, data)
+	lost := 0
+	sort.Float64s(innerData)
+	for _, v := range innerData {
+		if math.IsNaN(v) {
+			lost++
+		}
+	}
+	innerData = append(innerData[lost:], innerData[:lost]...)
+	result := make([]float64, 0)
+	samples := math.Log2(float64(len(innerData)))
+	for i := 0; i < int(samples); i++ {
+		index := (int(math.Round(float64(i) / ((samples - 1) / (float64(len(innerData)) - 1)))))
+		result = append(result, innerData[index])
+	}
+	return result
+}
+
+// The mean of the replies without the fastest and slowest tenth of them
+func trimmedMean(data []float64) float64 {
+	sorted := replies(data)
+	trim := len(sorted) / 10
+	sorted = sorted[trim : len(sorted)-trim]
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, latency := range sorted {
+		sum += latency
+	}
+	return sum / float64(len(sorted))
+}
+
+// The standard deviation of the replies, which is how spread out they are
+func standardDeviation(data []float64) float64 {
+	stats := summarize(data)
+	if stats.count == stats.lost {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, latency := range replies(data) {
+		sum += (latency - stats.mean) * (latency - stats.mean)
+	}
+	return math.Sqrt(sum / float64(stats.count-stats.lost))
+}
+
+// Sizes of the groups of the aggregate charts, each group the size of the
+// previous one times the group size
+func geometricGroups(groupSize, aggregates int) []int {
+	counts := make([]int, aggregates)
+	size := groupSize
+	for i := range counts {
+		counts[i] = size
+		size *= groupSize
+	}
+	return counts
+}
+
+// Largest group of samples, as many as the history of a stream holds at the
+// width of headless mode, which it holds at any width
+const maxGroupSize = 80 * 65536
+
+// Check that groups of the size can be aggregated: order statistics are
+// spread over at least 3 samples, and a group fits in the history
+func checkGroupSize(size int, aggregations []aggregation) error {
+	if size > maxGroupSize {
+		return fmt.Errorf("groups of %d samples are more than the %d samples kept", size, maxGroupSize)
+	}
+	for _, a := range aggregations {
+		if a.name == "order_statistics" && size < 3 {
+			return fmt.Errorf("order statistics need groups of at least 3 samples, not %d", size)
+		}
+	}
+	return nil
+}
+
+// Parse a comma separated list of group sizes, each a number of samples or
+// a span of time such as 1m, which is turned into a number of samples at the
+// interval
+func parseGroups(list string, interval time.Duration, aggregations []aggregation) (counts []int, names []string, err error) {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if count, err := strconv.Atoi(name); err == nil {
+			if count < 1 {
+				return nil, nil, fmt.Errorf("-groups %s must be at least 1 sample", name)
+			}
+			if err := checkGroupSize(count, aggregations); err != nil {
+				return nil, nil, fmt.Errorf("-groups %s: %w", name, err)
+			}
+			counts = append(counts, count)
+			names = append(names, name)
+			continue
+		}
+		span, err := time.ParseDuration(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("-groups expects numbers of samples or spans of time such as 10,1m,1h, not %q", name)
+		}
+		if span < interval {
+			return nil, nil, fmt.Errorf("-groups %s is shorter than the time between pings, %v", name, interval)
+		}
+		count := int((span + interval/2) / interval)
+		if err := checkGroupSize(count, aggregations); err != nil {
+			return nil, nil, fmt.Errorf("-groups %s: %w", name, err)
+		}
+		counts = append(counts, count)
+		names = append(names, name)
+	}
+	return counts, names, nil
+}
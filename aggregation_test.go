@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAggregations(t *testing.T) {
+	aggregations, err := parseAggregations([]string{"order_statistics", " median", "p0", "trimmed_mean", "loss"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 8
+	if rows := aggregationRows(aggregations, size); rows != 7 {
+		t.Errorf("the aggregations fill %d rows, want 7", rows)
+	}
+	names := []string{"order_statistics 0", "order_statistics 1", "order_statistics 2", "median", "p0", "trimmed_mean", "loss"}
+	if got := aggregationRowNames(aggregations, size); !slices.Equal(got, names) {
+		t.Errorf("rows are named %q, want %q", got, names)
+	}
+	labels := []string{"min", "p57", "max", "median", "p0", "trim", "loss"}
+	if got := aggregationRowLabels(aggregations, size); !slices.Equal(got, labels) {
+		t.Errorf("rows are labeled %q, want %q", got, labels)
+	}
+	if got := lossRows(aggregations, size); !slices.Equal(got, []bool{false, false, false, false, false, false, true}) {
+		t.Errorf("loss rows are %v, want only the last", got)
+	}
+
+	data := []float64{7, 3, math.NaN(), 1, 5, 2, 6, 4}
+	rows := aggregate(aggregations, data)
+	want := []float64{1, 5, math.NaN(), 4, 1, 4, 1}
+	if len(rows) != len(want) {
+		t.Fatalf("aggregated %v into %d rows, want %d", data, len(rows), len(want))
+	}
+	for i := range want {
+		if rows[i] != want[i] && !(math.IsNaN(rows[i]) && math.IsNaN(want[i])) {
+			t.Errorf("row %s is %v, want %v", names[i], rows[i], want[i])
+		}
+	}
+}
+
+func TestParseAggregationErrors(t *testing.T) {
+	for _, name := range []string{"average", "p", "p101", "p-1", "90"} {
+		if _, err := parseAggregation(name); err == nil {
+			t.Errorf("aggregation %q was accepted", name)
+		}
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	aggregations, err := parseAggregations(defaultAggregations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, names, err := parseGroups("10, 1m,90s", 5*time.Second, aggregations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(counts, []int{10, 12, 18}) || !slices.Equal(names, []string{"10", "1m", "90s"}) {
+		t.Errorf("groups are %v named %q, want [10 12 18] named [10 1m 90s]", counts, names)
+	}
+
+	for _, list := range []string{"0", "2", "1s", "soon", "1000000000"} {
+		if _, _, err := parseGroups(list, 5*time.Second, aggregations); err == nil {
+			t.Errorf("-groups %s was accepted", list)
+		}
+	}
+	median, _ := parseAggregations([]string{"median"})
+	if _, _, err := parseGroups("2", 5*time.Second, median); err != nil {
+		t.Errorf("groups of 2 were rejected without order statistics: %v", err)
+	}
+}
+
+func TestGeometricGroups(t *testing.T) {
+	if got := geometricGroups(4, 3); !slices.Equal(got, []int{4, 16, 64}) {
+		t.Errorf("geometricGroups(4, 3) = %v, want [4 16 64]", got)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// A request seen in a capture, waiting for its reply
+type pendingProbe struct {
+	target string
+	ip     string
+	sent   time.Time
+	// The reply of a TCP handshake acknowledges this sequence number
+	ack uint32
+}
+
+func runAnalyze(args []string) {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	output := flags.String("o", "", "Session to write the samples to, to play them back in the charts with -replay")
+	timeout := flags.Duration("timeout", 2*time.Second, "Time after which a request without a reply counts as lost")
+	timeFormatName := flags.String("time-format", "local", "How to show timestamps: iso8601, local or unix")
+	timezone := flags.String("timezone", "", "IANA time zone of timestamps, defaults to the local one")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback analyze [-o <session>] [-timeout <duration>] [-time-format=<format>] [-timezone=<zone>] <capture>")
+		fmt.Fprintln(flags.Output(), "Pairs the ICMP echo requests and replies, and the TCP handshakes, of a pcap or pcapng capture, and summarizes them by target")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	timeFormat, err := parseTimeFormat(*timeFormatName, *timezone)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	packets, err := readCapture(file)
+	file.Close()
+	if err != nil {
+		fmt.Printf("%s: %v\n", flags.Arg(0), err)
+		os.Exit(1)
+	}
+	records := pairProbes(packets, *timeout)
+	if len(records) == 0 {
+		fmt.Printf("%s holds no ICMP echo requests or TCP handshakes\n", flags.Arg(0))
+		os.Exit(1)
+	}
+	printCaptureSummary(records, timeFormat)
+	if *output != "" {
+		if err := writeSession(*output, records); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d samples to %s, play them back with pingback -replay=%s\n", len(records), *output, *output)
+	}
+}
+
+// Pair the requests of a capture with their replies into samples of the
+// targets they were sent to. ICMP echo requests are paired with the reply of
+// the same identifier and sequence number, and TCP SYNs with the SYN-ACK
+// acknowledging them, where a reset is a refused connection. Requests whose
+// reply came after the timeout, or never, are lost, except those sent
+// within the timeout of the end of the capture, whose reply may have come
+// after it ended.
+func pairProbes(packets []capturedPacket, timeout time.Duration) []record {
+	sort.SliceStable(packets, func(i, j int) bool { return packets[i].at.Before(packets[j].at) })
+	pending := make(map[string]*pendingProbe)
+	var records []record
+	for _, packet := range packets {
+		ip, ok := parseIP(packet.ip)
+		if !ok {
+			continue
+		}
+		payload := ip.payload
+		switch {
+		case (ip.protocol == 1 || ip.protocol == 58) && len(payload) >= 8:
+			kind := payload[0]
+			request, reply := kind == 8 || kind == 128, kind == 0 || kind == 129
+			if !request && !reply {
+				continue
+			}
+			id, seq := binary.BigEndian.Uint16(payload[4:]), binary.BigEndian.Uint16(payload[6:])
+			if request {
+				key := fmt.Sprintf("icmp %s %s %d %d", ip.src, ip.dst, id, seq)
+				pending[key] = &pendingProbe{target: ip.dst.String(), ip: ip.dst.String(), sent: packet.at}
+				continue
+			}
+			key := fmt.Sprintf("icmp %s %s %d %d", ip.dst, ip.src, id, seq)
+			if probe, ok := pending[key]; ok {
+				delete(pending, key)
+				records = append(records, capturedSample(probe, packet.at, ip.ttl, "", timeout))
+			}
+		case ip.protocol == 6 && len(payload) >= 14:
+			srcPort, dstPort := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+			seq, ack := binary.BigEndian.Uint32(payload[4:]), binary.BigEndian.Uint32(payload[8:])
+			syn, acked, reset := payload[13]&0x02 != 0, payload[13]&0x10 != 0, payload[13]&0x04 != 0
+			if syn && !acked {
+				key := tcpKey(ip.src, srcPort, ip.dst, dstPort)
+				if _, ok := pending[key]; !ok {
+					// Retransmitted SYNs are part of the same handshake
+					target := "tcp://" + net.JoinHostPort(ip.dst.String(), strconv.Itoa(int(dstPort)))
+					pending[key] = &pendingProbe{target: target, ip: ip.dst.String(), sent: packet.at, ack: seq + 1}
+				}
+				continue
+			}
+			if !acked || (!syn && !reset) {
+				continue
+			}
+			key := tcpKey(ip.dst, dstPort, ip.src, srcPort)
+			if probe, ok := pending[key]; ok && ack == probe.ack {
+				delete(pending, key)
+				errClass := ""
+				if reset {
+					errClass = "refused"
+				}
+				records = append(records, capturedSample(probe, packet.at, ip.ttl, errClass, timeout))
+			}
+		}
+	}
+	var end time.Time
+	if len(packets) > 0 {
+		end = packets[len(packets)-1].at
+	}
+	for _, probe := range pending {
+		if end.Sub(probe.sent) < timeout {
+			continue
+		}
+		records = append(records, sampleRecord(probe.target, probe.sent, math.NaN(), sampleMeta{ip: probe.ip, errClass: "timeout"}))
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records
+}
+
+func tcpKey(src netip.Addr, srcPort uint16, dst netip.Addr, dstPort uint16) string {
+	return fmt.Sprintf("tcp %s %s", netip.AddrPortFrom(src, srcPort), netip.AddrPortFrom(dst, dstPort))
+}
+
+func capturedSample(probe *pendingProbe, received time.Time, ttl int, errClass string, timeout time.Duration) record {
+	latency := received.Sub(probe.sent)
+	switch {
+	case errClass != "":
+		return sampleRecord(probe.target, probe.sent, math.NaN(), sampleMeta{ip: probe.ip, errClass: errClass})
+	case latency > timeout:
+		return sampleRecord(probe.target, probe.sent, math.NaN(), sampleMeta{ip: probe.ip, errClass: "timeout"})
+	}
+	return sampleRecord(probe.target, probe.sent, latency.Seconds()*1000, sampleMeta{ip: probe.ip, ttl: ttl})
+}
+
+// Print the statistics of every target of the capture, in the order they
+// were first probed
+func printCaptureSummary(records []record, timeFormat timeFormat) {
+	var targets []string
+	for _, rec := range records {
+		if !slices.Contains(targets, rec.Target) {
+			targets = append(targets, rec.Target)
+		}
+	}
+	samples := samplesByTarget(records)
+	fmt.Printf("Captured from %s for %v:\n", timeFormat.format(records[0].Time),
+		records[len(records)-1].Time.Sub(records[0].Time).Round(time.Second))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  target\tsent\tloss %\tmin\tmedian\tp95\tmax\tjitter ms")
+	for _, target := range targets {
+		stats := summarize(samples[target])
+		fmt.Fprintf(tw, "  %s\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n", target, stats.count, stats.lossPercent(),
+			stats.min, stats.median, stats.p95, stats.max, stats.jitter)
+	}
+	tw.Flush()
+}
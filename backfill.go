@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// Longer logs are downsampled to this many samples
+	maxBackfill = 65536
+	// Number of samples processed between renders of the progress
+	backfillChunk = 4096
+)
+
+var (
+	pingHeaderPattern = regexp.MustCompile(`^PING (\S+)`)
+	pingReplyPattern  = regexp.MustCompile(`^\[(\d+\.\d+)\] .*icmp_seq=(\d+).* time=([\d.]+) ms`)
+	pingMissPattern   = regexp.MustCompile(`^\[(\d+\.\d+)\] no answer yet for icmp_seq=(\d+)`)
+)
+
+type backfill struct {
+	path       string
+	latencies  []float64
+	timestamps []time.Time
+	done       int
+	factor     int
+}
+
+type (
+	backfillParsedMsg struct {
+		target     *target
+		latencies  []float64
+		timestamps []time.Time
+		err        error
+	}
+	backfillStepMsg struct{ target *target }
+)
+
+// Read the address a log of the system ping command was recorded for
+func backfillAddress(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := pingHeaderPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s is not a log of the ping command", path)
+}
+
+func parseUnixTime(s string) time.Time {
+	seconds, _ := strconv.ParseFloat(s, 64)
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9))
+}
+
+// Parse a log of `ping -D`, where skipped sequence numbers and unanswered
+// pings count as lost packets
+func parsePingLog(path string) ([]float64, []time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var latencies []float64
+	var timestamps []time.Time
+	lastSeq := -1
+	record := func(seq int, at time.Time, latency float64) {
+		if seq <= lastSeq {
+			return
+		}
+		if lastSeq >= 0 && len(timestamps) > 0 {
+			previous := timestamps[len(timestamps)-1]
+			step := at.Sub(previous) / time.Duration(seq-lastSeq)
+			for missing := 1; missing < seq-lastSeq; missing++ {
+				latencies = append(latencies, math.NaN())
+				timestamps = append(timestamps, previous.Add(step*time.Duration(missing)))
+			}
+		}
+		latencies = append(latencies, latency)
+		timestamps = append(timestamps, at)
+		lastSeq = seq
+	}
+
+	scanner := bufio.NewScanner(file)
+	timestamped := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := pingReplyPattern.FindStringSubmatch(line); match != nil {
+			seq, _ := strconv.Atoi(match[2])
+			latency, _ := strconv.ParseFloat(match[3], 64)
+			record(seq, parseUnixTime(match[1]), latency)
+			timestamped = true
+		} else if match := pingMissPattern.FindStringSubmatch(line); match != nil {
+			seq, _ := strconv.Atoi(match[2])
+			record(seq, parseUnixTime(match[1]), math.NaN())
+		} else if strings.Contains(line, "icmp_seq=") && !strings.HasPrefix(line, "[") {
+			return nil, nil, fmt.Errorf("%s has no timestamps, record it with ping -D", path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if !timestamped {
+		return nil, nil, fmt.Errorf("%s has no replies", path)
+	}
+	return latencies, timestamps, nil
+}
+
+func parseBackfillCmd(t *target) tea.Cmd {
+	path := t.backfill.path
+	return func() tea.Msg {
+		latencies, timestamps, err := parsePingLog(path)
+		return backfillParsedMsg{t, latencies, timestamps, err}
+	}
+}
+
+func backfillStepCmd(t *target) tea.Cmd {
+	return func() tea.Msg {
+		return backfillStepMsg{t}
+	}
+}
+
+// Downsample the parsed log so it fits the history, keeping the worst sample
+// of each group so outages survive
+func (m *model) startBackfill(msg backfillParsedMsg) tea.Cmd {
+	b := msg.target.backfill
+	b.factor = (len(msg.latencies) + maxBackfill - 1) / maxBackfill
+	for i := 0; i < len(msg.latencies); i += b.factor {
+		b.latencies = append(b.latencies, combine(msg.latencies[i:min(i+b.factor, len(msg.latencies))], worstColumn))
+		b.timestamps = append(b.timestamps, msg.timestamps[i])
+	}
+	return backfillStepCmd(msg.target)
+}
+
+// Feed the next chunk of the backfill into the target, and start pinging it
+// once the backfill is done
+func (m *model) stepBackfill(t *target) tea.Cmd {
+	b := t.backfill
+	end := min(b.done+backfillChunk, len(b.latencies))
+	for i := b.done; i < end; i++ {
+		if !math.IsNaN(b.latencies[i]) {
+			m.initialized = true
+		}
+		m.processLatency(t, b.latencies[i], b.timestamps[i])
+	}
+	b.done = end
+	if b.done < len(b.latencies) {
+		return backfillStepCmd(t)
+	}
+	t.backfill = nil
+	m.resumeSchedule(t)
+	return m.schedulePing(t)
+}
+
+// Move the target to its first slot that hasn't passed yet, by whole
+// intervals so it keeps its offset from the other targets
+func (m *model) resumeSchedule(t *target) {
+	interval := m.currentInterval()
+	if late := m.clock.Now().Sub(t.nextPing); late > 0 {
+		t.nextPing = t.nextPing.Add((late + interval - 1).Truncate(interval))
+	}
+}
+
+func (m *model) backfillProgress() string {
+	var parts []string
+	for _, t := range m.targets {
+		if b := t.backfill; b != nil && len(b.latencies) > 0 {
+			part := fmt.Sprintf("%s from %s %d%%", t.address, b.path, 100*b.done/len(b.latencies))
+			if b.factor > 1 {
+				part += fmt.Sprintf(" (every %d samples combined)", b.factor)
+			}
+			parts = append(parts, part)
+		} else if b != nil {
+			parts = append(parts, fmt.Sprintf("%s from %s", t.address, b.path))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Backfilling " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	bannerStyle         = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#d23105"))
+	resolvedBannerStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#4a4a4a"))
+)
+
+// A banner shows an alert in the pane of its target until acknowledged,
+// staying after the alert resolves so it isn't missed
+type banner struct {
+	text  string
+	value string
+	start time.Time
+	end   time.Time
+	// Acknowledged banners are hidden until the alert resolves, and then
+	// removed
+	acknowledged bool
+}
+
+// Raise a banner for the rule on the target, or update the breached value of
+// the one already raised
+func (m *model) raiseBanner(t *target, rule, text, value string, start time.Time) {
+	if b, ok := t.banners[rule]; ok && b.end.IsZero() {
+		b.value = value
+		return
+	}
+	if t.banners == nil {
+		t.banners = make(map[string]*banner)
+	}
+	t.banners[rule] = &banner{text: text, value: value, start: start}
+}
+
+func (m *model) resolveBanner(t *target, rule string, now time.Time) {
+	if b, ok := t.banners[rule]; ok && b.end.IsZero() {
+		b.end = now
+		if b.acknowledged {
+			delete(t.banners, rule)
+		}
+	}
+}
+
+func (m *model) acknowledgeBanners(t *target) {
+	for rule, b := range t.banners {
+		b.acknowledged = true
+		if !b.end.IsZero() {
+			delete(t.banners, rule)
+		}
+	}
+}
+
+func (m *model) renderBanners(t *target, now time.Time) []string {
+	rules := make([]string, 0, len(t.banners))
+	for rule, b := range t.banners {
+		if !b.acknowledged {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return t.banners[rules[i]].start.Before(t.banners[rules[j]].start)
+	})
+	hint := ""
+	if key := m.keys.keyFor("acknowledge"); key != "" && t == m.targets[m.focus] {
+		hint = fmt.Sprintf("  (%s to acknowledge)", key)
+	}
+	var lines []string
+	for _, rule := range rules {
+		b := t.banners[rule]
+		if b.end.IsZero() {
+			lines = append(lines, bannerStyle.Render(fmt.Sprintf(" ⚠ %s for %v  %s%s ",
+				b.text, now.Sub(b.start).Round(time.Second), b.value, hint)))
+		} else {
+			lines = append(lines, resolvedBannerStyle.Render(fmt.Sprintf(" ✓ %s for %v  %s, resolved %v ago%s ",
+				b.text, b.end.Sub(b.start).Round(time.Second), b.value, now.Sub(b.end).Round(time.Second), hint)))
+		}
+	}
+	return lines
+}
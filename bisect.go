@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Samples this soon after switching configurations are discarded, as the
+// switch itself tends to lose packets
+const bisectSettle = 10 * time.Second
+
+// A configuration to compare, switched to by running its command
+type bisectConfig struct {
+	name    string
+	command string
+	samples []float64
+}
+
+// A bisection alternates between two configurations, such as Wi-Fi and
+// Ethernet, to find out which one is worse
+type bisection struct {
+	configs    [2]*bisectConfig
+	period     time.Duration
+	active     int
+	switching  bool
+	switchedAt time.Time
+	rounds     int
+	err        error
+}
+
+type (
+	bisectSwitchedMsg struct {
+		config int
+		err    error
+	}
+	bisectDueMsg struct{}
+)
+
+// Parse configurations given as <name>=<command>
+func parseBisection(values []string, period time.Duration) (*bisection, error) {
+	if len(values) != 2 {
+		return nil, fmt.Errorf("-bisect must be given twice, once for each configuration to compare")
+	}
+	b := &bisection{period: period}
+	for i, value := range values {
+		name, command, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("-bisect %q is not of the form <name>=<command>", value)
+		}
+		b.configs[i] = &bisectConfig{name: name, command: command}
+	}
+	return b, nil
+}
+
+// Run the command of the configuration to switch to it
+func (b *bisection) switchCmd(ctx context.Context, config int) tea.Cmd {
+	b.switching = true
+	command := b.configs[config].command
+	return func() tea.Msg {
+		output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+		if err != nil {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+		}
+		return bisectSwitchedMsg{config, err}
+	}
+}
+
+func (m *model) switchedBisection(msg bisectSwitchedMsg, now time.Time) tea.Cmd {
+	b := m.bisection
+	b.switching = false
+	if msg.err != nil {
+		b.err = msg.err
+		return nil
+	}
+	b.active = msg.config
+	b.switchedAt = now
+	if msg.config == 0 {
+		b.rounds++
+	}
+	m.addEvent(bisectEvent, "", b.configs[msg.config].name, now)
+	return m.clock.Tick(b.period, func(time.Time) tea.Msg { return bisectDueMsg{} })
+}
+
+// Attribute a sample to the active configuration, unless it was sent while
+// switching or settling
+func (m *model) trackBisection(latency float64, sent time.Time) {
+	b := m.bisection
+	if b == nil || b.switching || b.err != nil || b.switchedAt.IsZero() || sent.Sub(b.switchedAt) < bisectSettle {
+		return
+	}
+	config := b.configs[b.active]
+	config.samples = append(config.samples, latency)
+}
+
+func (m *model) renderBisection() string {
+	b := m.bisection
+	a, c := b.configs[0], b.configs[1]
+	state := "now " + b.configs[b.active].name
+	if b.switching {
+		state = "switching"
+	}
+	lines := []string{fmt.Sprintf("Bisecting %s vs %s every %v, %s, round %d:", a.name, c.name, b.period, state, b.rounds)}
+	if b.err != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, lines[0], "  Switching failed: "+b.err.Error())
+	}
+	for _, config := range b.configs {
+		stats := summarize(config.samples)
+		unit := m.latencyFormat.unitOf(stats.p95)
+		lines = append(lines, fmt.Sprintf("  %-10s samples %d  loss %.1f%%  median %s  p95 %s %s",
+			config.name, stats.count, stats.lossPercent(),
+			m.latencyFormat.number(stats.median, unit, 1), m.latencyFormat.number(stats.p95, unit, 1), unit))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, append(lines, "  "+b.verdict(m.noiseFloor))...)
+}
+
+// Tell which configuration is worse, if the difference is significant and
+// the medians are at least the noise floor apart
+func (b *bisection) verdict(noiseFloor float64) string {
+	a, c := b.configs[0], b.configs[1]
+	sa, sc := summarize(a.samples), summarize(c.samples)
+	latencyP := mannWhitney(replies(a.samples), replies(c.samples))
+	lossP := proportionTest(sa.lost, sa.count, sc.lost, sc.count)
+	if math.IsNaN(latencyP) || math.IsNaN(lossP) {
+		return "Too few samples to compare yet"
+	}
+	var reasons []string
+	worse := ""
+	if lossP < significanceLevel && sa.lossPercent() != sc.lossPercent() {
+		worse = a.name
+		if sc.lossPercent() > sa.lossPercent() {
+			worse = c.name
+		}
+		reasons = append(reasons, fmt.Sprintf("loses more packets (p=%.3g)", lossP))
+	}
+	if latencyP < significanceLevel && math.Abs(sa.median-sc.median) >= noiseFloor {
+		slower := a.name
+		if sc.median > sa.median {
+			slower = c.name
+		}
+		if worse != "" && worse != slower {
+			return fmt.Sprintf("%s loses more packets, but %s is slower (p=%.3g, p=%.3g)", worse, slower, lossP, latencyP)
+		}
+		worse = slower
+		reasons = append(reasons, fmt.Sprintf("is slower (p=%.3g)", latencyP))
+	}
+	if worse == "" {
+		return fmt.Sprintf("No significant difference yet (latency p=%.3g, loss p=%.3g)", latencyP, lossP)
+	}
+	return worse + " is worse: it " + strings.Join(reasons, " and ")
+}
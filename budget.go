@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Within budget is green, turning yellow as the budget is approached, and
+// over budget is red
+var budgetGradient = []lipgloss.Color{"#31f199", "#edd03a", "#d23105"}
+
+// Parse a latency budget of a stage, such as dns=20
+func parseBudget(value string, budgets map[string]float64) error {
+	stage, limit, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("budget %q is not of the form <stage>=<milliseconds>", value)
+	}
+	if !slices.Contains(httpStages, stage) {
+		return fmt.Errorf("budget %q is for an unknown stage, expected one of %s", value, strings.Join(httpStages, ", "))
+	}
+	milliseconds, err := strconv.ParseFloat(limit, 64)
+	if err != nil || milliseconds <= 0 {
+		return fmt.Errorf("budget %q must be a positive number of milliseconds", value)
+	}
+	budgets[stage] = milliseconds
+	return nil
+}
+
+// Append the stage timings of a sample, keeping as many as there are samples.
+// Lost samples have no stages, and stages that didn't happen took no time.
+func (m *model) appendStages(t *target, stages []float64) {
+	lost := stages == nil
+	for i := range httpStages {
+		stage := math.NaN()
+		if !lost {
+			stage = stages[i]
+			if math.IsNaN(stage) {
+				stage = 0
+			}
+		}
+		t.stageData[i] = append(t.stageData[i], stage)
+		t.stageData[i] = t.stageData[i][max(0, len(t.stageData[i])-len(t.latencyData)):]
+	}
+}
+
+// Track the stages of the target that are over budget, alerting once a stage
+// has been over budget for as many samples as make an outage, and again once
+// it is back within budget
+func (m *model) trackBudgets(t *target, stages []float64, now time.Time) tea.Cmd {
+	var cmds []tea.Cmd
+	for i, stage := range httpStages {
+		budget, ok := m.budgets[stage]
+		if !ok || stages == nil {
+			continue
+		}
+		// Stages that didn't happen took no time
+		if stages[i] <= budget || math.IsNaN(stages[i]) {
+			t.budgetStreaks[i] = 0
+			if t.overBudget[i] {
+				t.overBudget[i] = false
+				m.resolveBanner(t, "budget "+stage, now)
+				m.addEvent(budgetEvent, t.address, fmt.Sprintf("%s %s ok", t.label, stage), now)
+				cmds = append(cmds, m.budgetAlertCmd("within_budget", t, stage, stages[i]))
+			}
+			continue
+		}
+		t.budgetStreaks[i]++
+		if t.budgetStreaks[i] >= m.outageThreshold {
+			m.raiseBanner(t, "budget "+stage, strings.ToUpper(stage)+" over budget",
+				fmt.Sprintf("%s, budget %g ms", m.latencyFormat.format(stages[i]), budget), now)
+		}
+		if t.budgetStreaks[i] == m.outageThreshold {
+			t.overBudget[i] = true
+			m.addEvent(budgetEvent, t.address, fmt.Sprintf("%s %s over", t.label, stage), now)
+			cmds = append(cmds, m.budgetAlertCmd("over_budget", t, stage, stages[i]))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m *model) budgetAlertCmd(event string, t *target, stage string, latency float64) tea.Cmd {
+	return m.runAlert(append(os.Environ(),
+		"PINGBACK_EVENT="+event,
+		"PINGBACK_TARGETS="+t.address,
+		"PINGBACK_STAGE="+stage,
+		fmt.Sprintf("PINGBACK_BUDGET=%g", m.budgets[stage]),
+		fmt.Sprintf("PINGBACK_LATENCY=%.1f", latency),
+	))
+}
+
+func (m *model) renderStages(t *target) string {
+	var rows []string
+	for i, stage := range httpStages {
+		data := m.displayedColumns(t.stageData[i], 1, t.counter-len(t.stageData[i]))
+		budget, ok := m.budgets[stage]
+		if !ok {
+			rows = append(rows, strings.ToUpper(stage)+":", m.renderStream(data, m.streamScale(t.stream)))
+			continue
+		}
+		title := fmt.Sprintf("%s (budget %g ms):", strings.ToUpper(stage), budget)
+		if t.overBudget[i] {
+			title += " over budget"
+		}
+		cells := make([]cell, len(data))
+		for j, latency := range data {
+			if math.IsNaN(latency) {
+				cells[j] = lostCell
+				continue
+			}
+			if latency == 0 {
+				cells[j] = cell{" ", "", false}
+				continue
+			}
+			cells[j] = gradientCell(budgetGradient, 1)
+			if latency <= budget {
+				cells[j] = gradientCell(budgetGradient[:2], latency/budget)
+			}
+		}
+		rows = append(rows, title, renderRow(cells))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+// Number of crash reports to bundle, the most recent ones
+const bundledCrashes = 5
+
+func runDebug(args []string) {
+	if len(args) > 0 && args[0] == "bundle" {
+		runDebugBundle(args[1:])
+		return
+	}
+	fmt.Println("Usage: pingback debug bundle [-session <session>] [-o <zip>]")
+	os.Exit(1)
+}
+
+func runDebugBundle(args []string) {
+	flags := flag.NewFlagSet("debug bundle", flag.ExitOnError)
+	output := flags.String("o", "pingback-bundle.zip", "Zip file to write the bundle to")
+	configPath := flags.String("config", defaultConfigPath(), "Config file to include")
+	sessionPath := flags.String("session", "", "Recorded session to include the most recent samples of")
+	samples := flags.Int("samples", 1000, "Number of the most recent records of the session to include")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback debug bundle [-session <session>] [-o <zip>]")
+		fmt.Fprintln(flags.Output(), "Bundles the config, recent samples, crash reports, the version and the terminal, to attach to bug reports.")
+		fmt.Fprintln(flags.Output(), "Pingback keeps no log, so the crash reports are the closest to one it includes.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	if *samples < 0 {
+		fmt.Println("-samples can't be negative")
+		os.Exit(1)
+	}
+
+	files, err := collectBundle(*configPath, *sessionPath, *samples)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := writeZip(*output, files); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("Wrote %s with %s\n", *output, strings.Join(names, ", "))
+	fmt.Println("Check that it holds nothing you'd rather not share before attaching it to a bug report")
+}
+
+// Collect the files of the bundle by their names in it, with the given
+// number of the most recent records of the session, if any
+func collectBundle(configPath, sessionPath string, samples int) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	var version bytes.Buffer
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version.WriteString(info.String())
+	}
+	fmt.Fprintf(&version, "\nruntime %s %s/%s, %d CPUs\n", runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+	files["version.txt"] = version.Bytes()
+	files["terminal.txt"] = []byte(describeTerminal())
+
+	if text, err := os.ReadFile(configPath); err == nil {
+		files["config.toml"] = text
+		var problems bytes.Buffer
+		if cfg, err := loadConfig(configPath, true); err != nil {
+			fmt.Fprintln(&problems, err)
+		} else {
+			for _, err := range cfg.validate() {
+				fmt.Fprintln(&problems, err)
+			}
+		}
+		files["config-problems.txt"] = problems.Bytes()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if sessionPath != "" {
+		records, err := readSession(sessionPath)
+		if err != nil {
+			return nil, err
+		}
+		var recent bytes.Buffer
+		for _, rec := range records[max(0, len(records)-samples):] {
+			line, err := json.Marshal(rec)
+			if err != nil {
+				return nil, err
+			}
+			recent.Write(append(line, '\n'))
+		}
+		files["samples.jsonl"] = recent.Bytes()
+	}
+
+	crashes, _ := filepath.Glob(filepath.Join(os.TempDir(), "pingback-crash-*", "crash.txt"))
+	sort.Slice(crashes, func(i, j int) bool {
+		return modTime(crashes[i]) > modTime(crashes[j])
+	})
+	for _, path := range crashes[:min(len(crashes), bundledCrashes)] {
+		if text, err := os.ReadFile(path); err == nil {
+			files["crashes/"+filepath.Base(filepath.Dir(path))+".txt"] = text
+		}
+	}
+	return files, nil
+}
+
+func modTime(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
+
+// Describe what the terminal can do, which decides how the charts are drawn
+func describeTerminal() string {
+	var b strings.Builder
+	for _, name := range []string{"TERM", "COLORTERM", "TERM_PROGRAM", "TMUX", "LANG", "LC_ALL", "NO_COLOR"} {
+		fmt.Fprintf(&b, "%s=%s\n", name, os.Getenv(name))
+	}
+	if term.IsTerminal(os.Stdout.Fd()) {
+		width, height, err := term.GetSize(os.Stdout.Fd())
+		if err != nil {
+			fmt.Fprintf(&b, "stdout is a terminal of unknown size: %v\n", err)
+		} else {
+			fmt.Fprintf(&b, "stdout is a terminal of %dx%d\n", width, height)
+		}
+	} else {
+		fmt.Fprintln(&b, "stdout is not a terminal")
+	}
+	profiles := map[termenv.Profile]string{
+		termenv.TrueColor: "true color",
+		termenv.ANSI256:   "256 colors",
+		termenv.ANSI:      "16 colors",
+		termenv.Ascii:     "no colors",
+	}
+	fmt.Fprintf(&b, "color profile: %s\n", profiles[lipgloss.ColorProfile()])
+	return b.String()
+}
+
+func writeZip(path string, files map[string][]byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var w io.Writer
+		if w, err = archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()}); err != nil {
+			break
+		}
+		if _, err = w.Write(files[name]); err != nil {
+			break
+		}
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
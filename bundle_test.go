@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(configPath, []byte("addresses = [\"{site}.example.com\"]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sessionPath := filepath.Join(dir, "session.jsonl")
+	var records []record
+	for i := range 5 {
+		records = append(records, record{Time: testEpoch.Add(time.Duration(i) * time.Second), Target: "example.com", Lost: true})
+	}
+	if err := writeSession(sessionPath, records); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := collectBundle(configPath, sessionPath, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(files["samples.jsonl"]), "\n"); lines != 2 {
+		t.Errorf("bundled %d records, want the last 2", lines)
+	}
+	if !strings.Contains(string(files["samples.jsonl"]), `"2024-01-01T12:00:04Z"`) {
+		t.Errorf("the newest record isn't bundled:\n%s", files["samples.jsonl"])
+	}
+	if !strings.Contains(string(files["config-problems.txt"]), "{site}") {
+		t.Errorf("the undefined variable of the config isn't reported: %q", files["config-problems.txt"])
+	}
+
+	zipPath := filepath.Join(dir, "bundle.zip")
+	if err := writeZip(zipPath, files); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		if strings.HasPrefix(f.Name, "crashes/") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var content bytes.Buffer
+		content.ReadFrom(r)
+		r.Close()
+		if !bytes.Equal(content.Bytes(), files[f.Name]) {
+			t.Errorf("%s holds other content than was bundled", f.Name)
+		}
+	}
+	for _, want := range []string{"config.toml", "config-problems.txt", "samples.jsonl", "terminal.txt", "version.txt"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("the bundle holds %q, missing %s", names, want)
+		}
+	}
+}
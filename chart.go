@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Glyphs that fill a cell of the chart from its bottom up, in steps. Braille
+// has four rows of dots to a cell and blocks eight eighths.
+var chartStyles = map[string][]string{
+	"braille": {" ", "⣀", "⣤", "⣶", "⣿"},
+	"blocks":  {" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"},
+}
+
+func parseChartStyle(name string) (string, error) {
+	if _, ok := chartStyles[name]; !ok {
+		styles := make([]string, 0, len(chartStyles))
+		for style := range chartStyles {
+			styles = append(styles, style)
+		}
+		slices.Sort(styles)
+		return "", fmt.Errorf("unknown chart style %q, expected one of %s", name, strings.Join(styles, ", "))
+	}
+	return name, nil
+}
+
+// Plot the latencies as bars whose height tells the latency, on the same
+// scale as the colors, for those who read height better than hue. Each
+// column is a sample, as in the color stream, so the other rows still line
+// up with it.
+func (m *model) renderChart(data []float64, sc scale) string {
+	glyphs := chartStyles[m.chartStyle]
+	steps := len(glyphs) - 1
+	rows := make([][]cell, m.chartHeight)
+	for y := range rows {
+		rows[y] = make([]cell, len(data))
+	}
+	for x, latency := range data {
+		if math.IsNaN(latency) || latency == 0 {
+			for y := range rows {
+				rows[y][x] = cell{" ", "", false}
+			}
+			// Phases of HTTP probes that didn't happen took no time
+			if latency != 0 {
+				rows[len(rows)-1][x] = lostCell
+			}
+			continue
+		}
+		// The lowest latencies are a step high, so they don't look lost
+		ratio := math.Max(0, math.Min(1, m.latencyRatio(latency, sc)))
+		height := 1 + int(math.Round(ratio*float64(m.chartHeight*steps-1)))
+		color := m.latencyToCell(latency, sc).color
+		for y := range rows {
+			filled := max(0, min(steps, height-(m.chartHeight-1-y)*steps))
+			rows[y][x] = cell{glyphs[filled], color, false}
+		}
+	}
+	lines := make([]string, len(rows))
+	for y, row := range rows {
+		lines[y] = renderRow(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *model) chartTitle(sc scale) string {
+	if sc.min == sc.max {
+		return "Chart:"
+	}
+	return fmt.Sprintf("Chart (%s to %s, logarithmic):", m.latencyFormat.format(sc.min), m.latencyFormat.format(sc.max))
+}
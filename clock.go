@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// The time the scheduler runs on and samples are aggregated by. Probes still
+// time round trips on the wall clock, since that's what they measure.
+type clock interface {
+	Now() time.Time
+	// Like tea.Tick, but waiting out the duration on this clock
+	Tick(d time.Duration, fn func(time.Time) tea.Msg) tea.Cmd
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+func (wallClock) Tick(d time.Duration, fn func(time.Time) tea.Msg) tea.Cmd {
+	return tea.Tick(d, fn)
+}
+
+// A clock that only moves when it's told to, so schedules play out the same
+// way every time, and a replay can move it through a recording as fast as
+// the samples can be processed
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiting []manualTick
+}
+
+type manualTick struct {
+	at   time.Time
+	done chan time.Time
+}
+
+func newManualClock(start time.Time) *manualClock {
+	return &manualClock{now: start}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// The command blocks until the clock is advanced past the end of the tick
+func (c *manualClock) Tick(d time.Duration, fn func(time.Time) tea.Msg) tea.Cmd {
+	c.mu.Lock()
+	tick := manualTick{c.now.Add(d), make(chan time.Time, 1)}
+	if d <= 0 {
+		tick.done <- c.now
+	} else {
+		c.waiting = append(c.waiting, tick)
+	}
+	c.mu.Unlock()
+	return func() tea.Msg {
+		return fn(<-tick.done)
+	}
+}
+
+// Move the clock forward, firing the ticks that end by the new time
+func (c *manualClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Move the clock to a time, which is ignored if it's in the past
+func (c *manualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.now) {
+		return
+	}
+	c.now = now
+	waiting := c.waiting[:0]
+	for _, tick := range c.waiting {
+		if tick.at.After(now) {
+			waiting = append(waiting, tick)
+		} else {
+			tick.done <- tick.at
+		}
+	}
+	c.waiting = waiting
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var testEpoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// A model of the addresses on a manual clock, aggregating groups of the
+// sizes with the default aggregations
+func newTestModel(t *testing.T, addresses []string, interval time.Duration, groups []int) (*model, *manualClock) {
+	t.Helper()
+	aggregations, err := parseAggregations(defaultAggregations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(addresses, nil, nil, nil, nil, interval, groups, 0, 0, "", false, timeFormat{}, 1, 0, 0, nil, 0, false, aggregations)
+	clock := newManualClock(testEpoch)
+	m.clock = clock
+	return &m, clock
+}
+
+// Run the command in the background, as bubbletea does
+func runCmd(cmd tea.Cmd) <-chan tea.Msg {
+	msgs := make(chan tea.Msg, 1)
+	go func() {
+		msgs <- cmd()
+	}()
+	return msgs
+}
+
+// Fail unless the command is still waiting on the clock
+func expectPending(t *testing.T, msgs <-chan tea.Msg) {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		t.Fatalf("got %#v before the clock reached it", msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func expectMsg(t *testing.T, msgs <-chan tea.Msg) tea.Msg {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("the clock was advanced, but the command kept waiting")
+		return nil
+	}
+}
+
+func TestManualClockTick(t *testing.T) {
+	clock := newManualClock(testEpoch)
+	msgs := runCmd(clock.Tick(time.Second, func(at time.Time) tea.Msg { return at }))
+	clock.Advance(999 * time.Millisecond)
+	expectPending(t, msgs)
+	clock.Advance(5 * time.Millisecond)
+	if at := expectMsg(t, msgs); at != testEpoch.Add(time.Second) {
+		t.Errorf("tick fired at %v, want the end of the tick, %v", at, testEpoch.Add(time.Second))
+	}
+	clock.Set(testEpoch)
+	if now := clock.Now(); now != testEpoch.Add(1004*time.Millisecond) {
+		t.Errorf("setting the clock back moved it to %v", now)
+	}
+}
+
+func TestScheduleStaggersTargets(t *testing.T) {
+	addresses := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	m, clock := newTestModel(t, addresses, time.Second, []int{4})
+	m.staggerTargets(clock.Now())
+	for i, target := range m.targets {
+		if want := time.Duration(i) * 250 * time.Millisecond; target.offset != want {
+			t.Errorf("%s is offset by %v, want %v", target.address, target.offset, want)
+		}
+	}
+
+	msgs := runCmd(m.schedulePing(m.targets[2]))
+	clock.Advance(499 * time.Millisecond)
+	expectPending(t, msgs)
+	clock.Advance(time.Millisecond)
+	msg, ok := expectMsg(t, msgs).(pingDueMsg)
+	if !ok || msg.target != m.targets[2] {
+		t.Fatalf("got %#v, want the ping of %s to be due", msg, m.targets[2].address)
+	}
+}
+
+func TestAdvanceScheduleSkipsMissedSlots(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	m.staggerTargets(clock.Now())
+	target := m.targets[0]
+
+	clock.Advance(10 * time.Millisecond)
+	m.advanceSchedule(target, clock.Now(), clock.Now())
+	if want := testEpoch.Add(time.Second); target.nextPing != want {
+		t.Errorf("next ping at %v, want %v", target.nextPing, want)
+	}
+	if target.skew != 10*time.Millisecond {
+		t.Errorf("the ping was %v late, want 10ms", target.skew)
+	}
+
+	// A probe that held up the target for several intervals is followed by
+	// the slot in progress rather than a burst of the missed ones
+	clock.Advance(3500 * time.Millisecond)
+	m.advanceSchedule(target, clock.Now(), clock.Now())
+	if want := testEpoch.Add(3 * time.Second); target.nextPing != want {
+		t.Errorf("next ping at %v, want %v", target.nextPing, want)
+	}
+}
+
+func TestBackfillKeepsStaggerOffset(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1", "10.0.0.2"}, time.Second, []int{4})
+	m.staggerTargets(clock.Now())
+	target := m.targets[1]
+
+	// The log took a while to parse and feed in
+	clock.Advance(2300 * time.Millisecond)
+	m.resumeSchedule(target)
+	if want := testEpoch.Add(2500 * time.Millisecond); target.nextPing != want {
+		t.Errorf("next ping at %v, want %v, keeping the offset of %v", target.nextPing, want, target.offset)
+	}
+}
+
+func TestAggregatesOnManualClock(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	m.staggerTargets(clock.Now())
+	target := m.targets[0]
+	var sent []time.Time
+	for _, latency := range []float64{3, 1, 4, 2, 8, 5, 7, 6} {
+		sent = append(sent, clock.Now())
+		m.update(latencyMsg{target, latency, clock.Now(), sampleMeta{}})
+		clock.Advance(time.Second)
+	}
+
+	if !slices.Equal(target.timestamps, sent) {
+		t.Errorf("samples are timestamped %v, want the times of the clock, %v", target.timestamps, sent)
+	}
+	// Groups of 4 get two order statistics, the fastest and slowest reply,
+	// followed by the number lost
+	want := [][]float64{{1, 5}, {4, 8}, {0, 0}}
+	for i, row := range target.aggregateData[0] {
+		if !slices.Equal(row, want[i]) {
+			t.Errorf("aggregate row %d is %v, want %v", i, row, want[i])
+		}
+	}
+}
+
+func TestPeriodicChecksOnManualClock(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	msgs := runCmd(m.checkPowerCmd(powerCheckInterval))
+	clock.Advance(powerCheckInterval - time.Second)
+	expectPending(t, msgs)
+	clock.Advance(time.Second)
+	if msg, ok := expectMsg(t, msgs).(powerMsg); !ok {
+		t.Errorf("got %#v, want the power to be checked", msg)
+	}
+}
+
+func TestRetentionKeepsNewestSamples(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	m.staggerTargets(clock.Now())
+	s := m.targets[0].stream
+	for i := range 40 {
+		m.update(latencyMsg{m.targets[0], float64(i + 1), clock.Now(), sampleMeta{}})
+		clock.Advance(time.Second)
+	}
+
+	// A history within its slack is left as it is
+	if trimmed := trimHistory(s.timestamps, 38); len(trimmed) != 40 {
+		t.Errorf("trimmed %d samples within the slack, want all 40 kept", 40-len(trimmed))
+	}
+	const limit = 16
+	s.latencyData = trimHistory(s.latencyData, limit)
+	s.timestamps = trimHistory(s.timestamps, limit)
+	if len(s.latencyData) != limit || len(s.timestamps) != limit {
+		t.Fatalf("kept %d samples and %d times, want %d", len(s.latencyData), len(s.timestamps), limit)
+	}
+	// The samples kept are the newest, still paired with the times they were
+	// sent, and numbered as before
+	for n := s.counter - limit; n < s.counter; n++ {
+		if want := testEpoch.Add(time.Duration(n) * time.Second); s.sampleTime(n) != want || s.sample(n) != float64(n+1) {
+			t.Errorf("sample %d is %v at %v, want %v at %v", n, s.sample(n), s.sampleTime(n), float64(n+1), want)
+		}
+	}
+	if times := m.displayedTimes(s); times[len(times)-1] != testEpoch.Add(39*time.Second) {
+		t.Errorf("the newest column is at %v, want the last sample's time", times[len(times)-1])
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// Defaults of colorblind mode, a palette that runs from dark blue to yellow
+// and can be read without telling red from green
+var colorblindDefaults = [][2]string{
+	{"palette", "viridis"},
+}
+
+// Colors of the Okabe-Ito palette, which stay apart with any kind of color
+// blindness
+const (
+	colorblindBlue       = lipgloss.Color("#0072b2")
+	colorblindYellow     = lipgloss.Color("#f0e442")
+	colorblindVermillion = lipgloss.Color("#d55e00")
+)
+
+// Apply colorblind mode: its defaults to the flags that weren't given,
+// blue to vermillion in place of the gradients from green to red, and lost
+// samples told apart by the shape of their glyph alone, as the purple they
+// are drawn in is close to the low end of the palette
+func applyColorblind() {
+	applyFlagDefaults("colorblind", colorblindDefaults)
+	budgetGradient = []lipgloss.Color{colorblindBlue, colorblindYellow, colorblindVermillion}
+	transferGradient = []lipgloss.Color{colorblindVermillion, colorblindYellow, colorblindBlue}
+	lostCell = cell{"X", "", false}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// How the samples that share a column are combined when zoomed out
+type columnMode int
+
+const (
+	worstColumn columnMode = iota
+	medianColumn
+	bestColumn
+	meanColumn
+)
+
+var columnModeNames = []string{"worst", "median", "best", "mean"}
+
+// The most samples a column can hold, where the history runs out on any
+// terminal
+const maxZoom = 1 << 16
+
+func parseColumnMode(name string) (columnMode, bool) {
+	for i, n := range columnModeNames {
+		if n == name {
+			return columnMode(i), true
+		}
+	}
+	return 0, false
+}
+
+func (c columnMode) String() string {
+	return columnModeNames[c]
+}
+
+// Get the bounds of the displayed part of a series that has the given
+// number of raw samples per element, and whose first element has the given
+// index. The start is aligned to a column so columns don't shift as new
+// samples arrive.
+func (m *model) displayedRange(length, samplesPerElement, firstIndex int) (int, int) {
+	end := max(0, length-m.offset/samplesPerElement)
+	start := max(0, end-m.windowWidth*m.zoom)
+	if misalignment := (firstIndex + start) % m.zoom; misalignment != 0 {
+		start = min(end, start+m.zoom-misalignment)
+	}
+	return start, end
+}
+
+func (m *model) displayedColumns(series []float64, samplesPerElement, firstIndex int) []float64 {
+	start, end := m.displayedRange(len(series), samplesPerElement, firstIndex)
+	return compress(series[start:end], m.zoom, m.columnMode)
+}
+
+// Get the time of the first sample in each displayed column of a stream
+func (m *model) displayedTimes(s *stream) []time.Time {
+	start, end := m.displayedRange(len(s.timestamps), 1, s.counter-len(s.timestamps))
+	times := make([]time.Time, 0, m.windowWidth)
+	for i := start; i < end; i += m.zoom {
+		times = append(times, s.timestamps[i])
+	}
+	return times
+}
+
+func compress(data []float64, zoom int, mode columnMode) []float64 {
+	if zoom == 1 {
+		return data
+	}
+	columns := make([]float64, 0, (len(data)+zoom-1)/zoom)
+	for i := 0; i < len(data); i += zoom {
+		columns = append(columns, combine(data[i:min(i+zoom, len(data))], mode))
+	}
+	return columns
+}
+
+// Zoom out by a factor of two, or in when the factor is below one, keeping
+// the view within the history
+func (m *model) zoomBy(factor float64) {
+	m.zoom = max(1, min(maxZoom, int(float64(m.zoom)*factor)))
+	m.scrollTo(m.offset)
+	m.gradientUpdate = true
+}
+
+// Combine samples into one, where losses count as worse than any latency
+func combine(samples []float64, mode columnMode) float64 {
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+	lost := 0
+	for lost < len(sorted) && math.IsNaN(sorted[lost]) {
+		lost++
+	}
+	sorted = append(sorted[lost:], sorted[:lost]...)
+	switch mode {
+	case meanColumn:
+		return summarize(samples).mean
+	case bestColumn:
+		return sorted[0]
+	case medianColumn:
+		return sorted[len(sorted)/2]
+	default:
+		return sorted[len(sorted)-1]
+	}
+}
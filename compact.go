@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func runCompact(args []string) {
+	flags := flag.NewFlagSet("compact", flag.ExitOnError)
+	keep := flags.Duration("keep", 24*time.Hour, "Keep samples newer than this as they are")
+	step := flags.Duration("step", time.Minute, "Combine older samples of each target into one record per step")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback compact [-keep=<duration>] [-step=<duration>] <session>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	path := flags.Arg(0)
+
+	before, err := os.Stat(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	records, err := readSession(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	compacted := compact(records, *keep, *step)
+	if err := writeSession(path, compacted); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	saved := before.Size() - after.Size()
+	fmt.Printf("Compacted %s from %d to %d records, %s to %s, saving %s (%.0f%%)\n",
+		path, len(records), len(compacted), formatBytes(before.Size()), formatBytes(after.Size()),
+		formatBytes(saved), 100*float64(saved)/float64(max(1, before.Size())))
+}
+
+// Combine the samples older than keep, relative to the newest record, into
+// one summary per target and step. Records that are already summaries, and
+// events, are left as they are.
+func compact(records []record, keep, step time.Duration) []record {
+	var latest time.Time
+	for _, rec := range records {
+		if rec.Time.After(latest) {
+			latest = rec.Time
+		}
+	}
+	cutoff := latest.Add(-keep)
+
+	type bucket struct {
+		target string
+		start  time.Time
+	}
+	samples := make(map[bucket][]float64)
+	var buckets []bucket
+	var result []record
+	for _, rec := range records {
+		if !rec.Time.Before(cutoff) || rec.Count > 0 || rec.Event != "" {
+			result = append(result, rec)
+			continue
+		}
+		b := bucket{rec.Target, rec.Time.Truncate(step)}
+		if _, ok := samples[b]; !ok {
+			buckets = append(buckets, b)
+		}
+		samples[b] = append(samples[b], rec.latency())
+	}
+	for _, b := range buckets {
+		result = append(result, summaryRecord(b.target, b.start, samples[b]))
+	}
+	return result
+}
+
+func summaryRecord(target string, start time.Time, samples []float64) record {
+	stats := summarize(samples)
+	rec := record{
+		Time:      start,
+		Target:    target,
+		Lost:      stats.lost == stats.count,
+		Count:     stats.count,
+		LostCount: stats.lost,
+	}
+	if !rec.Lost {
+		rec.RTT, rec.Min, rec.Max = &stats.median, &stats.min, &stats.max
+	}
+	return rec
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
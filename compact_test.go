@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	sample := func(target string, after time.Duration, rtt float64) record {
+		rec := record{Time: testEpoch.Add(after), Target: target}
+		if math.IsNaN(rtt) {
+			rec.Lost = true
+		} else {
+			rec.RTT = &rtt
+		}
+		return rec
+	}
+	summary := record{Time: testEpoch.Add(-time.Hour), Target: "a", Count: 60, RTT: new(float64)}
+	records := []record{
+		summary,
+		sample("a", 0, 30),
+		sample("b", 10*time.Second, math.NaN()),
+		sample("a", 20*time.Second, 10),
+		{Time: testEpoch.Add(25 * time.Second), Event: "started"},
+		sample("a", 40*time.Second, math.NaN()),
+		sample("a", 50*time.Second, 20),
+		sample("a", 70*time.Second, 40),
+		sample("a", 2*time.Hour, 15),
+	}
+
+	compacted := compact(records, time.Hour, time.Minute)
+	if err := writeSession(filepath.Join(t.TempDir(), "session.jsonl"), compacted); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		after          time.Duration
+		target, event  string
+		count, lost    int
+		median, lo, hi float64
+	}{
+		{after: -time.Hour, target: "a", count: 60},
+		{target: "a", count: 4, lost: 1, median: 20, lo: 10, hi: 30},
+		{target: "b", count: 1, lost: 1},
+		{after: 25 * time.Second, event: "started"},
+		{after: time.Minute, target: "a", count: 1, median: 40, lo: 40, hi: 40},
+		{after: 2 * time.Hour, target: "a", median: 15},
+	}
+	if len(compacted) != len(want) {
+		t.Fatalf("compacted into %d records, want %d: %+v", len(compacted), len(want), compacted)
+	}
+	for i, w := range want {
+		rec := compacted[i]
+		if !rec.Time.Equal(testEpoch.Add(w.after)) || rec.Target != w.target || rec.Event != w.event ||
+			rec.Count != w.count || rec.LostCount != w.lost {
+			t.Errorf("record %d is %+v, want %+v", i, rec, w)
+			continue
+		}
+		if w.event != "" || rec.Count == 60 {
+			continue
+		}
+		if rec.Count > 0 && rec.LostCount == rec.Count {
+			if !rec.Lost || rec.RTT != nil {
+				t.Errorf("record %d lost every sample but is %+v", i, rec)
+			}
+			continue
+		}
+		if rec.latency() != w.median {
+			t.Errorf("record %d has a median of %v, want %v", i, rec.latency(), w.median)
+		}
+		if rec.Count > 0 && (*rec.Min != w.lo || *rec.Max != w.hi) {
+			t.Errorf("record %d ranges from %v to %v, want %v to %v", i, *rec.Min, *rec.Max, w.lo, w.hi)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, test := range tests {
+		if got := formatBytes(test.n); got != test.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", test.n, got, test.want)
+		}
+	}
+}
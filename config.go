@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type config struct {
+	addresses []string
+	variables map[string][]string
+	budgets   map[string]float64
+	// Notes and metadata of each address, such as its location
+	targets map[string]map[string]string
+	// Aggregations of the rows of aggregate charts
+	aggregation []string
+	// Color scales pinned to streams, as <min>-<max>
+	scales map[string]string
+	// The upstream target of each target, where * is every target
+	dependencies map[string]string
+	// Keys bound to each action, replacing its default keys
+	keys map[string][]string
+	// Values of flags that weren't given, by flag name
+	defaults map[string]any
+	// Named sets of flag values, picked with -profile
+	profiles map[string]map[string]any
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pingback", "config.toml")
+}
+
+// Load the config, where a missing file at the default path is no error
+func loadConfig(path string, explicit bool) (config, error) {
+	var c config
+	if path == "" {
+		return c, nil
+	}
+	text, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	values, err := parseTOML(string(text))
+	if err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.decode(values); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *config) decode(values map[string]any) error {
+	for key, value := range values {
+		var err error
+		switch key {
+		case "addresses":
+			c.addresses, err = stringsValue(key, value)
+		case "variables":
+			table, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("variables must be a table")
+			}
+			c.variables = make(map[string][]string)
+			for name, list := range table {
+				if c.variables[name], err = stringsValue("variables."+name, list); err != nil {
+					return err
+				}
+			}
+		case "budgets":
+			table, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("budgets must be a table")
+			}
+			c.budgets = make(map[string]float64)
+			for stage, limit := range table {
+				milliseconds, ok := numberValue(limit)
+				if !ok {
+					return fmt.Errorf("budgets.%s must be a number of milliseconds", stage)
+				}
+				if err := parseBudget(fmt.Sprintf("%s=%g", stage, milliseconds), c.budgets); err != nil {
+					return err
+				}
+			}
+		case "targets":
+			table, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("targets must be a table")
+			}
+			c.targets = make(map[string]map[string]string)
+			for address, fields := range table {
+				fields, ok := fields.(map[string]any)
+				if !ok {
+					return fmt.Errorf("targets.%q must be a table", address)
+				}
+				c.targets[address] = make(map[string]string)
+				for name, field := range fields {
+					if c.targets[address][name], ok = field.(string); !ok {
+						return fmt.Errorf("targets.%q.%s must be a string", address, name)
+					}
+				}
+			}
+		case "aggregation":
+			c.aggregation, err = stringsValue(key, value)
+		case "scales":
+			table, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("scales must be a table")
+			}
+			c.scales = make(map[string]string)
+			for name, span := range table {
+				if c.scales[name], ok = span.(string); !ok {
+					return fmt.Errorf("scales.%q must be a string such as \"1-20\"", name)
+				}
+			}
+		case "dependencies":
+			table, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("dependencies must be a table")
+			}
+			c.dependencies = make(map[string]string)
+			for name, upstream := range table {
+				if c.dependencies[name], ok = upstream.(string); !ok || c.dependencies[name] == "" {
+					return fmt.Errorf("dependencies.%q must be the address or label of a target", name)
+				}
+			}
+		case "keys":
+			table, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("keys must be a table")
+			}
+			c.keys = make(map[string][]string)
+			for action, keys := range table {
+				if key, ok := keys.(string); ok {
+					c.keys[action] = []string{key}
+				} else if c.keys[action], err = stringsValue("keys."+action, keys); err != nil {
+					return err
+				}
+			}
+		case "defaults":
+			c.defaults, err = settingsValue(key, value)
+		case "profiles":
+			table, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("profiles must be a table")
+			}
+			c.profiles = make(map[string]map[string]any)
+			for name, settings := range table {
+				if c.profiles[name], err = settingsValue(fmt.Sprintf("profiles.%q", name), settings); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unknown setting %s", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stringsValue(key string, value any) ([]string, error) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of strings", key)
+	}
+	strs := make([]string, len(list))
+	for i, item := range list {
+		if strs[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+	}
+	return strs, nil
+}
+
+// Check that a table of flag values holds strings, numbers, booleans and
+// lists of those, with the names of flags as keys
+func settingsValue(key string, value any) (map[string]any, error) {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a table", key)
+	}
+	for name, setting := range table {
+		items, isList := setting.([]any)
+		if !isList {
+			items = []any{setting}
+		}
+		for _, item := range items {
+			switch item.(type) {
+			case string, int64, float64, bool:
+			default:
+				return nil, fmt.Errorf("%s.%s must be a string, number, boolean or list of them", key, name)
+			}
+		}
+	}
+	return table, nil
+}
+
+// Set the flags named in a table of the config that weren't given
+func applySettings(key string, settings map[string]any) error {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, setting := range settings {
+		f, err := settingFlag(key, name)
+		if err != nil {
+			return err
+		}
+		if given[f.Name] {
+			continue
+		}
+		if err := setSetting(key, name, f, setting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The flag a setting of a table of the config sets
+func settingFlag(key, name string) (*flag.Flag, error) {
+	flagName := strings.ReplaceAll(name, "_", "-")
+	if flagName == "addresses" {
+		flagName = "address"
+	}
+	f := flag.Lookup(flagName)
+	if f == nil || flagName == "config" || flagName == "profile" {
+		return nil, fmt.Errorf("%s.%s is not a flag that can be set in the config", key, name)
+	}
+	return f, nil
+}
+
+// Set the flag to a setting, where lists set repeatable flags once per item
+// and are otherwise comma separated
+func setSetting(key, name string, f *flag.Flag, setting any) error {
+	items, isList := setting.([]any)
+	if !isList {
+		items = []any{setting}
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = fmt.Sprint(item)
+	}
+	if _, repeatable := f.Value.(*stringList); !repeatable {
+		values = []string{strings.Join(values, ",")}
+	}
+	for _, value := range values {
+		if err := flag.Set(f.Name, value); err != nil {
+			return fmt.Errorf("%s.%s: %w", key, name, err)
+		}
+	}
+	return nil
+}
+
+func numberValue(value any) (float64, bool) {
+	switch number := value.(type) {
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+var templatePattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// Expand the variables in a templated address over every value listed for
+// them in the config, labeling each expansion with its values. A variable
+// used twice takes the same value in both places. The variables are found in
+// the address once, so values are used as they are even when they look like
+// variables themselves.
+func expandTemplate(address string, variables map[string][]string) (addresses, labels []string, err error) {
+	matches := templatePattern.FindAllStringSubmatchIndex(address, -1)
+	if matches == nil {
+		return []string{address}, []string{address}, nil
+	}
+	var names []string
+	for _, match := range matches {
+		name := address[match[2]:match[3]]
+		if slices.Contains(names, name) {
+			continue
+		}
+		if values, ok := variables[name]; !ok || len(values) == 0 {
+			return nil, nil, fmt.Errorf("%s uses {%s}, which is not listed under [variables] in the config", address, name)
+		}
+		names = append(names, name)
+	}
+	// Every combination of the values of the variables, in the order they
+	// first appear
+	combinations := [][]string{nil}
+	for _, name := range names {
+		var expanded [][]string
+		for _, combination := range combinations {
+			for _, value := range variables[name] {
+				expanded = append(expanded, append(slices.Clip(combination), value))
+			}
+		}
+		combinations = expanded
+	}
+	for _, values := range combinations {
+		var b strings.Builder
+		end := 0
+		for _, match := range matches {
+			b.WriteString(address[end:match[0]])
+			b.WriteString(values[slices.Index(names, address[match[2]:match[3]])])
+			end = match[1]
+		}
+		b.WriteString(address[end:])
+		addresses = append(addresses, b.String())
+		labels = append(labels, strings.Join(values, " "))
+	}
+	return addresses, labels, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	variables := map[string][]string{
+		"site": {"oslo", "reykjavik"},
+		"n":    {"1", "2"},
+		"self": {"{self}.example.com"},
+		"a":    {"{b}"},
+		"b":    {"{a}"},
+	}
+	tests := []struct {
+		address   string
+		addresses []string
+		labels    []string
+		err       string
+	}{
+		{address: "example.com", addresses: []string{"example.com"}, labels: []string{"example.com"}},
+		{address: "{site}.example.com", addresses: []string{"oslo.example.com", "reykjavik.example.com"}, labels: []string{"oslo", "reykjavik"}},
+		{
+			address:   "gw{n}.{site}.example.com",
+			addresses: []string{"gw1.oslo.example.com", "gw1.reykjavik.example.com", "gw2.oslo.example.com", "gw2.reykjavik.example.com"},
+			labels:    []string{"1 oslo", "1 reykjavik", "2 oslo", "2 reykjavik"},
+		},
+		// A variable used twice takes one value at a time
+		{address: "{site}.{site}.example", addresses: []string{"oslo.oslo.example", "reykjavik.reykjavik.example"}, labels: []string{"oslo", "reykjavik"}},
+		{
+			address:   "{site}-{n}.{site}",
+			addresses: []string{"oslo-1.oslo", "oslo-2.oslo", "reykjavik-1.reykjavik", "reykjavik-2.reykjavik"},
+			labels:    []string{"oslo 1", "oslo 2", "reykjavik 1", "reykjavik 2"},
+		},
+		// Values are not expanded again
+		{address: "{self}", addresses: []string{"{self}.example.com"}, labels: []string{"{self}.example.com"}},
+		{address: "{a}.{b}", addresses: []string{"{b}.{a}"}, labels: []string{"{b} {a}"}},
+		{address: "{site}.{missing}", err: "{missing}, which is not listed"},
+	}
+	for _, test := range tests {
+		addresses, labels, err := expandTemplate(test.address, variables)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want one containing %q", test.address, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.address, err)
+			continue
+		}
+		if !slices.Equal(addresses, test.addresses) || !slices.Equal(labels, test.labels) {
+			t.Errorf("%s expanded to %q labeled %q, want %q labeled %q", test.address, addresses, labels, test.addresses, test.labels)
+		}
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	// The settings are checked against the flags of pingback, which main
+	// defines, so a few of them stand in here
+	defer func(flags *flag.FlagSet) { flag.CommandLine = flags }(flag.CommandLine)
+	tests := []struct {
+		config string
+		errs   []string
+	}{
+		{config: "[defaults]\ndelay = 500\naddresses = [\"example.com\", \"example.org\"]\n"},
+		{config: "[profiles.home]\ngroup_size = 10\n", errs: []string{`profiles."home".group_size is not a flag`}},
+		{config: "[defaults]\ndelay = \"often\"\n", errs: []string{"defaults.delay: "}},
+		{config: "[defaults]\nconfig = \"other.toml\"\n", errs: []string{"defaults.config is not a flag"}},
+		{
+			config: "[defaults]\nmax-pps = 10\ndelay = \"often\"\n[profiles.office]\nmax_pps = \"many\"\n",
+			errs:   []string{"defaults.delay: ", `profiles."office".max_pps: `},
+		},
+		{config: "[scales]\nexample = \"20-1\"\n", errs: []string{`scales."example": `}},
+	}
+	for _, test := range tests {
+		flag.CommandLine = flag.NewFlagSet("pingback", flag.ContinueOnError)
+		var addresses stringList
+		flag.Var(&addresses, "address", "")
+		flag.Int("delay", 1000, "")
+		flag.Float64("max-pps", 20, "")
+		flag.String("config", "", "")
+
+		values, err := parseTOML(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c config
+		if err := c.decode(values); err != nil {
+			t.Errorf("%q: %v", test.config, err)
+			continue
+		}
+		errs := c.validate()
+		if len(errs) != len(test.errs) {
+			t.Errorf("%q: got errors %v, want %d", test.config, errs, len(test.errs))
+			continue
+		}
+		for i, err := range errs {
+			if !strings.Contains(err.Error(), test.errs[i]) {
+				t.Errorf("%q: got error %v, want one containing %q", test.config, err, test.errs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// JSON Schema of the config, for editors that check TOML against one
+//
+//go:embed config.schema.json
+var configSchema string
+
+func runConfig(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "validate":
+			runConfigValidate(args[1:])
+			return
+		case "schema":
+			fmt.Print(configSchema)
+			return
+		}
+	}
+	fmt.Println("Usage: pingback config validate [<config>] | pingback config schema")
+	os.Exit(1)
+}
+
+func runConfigValidate(args []string) {
+	flags := flag.NewFlagSet("config validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback config validate [<config>]")
+		fmt.Fprintln(flags.Output(), "The config defaults to", defaultConfigPath())
+	}
+	flags.Parse(args)
+	path := defaultConfigPath()
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(1)
+	} else if flags.NArg() == 1 {
+		path = flags.Arg(0)
+	}
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	errs := cfg.validate()
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", path, err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", path)
+}
+
+// Check the values of the config that are only checked when used, reporting
+// every problem rather than the first
+func (c *config) validate() []error {
+	var errs []error
+	for _, address := range c.addresses {
+		if _, _, err := expandTemplate(address, c.variables); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.aggregation != nil {
+		if _, err := parseAggregations(c.aggregation); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	names := make([]string, 0, len(c.scales))
+	for name := range c.scales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := parsePinnedScale(name+"="+c.scales[name], make(map[string]scale)); err != nil {
+			errs = append(errs, fmt.Errorf("scales.%q: %w", name, err))
+		}
+	}
+	if _, err := newKeymap(false, c.keys); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, checkSettings("defaults", c.defaults)...)
+	profiles := make([]string, 0, len(c.profiles))
+	for name := range c.profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		errs = append(errs, checkSettings(fmt.Sprintf("profiles.%q", name), c.profiles[name])...)
+	}
+	return errs
+}
+
+// Check that every setting of a table of the config names a flag and that
+// the flag accepts its value, which sets the flag, so it is only done when
+// the flags aren't used afterwards
+func checkSettings(key string, settings map[string]any) []error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		f, err := settingFlag(key, name)
+		if err == nil {
+			err = setSetting(key, name, f, settings[name])
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Targets whose latency or loss correlate at least this strongly are
+// considered to degrade together
+const correlationGroupThreshold = 0.7
+
+type targetCorrelation struct {
+	a, b    *target
+	latency float64
+	loss    float64
+}
+
+// Pearson correlation coefficient of two equally long series, NaN when
+// either of them is constant
+func correlation(xs, ys []float64) float64 {
+	if len(xs) < 2 {
+		return math.NaN()
+	}
+	n := float64(len(xs))
+	meanX, meanY := 0.0, 0.0
+	for i := range xs {
+		meanX += xs[i] / n
+		meanY += ys[i] / n
+	}
+	cov, varX, varY := 0.0, 0.0, 0.0
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// Get the slot of the interval a sample sent at the time falls in, counting
+// from the slot starting at the origin
+func slotOf(at, origin time.Time, interval time.Duration) int64 {
+	return int64(math.Floor(float64(at.Sub(origin)) / float64(interval)))
+}
+
+// Get the sample of the target in each slot from start to end, the worst of
+// them where a slot has several. Slots the target has no sample in are left
+// out.
+func slotSamples(t *target, origin time.Time, interval time.Duration, start, end int64) map[int64]float64 {
+	grouped := make(map[int64][]float64)
+	for n := len(t.timestamps) - 1; n >= 0; n-- {
+		slot := slotOf(t.timestamps[n], origin, interval)
+		if slot < start {
+			break
+		}
+		if slot <= end {
+			grouped[slot] = append(grouped[slot], t.latencyData[n])
+		}
+	}
+	samples := make(map[int64]float64, len(grouped))
+	for slot, group := range grouped {
+		samples[slot] = combine(group, worstColumn)
+	}
+	return samples
+}
+
+// Correlate every pair of targets over the most recent slots of the interval
+// they both have samples in, strongest latency correlation first. Pairing
+// samples by the slot they were sent in, rather than by their number, keeps
+// targets that started late or missed probes lined up in time.
+func (m *model) correlations() []targetCorrelation {
+	if len(m.targets) < 2 {
+		return nil
+	}
+	interval := m.currentInterval()
+	// Slots start where the first target is probed, so the staggered probes
+	// of the other targets fall in the same slot
+	origin := m.targets[0].nextPing.Add(-m.targets[0].offset)
+	end := int64(math.MaxInt64)
+	for _, t := range m.targets {
+		if len(t.timestamps) == 0 {
+			return nil
+		}
+		end = min(end, slotOf(t.timestamps[len(t.timestamps)-1], origin, interval))
+	}
+	start := end - int64(m.correlationWindow) + 1
+	slots := make([]map[int64]float64, len(m.targets))
+	for i, t := range m.targets {
+		slots[i] = slotSamples(t, origin, interval, start, end)
+	}
+
+	var result []targetCorrelation
+	common := 0
+	for i, a := range m.targets {
+		for j := i + 1; j < len(m.targets); j++ {
+			b := m.targets[j]
+			var latencyA, latencyB, lossA, lossB []float64
+			for slot := start; slot <= end; slot++ {
+				x, okA := slots[i][slot]
+				y, okB := slots[j][slot]
+				if !okA || !okB {
+					continue
+				}
+				lossA = append(lossA, boolToFloat(math.IsNaN(x)))
+				lossB = append(lossB, boolToFloat(math.IsNaN(y)))
+				if !math.IsNaN(x) && !math.IsNaN(y) {
+					latencyA = append(latencyA, m.quantize(x))
+					latencyB = append(latencyB, m.quantize(y))
+				}
+			}
+			common = max(common, len(lossA))
+			result = append(result, targetCorrelation{
+				a:       a,
+				b:       b,
+				latency: correlation(latencyA, latencyB),
+				loss:    correlation(lossA, lossB),
+			})
+		}
+	}
+	if common < 2 {
+		return nil
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if math.IsNaN(result[j].latency) {
+			return !math.IsNaN(result[i].latency)
+		}
+		return result[i].latency > result[j].latency
+	})
+	return result
+}
+
+// Group targets that are transitively strongly correlated
+func correlationGroups(targets []*target, correlations []targetCorrelation) [][]*target {
+	parent := make(map[*target]*target)
+	var find func(t *target) *target
+	find = func(t *target) *target {
+		if p, ok := parent[t]; ok && p != t {
+			parent[t] = find(p)
+			return parent[t]
+		}
+		return t
+	}
+	for _, c := range correlations {
+		if c.latency >= correlationGroupThreshold || c.loss >= correlationGroupThreshold {
+			parent[find(c.a)] = find(c.b)
+		}
+	}
+	members := make(map[*target][]*target)
+	var roots []*target
+	for _, t := range targets {
+		root := find(t)
+		if len(members[root]) == 0 {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], t)
+	}
+	var groups [][]*target
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			groups = append(groups, members[root])
+		}
+	}
+	return groups
+}
+
+func (m *model) renderCorrelations() string {
+	correlations := m.correlations()
+	if len(correlations) == 0 {
+		return ""
+	}
+	formatCoefficient := func(r float64) string {
+		if math.IsNaN(r) {
+			return "    -"
+		}
+		return fmt.Sprintf("%5.2f", r)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Correlation over the last %d samples:", m.correlationWindow),
+		"latency   loss  targets",
+	}
+	for _, c := range correlations {
+		lines = append(lines, fmt.Sprintf("  %s  %s  %s ~ %s",
+			formatCoefficient(c.latency), formatCoefficient(c.loss), c.a.address, c.b.address))
+	}
+	for _, group := range correlationGroups(m.targets, correlations) {
+		addresses := make([]string, len(group))
+		for i, t := range group {
+			addresses[i] = t.address
+		}
+		lines = append(lines, "Degrading together: "+strings.Join(addresses, ", "))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
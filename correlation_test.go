@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCorrelationPairsSamplesByTime(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1", "10.0.0.2"}, time.Second, []int{4})
+	m.correlationWindow = 30
+	m.staggerTargets(clock.Now())
+	a, b := m.targets[0], m.targets[1]
+	latency := func(slot int) float64 {
+		return 10 + float64(slot*slot%17)
+	}
+
+	// The second target starts five slots late and misses a probe, yet its
+	// latencies follow those of the first at the same times
+	for slot := range 20 {
+		at := testEpoch.Add(time.Duration(slot) * time.Second)
+		m.update(latencyMsg{a, latency(slot), at, sampleMeta{}})
+		if slot >= 5 && slot != 12 {
+			m.update(latencyMsg{b, latency(slot) * 2, at.Add(b.offset), sampleMeta{}})
+		}
+	}
+	correlations := m.correlations()
+	if len(correlations) != 1 {
+		t.Fatalf("got %d correlations, want one", len(correlations))
+	}
+	if r := correlations[0].latency; math.Abs(r-1) > 1e-9 {
+		t.Errorf("latencies correlate by %v, want 1", r)
+	}
+}
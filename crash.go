@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// A panic caught while the program was running. Panics in commands are sent
+// to Update as this message, which ends the program.
+type panicMsg struct {
+	value any
+	stack []byte
+}
+
+// Catch panics in the command and in the commands of the batch it returns,
+// if any, turning them into a panicMsg
+func recoverCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = panicMsg{r, debug.Stack()}
+			}
+		}()
+		msg = cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for i := range batch {
+				batch[i] = recoverCmd(batch[i])
+			}
+		}
+		return msg
+	}
+}
+
+// Run the program, catching panics in Update and View, which run on this
+// goroutine, and restoring the terminal after them
+func runProgram(p *tea.Program, m *model) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.crash = &panicMsg{r, debug.Stack()}
+			p.Kill()
+		}
+	}()
+	_, err = p.Run()
+	return err
+}
+
+// Write the history and a report of the crash to a new directory, so the
+// session isn't lost and the report can be attached to a bug report
+func (m *model) dumpCrash(stopped time.Time) (string, error) {
+	dir, err := os.MkdirTemp("", "pingback-crash-")
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(filepath.Join(dir, "crash.txt"))
+	if err != nil {
+		return dir, err
+	}
+	fmt.Fprintf(file, "panic: %v\n\n%s\n", m.crash.value, m.crash.stack)
+	m.writeDiagnostics(file, stopped)
+	if err := file.Close(); err != nil {
+		return dir, err
+	}
+	return dir, m.exportHistory(filepath.Join(dir, "history.json"))
+}
+
+// Export every sample, even though the state that caused the panic may
+// cause another one
+func (m *model) exportHistory(path string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("exporting the history panicked too: %v", r)
+		}
+	}()
+	return m.exportAll(path)
+}
+
+// Write what is needed to reproduce a problem: the version, the command
+// line, the terminal and the state of each target
+func (m *model) writeDiagnostics(w io.Writer, now time.Time) {
+	version := "(unknown)"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+	}
+	fmt.Fprintf(w, "pingback %s, %s, %s/%s\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "command: %s\n", strings.Join(os.Args, " "))
+	fmt.Fprintf(w, "TERM=%s COLORTERM=%s, %d columns wide\n", os.Getenv("TERM"), os.Getenv("COLORTERM"), m.windowWidth)
+	fmt.Fprintf(w, "ran from %s for %v, every %v\n", m.started.Format(time.RFC3339), now.Sub(m.started).Round(time.Second), m.interval)
+	for _, t := range m.targets {
+		fmt.Fprintf(w, "target %s: %d samples, %d stuck probes restarted\n", t.address, t.counter, t.restarts)
+	}
+}
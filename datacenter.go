@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Defaults of datacenter mode, tuned for hosts microseconds apart: fast
+// probes, TCP to SSH alongside ICMP, since some hosts deprioritize ICMP,
+// groups of about a second and outages counted in tenths of a second
+var datacenterDefaults = [][2]string{
+	{"delay", "50"},
+	{"max-pps", "1000"},
+	{"probes", "icmp,tcp:22"},
+	{"group", "20"},
+	{"outage-after", "10"},
+	{"loss-window", "200"},
+	{"unit", "us"},
+	{"metrics-buckets", "0.01,0.02,0.05,0.1,0.2,0.5,1,2,5,10"},
+}
+
+// Color scale of datacenter mode, from 20 µs to 2 ms, so that the normal
+// spread of latencies in a datacenter isn't all one color
+var datacenterScale = scale{0.02, 2}
+
+// Apply the defaults of datacenter mode to the flags that weren't given
+func applyDatacenterDefaults() {
+	applyFlagDefaults("datacenter", datacenterDefaults)
+}
+
+// Apply the defaults of a mode to the flags that weren't given
+func applyFlagDefaults(mode string, defaults [][2]string) {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, setting := range defaults {
+		if !given[setting[0]] {
+			if err := flag.Set(setting[0], setting[1]); err != nil {
+				panic(fmt.Sprintf("%s default -%s: %v", mode, setting[0], err))
+			}
+		}
+	}
+}
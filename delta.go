@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Faster than the previous sample is blue, as fast is gray and slower is red
+var deltaGradient = []lipgloss.Color{"#466be3", "#808080", "#d23105"}
+
+// Changes by this factor or more get the outermost colors
+const deltaRange = 2
+
+// Color each sample by how much it changed from the previous one, so
+// instability stands out even when the latency stays within a narrow band
+func (m *model) renderDeltaStream(data []float64) string {
+	cells := make([]cell, len(data))
+	previous := math.NaN()
+	for i, latency := range data {
+		if math.IsNaN(latency) {
+			cells[i] = lostCell
+			continue
+		}
+		ratio := 0.5
+		if !math.IsNaN(previous) && math.Abs(latency-previous) >= m.noiseFloor {
+			ratio = deltaRatio(previous, latency, m.minLatency)
+		}
+		cells[i] = gradientCell(deltaGradient, ratio)
+		previous = latency
+	}
+	return renderRow(cells)
+}
+
+// Map the change between two samples to a ratio of the gradient, where no
+// change is in the middle. Differences between targets can be negative, so
+// samples are clamped to the lowest latency.
+func deltaRatio(previous, latency, floor float64) float64 {
+	change := math.Log(math.Max(latency, floor)/math.Max(previous, floor)) / math.Log(deltaRange)
+	return 0.5 + 0.5*math.Max(-1, math.Min(1, change))
+}
+
+func renderDeltaLegend() string {
+	factors := []float64{1.0 / 2, 1.0 / 1.5, 1.0 / 1.2, 1, 1.2, 1.5, 2}
+	entries := make([]string, len(factors))
+	for i, factor := range factors {
+		label := fmt.Sprintf("×%.2g", factor)
+		if factor < 1 {
+			label = fmt.Sprintf("÷%.2g", 1/factor)
+		}
+		entries[i] = fmt.Sprintf("%s %-5s", renderRow([]cell{gradientCell(deltaGradient, deltaRatio(1, factor, 0))}), label)
+	}
+	return lipgloss.JoinVertical(lipgloss.Top, "Change Legend (from the previous sample):",
+		lipgloss.JoinHorizontal(lipgloss.Top, entries...))
+}
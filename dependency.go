@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse a dependency of targets, such as *=gateway, into the dependencies
+// by the name of the dependent target
+func parseDependency(value string, dependencies map[string]string) error {
+	name, upstream, ok := strings.Cut(value, "=")
+	if !ok || name == "" || upstream == "" {
+		return fmt.Errorf("-depends %q is not of the form <target>=<upstream>", value)
+	}
+	dependencies[name] = upstream
+	return nil
+}
+
+// Link each target to the target it depends on. Targets are named by address,
+// label or group, where * names every target without a dependency of its
+// own, and the upstream by address or label.
+func (m *model) linkDependencies(dependencies map[string]string) error {
+	used := make(map[string]bool)
+	for _, t := range m.targets {
+		name := "*"
+		for _, n := range []string{t.address, t.label, t.group} {
+			if _, ok := dependencies[n]; ok && n != "" {
+				name = n
+				break
+			}
+		}
+		upstreamName, ok := dependencies[name]
+		if !ok {
+			continue
+		}
+		used[name] = true
+		upstream := m.targetNamed(upstreamName)
+		if upstream == nil {
+			return fmt.Errorf("-depends %s=%s: %s is no target", name, upstreamName, upstreamName)
+		}
+		if upstream != t {
+			t.upstream = upstream
+		} else if name != "*" {
+			return fmt.Errorf("-depends %s=%s: %s can't depend on itself", name, upstreamName, t.label)
+		}
+	}
+	for name := range dependencies {
+		if !used[name] && name != "*" {
+			return fmt.Errorf("-depends %s matches no target", name)
+		}
+	}
+	for _, t := range m.targets {
+		u := t.upstream
+		for range m.targets {
+			if u == nil {
+				break
+			}
+			if u == t {
+				return fmt.Errorf("the dependencies of %s go round in a circle", t.label)
+			}
+			u = u.upstream
+		}
+	}
+	return nil
+}
+
+func (m *model) targetNamed(name string) *target {
+	for _, t := range m.targets {
+		if t.address == name || t.label == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Get the target that this one depends on, directly or not, that is down,
+// which explains why this one is. A packet it happened to lose at the same
+// time explains nothing.
+func (t *target) upstreamDown() *target {
+	for u := t.upstream; u != nil; u = u.upstream {
+		if u.outage != nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// Get the target that this one depends on, directly or not, that is down or
+// losing packets in a row. When both fail at once, the losses of this target
+// may be handled before those of the upstream target, whose streak is then
+// still short of an outage. The blame only holds while the streak lasts.
+func (t *target) upstreamFailing() *target {
+	for u := t.upstream; u != nil; u = u.upstream {
+		if u.outage != nil || u.lossStreak > 0 {
+			return u
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Get the metadata of the target from the config, where probes of an address
+// share the metadata of the address
+func (m *model) metadata(t *target) map[string]string {
+	if metadata, ok := m.targetMetadata[t.address]; ok {
+		return metadata
+	}
+	return m.targetMetadata[t.group]
+}
+
+// Record the metadata of every target, so it travels with the session
+func (m *model) recordMetadata(now time.Time) {
+	for _, t := range m.targets {
+		if metadata := m.metadata(t); metadata != nil {
+			m.record(record{Time: now, Event: "metadata", Target: t.address, Metadata: metadata})
+		}
+	}
+}
+
+func (m *model) renderDetails() string {
+	t := m.targets[m.focus]
+	lines := []string{fmt.Sprintf("Details of %s:", t.label)}
+	lines = append(lines, fmt.Sprintf("  %-10s %s", "address", t.address))
+	if t.ip != "" {
+		lines = append(lines, fmt.Sprintf("  %-10s %s", "ip", t.ip))
+	}
+	if len(t.ips) > 1 {
+		ips := make([]string, 0, len(t.ips))
+		for ip := range t.ips {
+			ips = append(ips, ip)
+		}
+		sort.Slice(ips, func(i, j int) bool { return t.ips[ips[i]] > t.ips[ips[j]] })
+		for i, ip := range ips {
+			ips[i] = fmt.Sprintf("%s (%d)", ip, t.ips[ip])
+		}
+		lines = append(lines, fmt.Sprintf("  %-10s %s", "resolved", strings.Join(ips, ", ")))
+	}
+	for i := len(t.meta) - 1; i >= 0; i-- {
+		if meta := t.meta[i]; meta.maxPlayers > 0 {
+			lines = append(lines, fmt.Sprintf("  %-10s %d of %d", "players", meta.players, meta.maxPlayers))
+			break
+		}
+	}
+	metadata := m.metadata(t)
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		if key != "notes" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("  %-10s %s", key, metadata[key]))
+	}
+	if notes, ok := metadata["notes"]; ok {
+		lines = append(lines, "", lipgloss.NewStyle().PaddingLeft(2).Width(max(20, m.windowWidth)).Render(notes))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func isDNS(address string) bool {
+	return strings.HasPrefix(address, "dns://")
+}
+
+// Time how long the resolver of a dns://<resolver>/<name> address takes to
+// answer a query for the A record of the name, sent over UDP without a cache
+// or hosts file in the way. A name that doesn't exist is still an answer,
+// but failures of the resolver count as lost.
+func (m *model) dnsCmd(ctx context.Context, t *target) tea.Cmd {
+	mark := m.mark
+	return func() tea.Msg {
+		parsed, err := url.Parse(t.address)
+		if err != nil {
+			return errMsg{err}
+		}
+		resolver := parsed.Host
+		if parsed.Port() == "" {
+			resolver = net.JoinHostPort(parsed.Hostname(), "53")
+		}
+		name, err := dnsmessage.NewName(strings.TrimSuffix(strings.TrimPrefix(parsed.Path, "/"), ".") + ".")
+		if err != nil {
+			return errMsg{err}
+		}
+		id := uint16(rand.N(1 << 16))
+		query, err := (&dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+			Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+		}).Pack()
+		if err != nil {
+			return errMsg{err}
+		}
+
+		sent, start := m.clock.Now(), time.Now()
+		ip := ""
+		lost := func(class string) tea.Msg {
+			return latencyMsg{t, math.NaN(), sent, sampleMeta{ip: ip, errClass: class}}
+		}
+		conn, err := probeDialer(mark).DialContext(ctx, "udp", resolver)
+		if err != nil {
+			return lost(classifyError(err))
+		}
+		defer conn.Close()
+		ip = conn.RemoteAddr().(*net.UDPAddr).IP.String()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		if _, err := conn.Write(query); err != nil {
+			return lost(classifyError(err))
+		}
+		buffer := make([]byte, 1500)
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				return lost(classifyError(err))
+			}
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buffer[:n])
+			if err != nil || header.ID != id || !header.Response {
+				// Not the answer to this query
+				continue
+			}
+			if header.RCode != dnsmessage.RCodeSuccess && header.RCode != dnsmessage.RCodeNameError {
+				return lost(strings.ToLower(strings.TrimPrefix(header.RCode.String(), "RCode")))
+			}
+			latency := time.Since(start).Seconds() * 1000
+			return latencyMsg{t, latency, sent, sampleMeta{ip: ip}}
+		}
+	}
+}
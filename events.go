@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const interfaceCheckInterval = 5 * time.Second
+
+type eventKind int
+
+const (
+	markerEvent eventKind = iota
+	ipChangeEvent
+	interfaceEvent
+	budgetEvent
+	speedTestEvent
+	wireguardEvent
+	slaEvent
+	bisectEvent
+)
+
+var eventKindNames = map[eventKind]string{
+	markerEvent:    "marker",
+	ipChangeEvent:  "ip_change",
+	interfaceEvent: "interface",
+	budgetEvent:    "budget",
+	speedTestEvent: "speed_test",
+	wireguardEvent: "wireguard",
+	slaEvent:       "sla",
+	bisectEvent:    "bisect",
+}
+
+var eventSymbols = map[eventKind]string{
+	markerEvent:    lipgloss.NewStyle().Foreground(lipgloss.Color("#edd03a")).Render("▼"),
+	ipChangeEvent:  lipgloss.NewStyle().Foreground(lipgloss.Color("#29bbec")).Render("◆"),
+	interfaceEvent: lipgloss.NewStyle().Foreground(lipgloss.Color("#fb8022")).Render("◇"),
+	budgetEvent:    lipgloss.NewStyle().Foreground(lipgloss.Color("#d23105")).Render("◈"),
+	speedTestEvent: lipgloss.NewStyle().Foreground(lipgloss.Color("#31f199")).Render("⇅"),
+	wireguardEvent: lipgloss.NewStyle().Foreground(lipgloss.Color("#a3fd3d")).Render("⚿"),
+	slaEvent:       lipgloss.NewStyle().Foreground(lipgloss.Color("#edd03a")).Render("§"),
+	bisectEvent:    lipgloss.NewStyle().Foreground(lipgloss.Color("#29bbec")).Render("⇄"),
+}
+
+type event struct {
+	time   time.Time
+	kind   eventKind
+	target string
+	label  string
+}
+
+func (e event) record() record {
+	return record{Time: e.time, Event: eventKindNames[e.kind], Target: e.target, Label: e.label}
+}
+
+// Add an event, recording it alongside the samples
+func (m *model) addEvent(kind eventKind, target, label string, at time.Time) {
+	e := event{at, kind, target, label}
+	m.events = append(m.events, e)
+	m.record(e.record())
+}
+
+func (m *model) addMarker(at time.Time) {
+	m.markerCount++
+	m.addEvent(markerEvent, "", fmt.Sprint(m.markerCount), at)
+}
+
+// Add a marker labeled with a note, such as what happened at the time
+func (m *model) annotate(text string, at time.Time) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		m.addMarker(at)
+		return
+	}
+	m.addEvent(markerEvent, "", text, at)
+}
+
+// Track the address a target resolves to. History stays keyed by the target,
+// so a name whose addresses rotate, as those of CDNs do, stays one stream.
+// Only addresses the target hasn't resolved to before are events, and the
+// first address is recorded without one.
+func (m *model) trackAddress(t *target, ip string, at time.Time) {
+	if ip == "" {
+		return
+	}
+	if t.ips == nil {
+		t.ips = make(map[string]int)
+	}
+	t.ips[ip]++
+	previous := t.ip
+	t.ip = ip
+	if t.ips[ip] > 1 {
+		return
+	}
+	metadata := map[string]string{"ip": ip}
+	if previous == "" {
+		m.record(record{Time: at, Event: eventKindNames[ipChangeEvent], Target: t.address, Label: "resolved to " + ip, Metadata: metadata})
+		return
+	}
+	metadata["previous_ip"] = previous
+	e := event{at, ipChangeEvent, t.address, fmt.Sprintf("%s %s", t.address, ip)}
+	m.events = append(m.events, e)
+	rec := e.record()
+	rec.Metadata = metadata
+	m.record(rec)
+}
+
+type interfacesMsg map[string]string
+
+// Describe the state of every network interface, so changes can be detected
+// by comparing descriptions
+func (m *model) checkInterfacesCmd(delay time.Duration) tea.Cmd {
+	return m.clock.Tick(delay, func(time.Time) tea.Msg {
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return nil
+		}
+		states := make(interfacesMsg)
+		for _, iface := range ifaces {
+			state := "down"
+			if iface.Flags&net.FlagUp != 0 {
+				state = "up"
+			}
+			addrs, _ := iface.Addrs()
+			for _, addr := range addrs {
+				state += " " + addr.String()
+			}
+			states[iface.Name] = state
+		}
+		return states
+	})
+}
+
+func (m *model) trackInterfaces(states interfacesMsg, at time.Time) {
+	if m.interfaces != nil {
+		for name, state := range states {
+			previous, known := m.interfaces[name]
+			switch {
+			case !known:
+				m.addEvent(interfaceEvent, "", name+" added", at)
+			case strings.Fields(state)[0] != strings.Fields(previous)[0]:
+				m.addEvent(interfaceEvent, "", name+" "+strings.Fields(state)[0], at)
+			case state != previous:
+				m.addEvent(interfaceEvent, "", name+" address", at)
+			}
+		}
+		for name := range m.interfaces {
+			if _, ok := states[name]; !ok {
+				m.addEvent(interfaceEvent, "", name+" removed", at)
+			}
+		}
+	}
+	m.interfaces = states
+}
+
+// Render the events that happened during the displayed samples, each one
+// in the column of the sample it happened during, followed by its label
+func (m *model) renderEventLane(timestamps []time.Time) string {
+	if len(timestamps) == 0 {
+		return ""
+	}
+	type placed struct {
+		column int
+		event  event
+	}
+	var lane []placed
+	for _, e := range m.events {
+		if e.time.Before(timestamps[0]) {
+			continue
+		}
+		column, found := slices.BinarySearchFunc(timestamps, e.time, func(t, target time.Time) int {
+			return t.Compare(target)
+		})
+		if !found {
+			column--
+		}
+		lane = append(lane, placed{column, e})
+	}
+	if len(lane) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	position := 0
+	for i, p := range lane {
+		if p.column < position {
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", p.column-position))
+		b.WriteString(eventSymbols[p.event.kind])
+		end := len(timestamps)
+		if i+1 < len(lane) {
+			end = lane[i+1].column
+		}
+		// Cut by the columns the label takes, not its bytes, which would
+		// split its runes
+		label := ansi.Truncate(p.event.label, max(0, end-p.column-1), "")
+		b.WriteString(label)
+		position = p.column + 1 + ansi.StringWidth(label)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, "Events:", b.String())
+}
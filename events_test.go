@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestEventLaneTruncatesByWidth(t *testing.T) {
+	m, _ := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	timestamps := make([]time.Time, 8)
+	for i := range timestamps {
+		timestamps[i] = testEpoch.Add(time.Duration(i) * time.Second)
+	}
+	m.events = []event{
+		{testEpoch, markerEvent, "", "Þjórsá ölkelda"},
+		{testEpoch.Add(4 * time.Second), markerEvent, "", "日本語のラベル"},
+	}
+	lane := m.renderEventLane(timestamps)
+	line := lane[strings.LastIndex(lane, "\n")+1:]
+	if !utf8.ValidString(line) {
+		t.Fatalf("the lane %q isn't valid UTF-8", line)
+	}
+	if width := ansi.StringWidth(line); width > len(timestamps) {
+		t.Errorf("the lane %q is %d columns wide, want at most %d", line, width, len(timestamps))
+	}
+	if !strings.Contains(line, "Þjó") {
+		t.Errorf("the lane %q lost the start of the first label", line)
+	}
+}
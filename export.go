@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Check that data can be exported to the path, by its extension
+func checkExportPath(path string) error {
+	switch filepath.Ext(path) {
+	case ".csv", ".json":
+		return nil
+	}
+	return fmt.Errorf("-export %s must end in .csv or .json", path)
+}
+
+// An aggregate row of a target, with the time of the last sample of each of
+// its groups
+type exportedRow struct {
+	group  int
+	name   string
+	times  []time.Time
+	values []float64
+}
+
+// Get every aggregate row of the target
+func (m *model) exportedRows(t *target) []exportedRow {
+	var rows []exportedRow
+	for i, size := range m.aggregateCounts {
+		names := aggregationRowNames(m.aggregations, size)
+		for j, values := range t.aggregateData[i] {
+			times := make([]time.Time, len(values))
+			for k := range values {
+				// Samples this old may have been dropped
+				if n := (k+1)*size - 1; t.counter-n <= len(t.timestamps) {
+					times[k] = t.sampleTime(n)
+				}
+			}
+			rows = append(rows, exportedRow{size, names[j], times, values})
+		}
+	}
+	return rows
+}
+
+// Write every sample and aggregate row of every target to a CSV or JSON
+// file, by the extension of the path
+func (m *model) exportAll(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".json" {
+		err = m.exportJSON(file)
+	} else {
+		err = m.exportCSV(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Write a row per sample and per value of each aggregate row. Samples are
+// in group 1 with their total round trip time, and the values of aggregate
+// rows are latencies in milliseconds except for those of loss rows, which
+// count lost samples.
+func (m *model) exportCSV(file *os.File) error {
+	w := csv.NewWriter(file)
+	w.Write([]string{"target", "timestamp", "group", "row", "value", "lost", "ip", "ttl", "error"})
+	value := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	timestamp := func(at time.Time) string {
+		if at.IsZero() {
+			return ""
+		}
+		return m.timeFormat.formatPrecise(at)
+	}
+	for _, t := range m.targets {
+		latencies := t.roundTrips()
+		first := t.counter - len(latencies)
+		skipped := len(t.latencyData) - len(latencies)
+		for i, latency := range latencies {
+			meta, _ := t.metaOf(first + i)
+			ttl := ""
+			if meta.ttl > 0 {
+				ttl = strconv.Itoa(meta.ttl)
+			}
+			w.Write([]string{t.address, timestamp(t.timestamps[skipped+i]), "1", "rtt_ms", value(latency),
+				strconv.FormatBool(math.IsNaN(latency)), meta.ip, ttl, meta.errClass})
+		}
+		for _, row := range m.exportedRows(t) {
+			for k, v := range row.values {
+				w.Write([]string{t.address, timestamp(row.times[k]), strconv.Itoa(row.group), row.name, value(v), "", "", "", ""})
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (m *model) exportJSON(file *os.File) error {
+	type sample struct {
+		Time  any      `json:"timestamp"`
+		RTT   *float64 `json:"rtt_ms"`
+		Lost  bool     `json:"lost"`
+		IP    string   `json:"ip,omitempty"`
+		TTL   int      `json:"ttl,omitempty"`
+		Error string   `json:"error,omitempty"`
+	}
+	type value struct {
+		Time  any      `json:"timestamp"`
+		Value *float64 `json:"value"`
+	}
+	type row struct {
+		Group  int     `json:"group"`
+		Name   string  `json:"row"`
+		Values []value `json:"values"`
+	}
+	type exported struct {
+		Address    string   `json:"target"`
+		Label      string   `json:"label"`
+		Samples    []sample `json:"samples"`
+		Aggregates []row    `json:"aggregates"`
+	}
+	// Timestamps are formatted as configured, where unix times are numbers
+	timestamp := func(at time.Time) any {
+		if at.IsZero() {
+			return nil
+		}
+		if m.timeFormat.name == "unix" {
+			return json.Number(m.timeFormat.formatPrecise(at))
+		}
+		return m.timeFormat.formatPrecise(at)
+	}
+	// Lost samples are null, as JSON has no NaN
+	number := func(v float64) *float64 {
+		if math.IsNaN(v) {
+			return nil
+		}
+		return &v
+	}
+	targets := make([]exported, len(m.targets))
+	for i, t := range m.targets {
+		latencies := t.roundTrips()
+		e := exported{Address: t.address, Label: t.label, Samples: make([]sample, len(latencies))}
+		first := t.counter - len(latencies)
+		skipped := len(t.latencyData) - len(latencies)
+		for j, latency := range latencies {
+			meta, _ := t.metaOf(first + j)
+			e.Samples[j] = sample{timestamp(t.timestamps[skipped+j]), number(latency), math.IsNaN(latency), meta.ip, meta.ttl, meta.errClass}
+		}
+		for _, r := range m.exportedRows(t) {
+			values := make([]value, len(r.values))
+			for k, v := range r.values {
+				values[k] = value{timestamp(r.times[k]), number(v)}
+			}
+			e.Aggregates = append(e.Aggregates, row{r.group, r.name, values})
+		}
+		targets[i] = e
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]any{"targets": targets})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Export the model to a file of the name in a temporary directory and read
+// it back
+func exportFile(t *testing.T, m *model, name string) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := m.exportAll(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestExportCSVSamples(t *testing.T) {
+	m, clock := newTestModel(t, []string{"https://example.com"}, 200*time.Millisecond, []int{4})
+	m.httpPhase = slices.Index(httpStages, "connect")
+	var err error
+	if m.timeFormat, err = parseTimeFormat("iso8601", "UTC"); err != nil {
+		t.Fatal(err)
+	}
+	target := m.targets[0]
+	samples := []struct {
+		latency float64
+		stages  []float64
+	}{
+		{120, []float64{math.NaN(), 10, 30, 60}},
+		{math.NaN(), nil},
+		// A reused connection has no connect stage
+		{80, []float64{math.NaN(), math.NaN(), math.NaN(), 70}},
+	}
+	for _, s := range samples {
+		m.update(latencyMsg{target, s.latency, clock.Now(), sampleMeta{stages: s.stages}})
+		clock.Advance(200 * time.Millisecond)
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(exportFile(t, m, "export.csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"https://example.com", "2024-01-01T12:00:00Z", "1", "rtt_ms", "120", "false"},
+		{"https://example.com", "2024-01-01T12:00:00.2Z", "1", "rtt_ms", "", "true"},
+		{"https://example.com", "2024-01-01T12:00:00.4Z", "1", "rtt_ms", "80", "false"},
+	}
+	for i, row := range want {
+		if got := rows[i+1][:6]; !slices.Equal(got, row) {
+			t.Errorf("sample %d was exported as %q, want %q", i, got, row)
+		}
+	}
+}
+
+func TestExportJSONTimeFormat(t *testing.T) {
+	tests := []struct {
+		format, timezone string
+		want             string
+	}{
+		{"iso8601", "Atlantic/Reykjavik", `"2024-01-01T12:00:00.25Z"`},
+		{"iso8601", "Asia/Tokyo", `"2024-01-01T21:00:00.25+09:00"`},
+		{"local", "Asia/Tokyo", `"2024-01-01 21:00:00.250000"`},
+		{"unix", "", `1704110400.250000`},
+	}
+	for _, test := range tests {
+		m, clock := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+		var err error
+		if m.timeFormat, err = parseTimeFormat(test.format, test.timezone); err != nil {
+			t.Skip(err)
+		}
+		clock.Advance(250 * time.Millisecond)
+		m.update(latencyMsg{m.targets[0], 5, clock.Now(), sampleMeta{}})
+
+		var exported struct {
+			Targets []struct {
+				Samples []struct {
+					Time json.RawMessage `json:"timestamp"`
+				} `json:"samples"`
+			} `json:"targets"`
+		}
+		if err := json.Unmarshal(exportFile(t, m, "export.json"), &exported); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(exported.Targets[0].Samples[0].Time); got != test.want {
+			t.Errorf("%s in %q exported the sample at %s, want %s", test.format, test.timezone, got, test.want)
+		}
+	}
+}
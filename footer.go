@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Summarize the samples of the target since the start, leaving out
+// backfilled samples. The summary is kept until a sample is added or
+// dropped, as summarizing a long session takes a while.
+func (m *model) sessionSummary(t *target) summary {
+	latencies := t.roundTrips()
+	if t.summaryCounter == t.counter && t.summaryKept == len(latencies) {
+		return t.summary
+	}
+	skipped := len(t.latencyData) - len(latencies)
+	start := max(0, searchTime(t.timestamps, m.started)-skipped)
+	t.summary = summarize(latencies[start:])
+	t.summaryCounter, t.summaryKept = t.counter, len(latencies)
+	return t.summary
+}
+
+// Describe the focused target over the session in numbers
+func (m *model) renderFooter() string {
+	t := m.targets[m.focus]
+	if t.counter == 0 {
+		return ""
+	}
+	stats := m.sessionSummary(t)
+	if stats.count == 0 {
+		return ""
+	}
+	f, unit := m.latencyFormat, m.latencyFormat.unitOf(stats.max)
+	current := "lost"
+	latencies := t.roundTrips()
+	if latest := latencies[len(latencies)-1]; !math.IsNaN(latest) {
+		current = f.number(latest, unit, 1) + " " + unit
+	}
+	return fmt.Sprintf("%s  now %s  min/avg/max %s/%s/%s %s  p95 %s %s  jitter %s %s  loss %.1f%%  %d samples",
+		t.label, current,
+		f.number(stats.min, unit, 1), f.number(stats.mean, unit, 1), f.number(stats.max, unit, 1), unit,
+		f.number(stats.p95, unit, 1), unit, f.number(stats.jitter, unit, 1), unit,
+		stats.lossPercent(), stats.count)
+}
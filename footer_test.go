@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFooterSummarizesSinceStart(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	target := m.targets[0]
+	// A backfilled sample from before the start
+	m.update(latencyMsg{target, 500, clock.Now(), sampleMeta{}})
+	clock.Advance(time.Second)
+	m.started = clock.Now()
+	for _, latency := range []float64{10, math.NaN(), 30} {
+		m.update(latencyMsg{target, latency, clock.Now(), sampleMeta{}})
+		clock.Advance(time.Second)
+	}
+	footer := m.renderFooter()
+	for _, want := range []string{"now 30", "min/avg/max 10/20/30", "3 samples", "loss 33.3%"} {
+		if !strings.Contains(footer, want) {
+			t.Errorf("footer %q doesn't say %q", footer, want)
+		}
+	}
+
+	m.update(latencyMsg{target, 50, clock.Now(), sampleMeta{}})
+	if footer := m.renderFooter(); !strings.Contains(footer, "4 samples") {
+		t.Errorf("footer %q wasn't updated with the new sample", footer)
+	}
+}
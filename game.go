@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// Ports that game servers answer queries on unless told otherwise
+var gamePorts = map[string]string{
+	"source":    "27015",
+	"minecraft": "25565",
+}
+
+func isGame(address string) bool {
+	return strings.HasPrefix(address, "source://") || strings.HasPrefix(address, "minecraft://")
+}
+
+// Get the host and port that a game server address is queried on
+func gameServer(address string) (string, error) {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Port() == "" {
+		return net.JoinHostPort(parsed.Hostname(), gamePorts[parsed.Scheme]), nil
+	}
+	return parsed.Host, nil
+}
+
+// Time how long the game server of a source:// or minecraft:// address takes
+// to answer a query, the way the server browser of the game asks it, and
+// find out how many players it has. Servers that don't answer count as lost.
+func (m *model) gameCmd(ctx context.Context, t *target) tea.Cmd {
+	mark := m.mark
+	query := querySource
+	if strings.HasPrefix(t.address, "minecraft://") {
+		query = queryMinecraft
+	}
+	return func() tea.Msg {
+		server, err := gameServer(t.address)
+		if err != nil {
+			return errMsg{err}
+		}
+		sent := m.clock.Now()
+		latency, meta := query(ctx, probeDialer(mark), server)
+		return latencyMsg{t, latency, sent, meta}
+	}
+}
+
+// Query a Source engine server for its info over UDP, answering the
+// challenge that newer servers ask for first. The round trip of the last
+// query is timed.
+func querySource(ctx context.Context, dialer *net.Dialer, server string) (float64, sampleMeta) {
+	var meta sampleMeta
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		meta.errClass = classifyError(err)
+		return math.NaN(), meta
+	}
+	defer conn.Close()
+	meta.ip = conn.RemoteAddr().(*net.UDPAddr).IP.String()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	request := append([]byte{0xff, 0xff, 0xff, 0xff, 'T'}, "Source Engine Query\x00"...)
+	buffer := make([]byte, 1400)
+	for range 2 {
+		start := time.Now()
+		if _, err := conn.Write(request); err != nil {
+			meta.errClass = classifyError(err)
+			return math.NaN(), meta
+		}
+		n, err := conn.Read(buffer)
+		if err != nil {
+			meta.errClass = classifyError(err)
+			return math.NaN(), meta
+		}
+		latency := time.Since(start).Seconds() * 1000
+		reply := buffer[:n]
+		if len(reply) >= 9 && bytes.HasPrefix(reply, []byte{0xff, 0xff, 0xff, 0xff, 'A'}) {
+			// Ask again with the challenge
+			request = append(request[:25:25], reply[5:9]...)
+			continue
+		}
+		if len(reply) >= 5 && bytes.HasPrefix(reply, []byte{0xff, 0xff, 0xff, 0xff, 'I'}) {
+			meta.players, meta.maxPlayers = sourcePlayers(reply[6:])
+		}
+		return latency, meta
+	}
+	meta.errClass = "challenge"
+	return math.NaN(), meta
+}
+
+// Read the number of players out of the info of a Source engine server,
+// which follows its name, map, folder and game, and the id of the game
+func sourcePlayers(info []byte) (int, int) {
+	for range 4 {
+		end := bytes.IndexByte(info, 0)
+		if end < 0 {
+			return 0, 0
+		}
+		info = info[end+1:]
+	}
+	if len(info) < 4 {
+		return 0, 0
+	}
+	return int(info[2]), int(info[3])
+}
+
+// Ask a Minecraft server for its status over TCP, then time the ping that
+// the server list of the game sends after it
+func queryMinecraft(ctx context.Context, dialer *net.Dialer, server string) (float64, sampleMeta) {
+	var meta sampleMeta
+	lost := func(err error) (float64, sampleMeta) {
+		meta.errClass = classifyError(err)
+		return math.NaN(), meta
+	}
+	host, portText, _ := net.SplitHostPort(server)
+	port, _ := strconv.Atoi(portText)
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return lost(err)
+	}
+	defer conn.Close()
+	meta.ip = conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	reader := bufio.NewReader(conn)
+
+	// A handshake of an unknown protocol version, asking for the status
+	handshake := binary.AppendUvarint([]byte{0x00}, math.MaxUint32)
+	handshake = binary.AppendUvarint(handshake, uint64(len(host)))
+	handshake = append(handshake, host...)
+	handshake = binary.BigEndian.AppendUint16(handshake, uint16(port))
+	handshake = append(handshake, 0x01)
+	if _, err := conn.Write(append(minecraftPacket(handshake), minecraftPacket([]byte{0x00})...)); err != nil {
+		return lost(err)
+	}
+	status, err := readMinecraftPacket(reader, 0x00)
+	if err != nil {
+		return lost(err)
+	}
+	if length, n := binary.Uvarint(status); n > 0 && uint64(len(status)-n) >= length {
+		var parsed struct {
+			Players struct {
+				Max    int `json:"max"`
+				Online int `json:"online"`
+			} `json:"players"`
+		}
+		if json.Unmarshal(status[n:n+int(length)], &parsed) == nil {
+			meta.players, meta.maxPlayers = parsed.Players.Online, parsed.Players.Max
+		}
+	}
+
+	ping := binary.BigEndian.AppendUint64([]byte{0x01}, rand.Uint64())
+	start := time.Now()
+	if _, err := conn.Write(minecraftPacket(ping)); err != nil {
+		return lost(err)
+	}
+	pong, err := readMinecraftPacket(reader, 0x01)
+	if err != nil {
+		return lost(err)
+	}
+	latency := time.Since(start).Seconds() * 1000
+	if !bytes.Equal(pong, ping[1:]) {
+		meta.errClass = "mismatch"
+		return math.NaN(), meta
+	}
+	return latency, meta
+}
+
+// Prefix the id and data of a Minecraft packet with its length
+func minecraftPacket(data []byte) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(data))), data...)
+}
+
+// Read a Minecraft packet with the given id, returning its data
+func readMinecraftPacket(reader *bufio.Reader, id byte) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 || length > 1<<21 {
+		return nil, fmt.Errorf("minecraft packet of %d bytes", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(reader, packet); err != nil {
+		return nil, err
+	}
+	if packet[0] != id {
+		return nil, errors.New("unexpected minecraft packet")
+	}
+	return packet[1:], nil
+}
@@ -6,8 +6,8 @@ require (
 	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
 	github.com/charmbracelet/bubbletea v1.2.4 // direct
 	github.com/charmbracelet/lipgloss v1.0.0 // direct
-	github.com/charmbracelet/x/ansi v0.4.5 // indirect
-	github.com/charmbracelet/x/term v0.2.1 // indirect
+	github.com/charmbracelet/x/ansi v0.4.5 // direct
+	github.com/charmbracelet/x/term v0.2.1 // direct
 	github.com/erikgeiser/coninput v0.0.0-20211004153227-1c3628e74d0f // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
@@ -16,11 +16,11 @@ require (
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/muesli/ansi v0.0.0-20230316100256-276c6243b2f6 // indirect
 	github.com/muesli/cancelreader v0.2.2 // indirect
-	github.com/muesli/termenv v0.15.2 // indirect
+	github.com/muesli/termenv v0.15.2 // direct
 	github.com/prometheus-community/pro-bing v0.5.0 // direct
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/net v0.31.0 // indirect
+	golang.org/x/net v0.31.0 // direct
 	golang.org/x/sync v0.9.0 // indirect
-	golang.org/x/sys v0.27.0 // indirect
+	golang.org/x/sys v0.27.0 // direct
 	golang.org/x/text v0.20.0 // indirect
 )
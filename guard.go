@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"time"
+)
+
+const (
+	// Pinging hosts on the internet faster than this is refused, as ping does
+	// for users other than root
+	minPublicInterval = 200 * time.Millisecond
+	// Number of lost pings in a row after which a host that used to reply is
+	// suspected of dropping us
+	blockedStreak = 20
+)
+
+// Carrier-grade NAT addresses, such as those of Tailscale, are not on the
+// internet either
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Get the host that an address probes
+func probeHost(address string) string {
+	if isHTTP(address) || isTCP(address) || isUDP(address) || isDNS(address) || isGame(address) {
+		if parsed, err := url.Parse(address); err == nil {
+			return parsed.Hostname()
+		}
+	}
+	return address
+}
+
+// Check whether the host is on the internet. Names that can't be resolved
+// are assumed to be.
+func isPublic(host string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return true
+	}
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refuse to ping hosts on the internet faster than the minimum interval
+func checkInterval(addresses []string, interval time.Duration) error {
+	if interval >= minPublicInterval {
+		return nil
+	}
+	for _, address := range addresses {
+		if host := probeHost(address); isPublic(host) {
+			return fmt.Errorf("pinging %s every %v could be taken as abuse, the least -delay for hosts on the internet is %d, pass -i-know-what-im-doing to ping it anyway",
+				host, interval, minPublicInterval.Milliseconds())
+		}
+	}
+	return nil
+}
+
+// Lengthen the interval so that all targets together send no more than the
+// given number of probes per second
+func capInterval(targets int, interval time.Duration, maxPPS float64) time.Duration {
+	if maxPPS <= 0 {
+		return interval
+	}
+	// Rounded up to a millisecond, as rounding down would go over the cap
+	least := time.Duration(math.Ceil(float64(targets)/maxPPS*1000)) * time.Millisecond
+	return max(interval, least)
+}
+
+// Detect a target that replied at first but stopped replying altogether
+// while the others keep replying, as a host does when it starts dropping
+// probes it sees as a flood
+func (m *model) blocked(t *target) bool {
+	if t.lossStreak < blockedStreak || len(t.latencyData) <= t.lossStreak {
+		return false
+	}
+	replied := false
+	for _, latency := range t.latencyData[:len(t.latencyData)-t.lossStreak] {
+		replied = replied || !math.IsNaN(latency)
+	}
+	if !replied {
+		return false
+	}
+	for _, other := range m.targets {
+		if other != t && other.lossStreak == 0 {
+			return true
+		}
+	}
+	return len(m.targets) == 1 && m.interval < time.Second
+}
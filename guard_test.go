@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCapInterval(t *testing.T) {
+	tests := []struct {
+		targets  int
+		interval time.Duration
+		maxPPS   float64
+		want     time.Duration
+	}{
+		{1, time.Second, 0, time.Second},
+		{4, time.Second, 10, time.Second},
+		{20, time.Second, 10, 2 * time.Second},
+		{3, 100 * time.Millisecond, 7, 429 * time.Millisecond},
+		{2, 100 * time.Millisecond, 3, 667 * time.Millisecond},
+		// 333.33 ms rounded to the nearest millisecond would send a little
+		// over 3 a second
+		{1, 100 * time.Millisecond, 3, 334 * time.Millisecond},
+	}
+	for _, test := range tests {
+		got := capInterval(test.targets, test.interval, test.maxPPS)
+		if got != test.want {
+			t.Errorf("%d targets every %v capped at %g/s: got %v, want %v", test.targets, test.interval, test.maxPPS, got, test.want)
+		}
+		if test.maxPPS > 0 && float64(test.targets)/got.Seconds() > test.maxPPS {
+			t.Errorf("%d targets every %v send %g/s, over the cap of %g/s", test.targets, got, float64(test.targets)/got.Seconds(), test.maxPPS)
+		}
+	}
+}
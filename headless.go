@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// Run the model without bubbletea, as nothing is drawn and no keys are read
+// in headless mode. Commands run on goroutines of their own and their
+// messages are handled one at a time, as bubbletea does, until a command
+// quits or one of the signals arrives. Panics are kept as the crash of the
+// model, like runProgram does.
+func runHeadless(m *model, signals ...os.Signal) (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
+	msgs := make(chan tea.Msg)
+	run := func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		go func() {
+			select {
+			case msgs <- cmd():
+			case <-ctx.Done():
+			}
+		}()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			m.crash = &panicMsg{r, debug.Stack()}
+		}
+	}()
+
+	run(m.Init())
+	for {
+		var msg tea.Msg
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg = <-msgs:
+		}
+		switch msg := msg.(type) {
+		case nil:
+			continue
+		case tea.QuitMsg:
+			return nil
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				run(cmd)
+			}
+			continue
+		}
+		_, cmd := m.Update(msg)
+		run(cmd)
+		if m.mirror != nil {
+			// The view is only built for the mirror, which View publishes to
+			m.View()
+		}
+	}
+}
+
+// The signals that end a headless session, which include SIGHUP when the
+// charts are saved on exit, as with quitOnHangup
+func headlessSignals(resume bool) []os.Signal {
+	signals := []os.Signal{os.Interrupt, syscall.SIGTERM}
+	if resume {
+		signals = append(signals, syscall.SIGHUP)
+	}
+	return signals
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// How long a heartbeat may take before it is given up on
+const heartbeatTimeout = 10 * time.Second
+
+type (
+	heartbeatDueMsg struct{}
+	heartbeatMsg    struct{ err error }
+)
+
+func (m *model) heartbeatDueCmd(delay time.Duration) tea.Cmd {
+	return m.clock.Tick(delay, func(time.Time) tea.Msg {
+		return heartbeatDueMsg{}
+	})
+}
+
+// Post a heartbeat to the URL, which a dead man's switch service expects
+// regularly and alerts on when they stop coming
+func heartbeatCmd(ctx context.Context, url string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, heartbeatTimeout)
+		defer cancel()
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+		if err != nil {
+			return heartbeatMsg{err}
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return heartbeatMsg{err}
+		}
+		response.Body.Close()
+		if response.StatusCode/100 != 2 {
+			return heartbeatMsg{fmt.Errorf("got %s", response.Status)}
+		}
+		return heartbeatMsg{nil}
+	}
+}
+
+// Probing is healthy when no target is down and every target that isn't
+// suspended got a sample since the last heartbeat, so a stalled probe loop
+// stops the heartbeats as well
+func (m *model) healthy() bool {
+	if slices.ContainsFunc(m.incidents, (*incident).ongoing) {
+		return false
+	}
+	for i, t := range m.targets {
+		if !m.suspended(t) && t.counter <= m.heartbeatCounts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Send a heartbeat if probing is healthy, and schedule the next
+func (m *model) beat() tea.Cmd {
+	next := m.heartbeatDueCmd(m.heartbeatInterval)
+	healthy := m.healthy()
+	for i, t := range m.targets {
+		m.heartbeatCounts[i] = t.counter
+	}
+	if !healthy {
+		return next
+	}
+	return tea.Batch(heartbeatCmd(m.ctx, m.heartbeatURL), next)
+}
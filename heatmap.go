@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Greys like the smoke of SmokePing, light where few samples fall and dark
+// where most do, both visible on dark and light terminals
+var smokeGradient = []lipgloss.Color{"#e6e6e6", "#5a5a5a"}
+
+// Number of rows of latency buckets in the heatmap
+const heatmapHeight = 8
+
+// Gather the samples of the stream into the windows of the first aggregate,
+// as many zoomed columns of them as are displayed beside the labels of the
+// aggregates
+func (m *model) heatmapColumns(s *stream) [][]float64 {
+	size := m.aggregateCounts[0]
+	first := s.counter - len(s.latencyData)
+	start := (size - first%size) % size
+	var windows [][]float64
+	for i := start; i+size <= len(s.latencyData); i += size {
+		windows = append(windows, s.latencyData[i:i+size])
+	}
+	from, to := m.displayedRange(len(windows), size, (first+start)/size)
+	var columns [][]float64
+	for i := from; i < to; i += m.zoom {
+		var column []float64
+		for _, window := range windows[i:min(i+m.zoom, to)] {
+			column = append(column, window...)
+		}
+		columns = append(columns, column)
+	}
+	return columns[max(0, len(columns)-(m.windowWidth-m.aggregateLabelWidth())):]
+}
+
+// Render the spread of the latencies of each window as a column of buckets
+// on the logarithmic scale of the colors, shaded by how many of its replies
+// fall in each, with the median in the color of its latency. Windows
+// without replies are marked lost at the bottom.
+func (m *model) renderHeatmap(s *stream, sc scale) string {
+	columns := m.heatmapColumns(s)
+	rows := make([][]cell, heatmapHeight)
+	for y := range rows {
+		rows[y] = make([]cell, len(columns))
+	}
+	for x, column := range columns {
+		for y := range rows {
+			rows[y][x] = cell{" ", "", false}
+		}
+		received := replies(column)
+		if len(received) == 0 {
+			rows[heatmapHeight-1][x] = lostCell
+			continue
+		}
+		counts := make([]int, heatmapHeight)
+		for _, latency := range received {
+			counts[m.heatmapBucket(latency, sc)]++
+		}
+		for bucket, count := range counts {
+			if count > 0 {
+				rows[heatmapHeight-1-bucket][x] = gradientCell(smokeGradient, float64(count)/float64(len(received)))
+			}
+		}
+		median := percentile(received, 50)
+		medianCell := m.latencyToCell(median, sc)
+		if noColor {
+			medianCell = cell{"-", "", false}
+		}
+		rows[heatmapHeight-1-m.heatmapBucket(median, sc)][x] = medianCell
+	}
+	padding := strings.Repeat(" ", m.aggregateLabelWidth())
+	lines := make([]string, len(rows))
+	for y, row := range rows {
+		lines[y] = padding + renderRow(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *model) heatmapBucket(latency float64, sc scale) int {
+	ratio := math.Max(0, math.Min(1, m.latencyRatio(latency, sc)))
+	return min(heatmapHeight-1, int(ratio*heatmapHeight))
+}
+
+func (m *model) heatmapTitle(sc scale) string {
+	title := fmt.Sprintf("Heatmap of windows of %s", m.aggregateNames[0])
+	if sc.min == sc.max {
+		return title + ":"
+	}
+	return fmt.Sprintf("%s (%s to %s, logarithmic):", title, m.latencyFormat.format(sc.min), m.latencyFormat.format(sc.max))
+}
+
+func renderHeatmapLegend() string {
+	entries := make([]string, 0, 11)
+	for percent := 0; percent <= 100; percent += 10 {
+		entries = append(entries, fmt.Sprintf("%s %-4d",
+			renderRow([]cell{gradientCell(smokeGradient, float64(percent)/100)}), percent))
+	}
+	return lipgloss.JoinVertical(lipgloss.Top, "Heatmap Legend (% of the replies of a window):",
+		lipgloss.JoinHorizontal(lipgloss.Top, entries...))
+}
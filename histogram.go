@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Buckets of the latency histograms, spaced evenly on a logarithmic scale
+// from the floor up, a few decades of them
+const (
+	histogramFloor      = 0.01
+	histogramPerDecade  = 20
+	histogramBuckets    = 7 * histogramPerDecade
+	histogramHeight     = 6
+	histogramLabelSpace = 3
+)
+
+func histogramBucket(latency float64) int {
+	bucket := int(math.Floor(math.Log10(latency/histogramFloor) * histogramPerDecade))
+	return max(0, min(histogramBuckets-1, bucket))
+}
+
+// The latency at which the bucket starts
+func histogramEdge(bucket int) float64 {
+	return histogramFloor * math.Pow(10, float64(bucket)/histogramPerDecade)
+}
+
+// Count a reply in the histogram of the target
+func (t *target) countHistogram(latency float64) {
+	if math.IsNaN(latency) || latency <= 0 {
+		return
+	}
+	if t.histogram == nil {
+		t.histogram = make([]int, histogramBuckets)
+	}
+	t.histogram[histogramBucket(latency)]++
+}
+
+// Render the histogram of the replies of the focused target this session
+// as bars over its latencies, from the lowest bucket that holds any to the
+// highest, so that a distribution with two peaks, such as from Wi-Fi
+// retries or a route that flaps, stands out
+func (m *model) renderHistogram() string {
+	t := m.targets[m.focus]
+	low, high, total, peak := -1, -1, 0, 0
+	for bucket, count := range t.histogram {
+		if count == 0 {
+			continue
+		}
+		if low < 0 {
+			low = bucket
+		}
+		high = bucket
+		total += count
+		peak = max(peak, count)
+	}
+	if total == 0 {
+		return fmt.Sprintf("Histogram of %s: no replies yet", t.label)
+	}
+	buckets := high - low + 1
+	width := max(1, min(4, m.windowWidth/buckets))
+	sc := m.streamScale(t.stream)
+	glyphs := chartStyles["blocks"]
+	steps := len(glyphs) - 1
+	rows := make([][]cell, histogramHeight)
+	for bucket := low; bucket <= high; bucket++ {
+		count := t.histogram[bucket]
+		// Buckets with any reply are a step high, so they don't look empty
+		height := 0
+		if count > 0 {
+			height = max(1, int(math.Round(float64(count)/float64(peak)*float64(histogramHeight*steps))))
+		}
+		middle := math.Sqrt(histogramEdge(bucket) * histogramEdge(bucket+1))
+		color := m.latencyToCell(middle, sc).color
+		for y := range rows {
+			filled := max(0, min(steps, height-(histogramHeight-1-y)*steps))
+			for range width {
+				rows[y] = append(rows[y], cell{glyphs[filled], color, false})
+			}
+		}
+	}
+	lines := []string{fmt.Sprintf("Histogram of the %d replies of %s, the highest bar %d:", total, t.label, peak)}
+	for _, row := range rows {
+		lines = append(lines, renderRow(row))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, append(lines, m.histogramAxis(low, high, width))...)
+}
+
+// Label the edges of the buckets under the bars, as many as fit
+func (m *model) histogramAxis(low, high, width int) string {
+	var axis strings.Builder
+	for bucket := low; bucket <= high; bucket++ {
+		column := (bucket - low) * width
+		if column < axis.Len() {
+			continue
+		}
+		label := m.latencyFormat.format(histogramEdge(bucket))
+		if column+len(label) > (high-low+1)*width {
+			break
+		}
+		axis.WriteString(strings.Repeat(" ", column-axis.Len()))
+		axis.WriteString(label + strings.Repeat(" ", histogramLabelSpace))
+	}
+	return strings.TrimRight(axis.String(), " ")
+}
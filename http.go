@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The stages of an HTTP probe, each timed on its own
+var httpStages = []string{"dns", "connect", "tls", "ttfb"}
+
+// Get the latency of the sample to show, which is the time of the chosen
+// stage for HTTP probes. A stage that didn't happen took no time, which is
+// left blank rather than shown as lost. Recorded samples keep the total time.
+func (m *model) shownLatency(msg latencyMsg) float64 {
+	if m.httpPhase < 0 || msg.meta.stages == nil || math.IsNaN(msg.latency) {
+		return msg.latency
+	}
+	if stage := msg.meta.stages[m.httpPhase]; !math.IsNaN(stage) {
+		return stage
+	}
+	return 0
+}
+
+func isHTTP(address string) bool {
+	return strings.HasPrefix(address, "http://") || strings.HasPrefix(address, "https://")
+}
+
+// Request the URL over a fresh connection, timing each stage of the request.
+// The latency is the time until the whole response has been read. Stages that
+// didn't happen, such as DNS for an IP address, are NaN.
+func (m *model) httpCmd(ctx context.Context, t *target) tea.Cmd {
+	netns, mark := m.netns, m.mark
+	return func() tea.Msg {
+		var dnsStart, dnsDone, tlsStart, tlsDone, wrote, firstByte time.Time
+		var ip, connected string
+		// Addresses are dialed in parallel when the name resolves to several,
+		// so the connect stage is timed for the one the request was sent on
+		var connectMu sync.Mutex
+		connectStarts, connectDones := make(map[string]time.Time), make(map[string]time.Time)
+		trace := &httptrace.ClientTrace{
+			DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+			DNSDone:  func(httptrace.DNSDoneInfo) { dnsDone = time.Now() },
+			ConnectStart: func(_, address string) {
+				connectMu.Lock()
+				defer connectMu.Unlock()
+				connectStarts[address] = time.Now()
+			},
+			ConnectDone: func(_, address string, err error) {
+				if err != nil {
+					return
+				}
+				connectMu.Lock()
+				defer connectMu.Unlock()
+				connectDones[address] = time.Now()
+			},
+			TLSHandshakeStart: func() { tlsStart = time.Now() },
+			TLSHandshakeDone:  func(tls.ConnectionState, error) { tlsDone = time.Now() },
+			GotConn: func(info httptrace.GotConnInfo) {
+				connected = info.Conn.RemoteAddr().String()
+				if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+					ip = addr.IP.String()
+				}
+			},
+			WroteRequest:         func(httptrace.WroteRequestInfo) { wrote = time.Now() },
+			GotFirstResponseByte: func() { firstByte = time.Now() },
+		}
+		request, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, t.address, nil)
+		if err != nil {
+			return errMsg{err}
+		}
+		dial := func(ctx context.Context, network, address string) (conn net.Conn, err error) {
+			// The transport dials on a goroutine of its own
+			if nsErr := inNetns(netns, func() { conn, err = probeDialer(mark).DialContext(ctx, network, address) }); nsErr != nil {
+				return nil, nsErr
+			}
+			return conn, err
+		}
+		client := &http.Client{Transport: &http.Transport{
+			DisableKeepAlives: true, Proxy: http.ProxyFromEnvironment, DialContext: dial,
+		}}
+
+		sent, start := m.clock.Now(), time.Now()
+		var size int64
+		response, err := client.Do(request)
+		if err == nil {
+			size, err = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		if err != nil {
+			return latencyMsg{t, math.NaN(), sent, sampleMeta{ip: ip, errClass: classifyError(err)}}
+		}
+		latency := time.Since(start).Seconds() * 1000
+		connectMu.Lock()
+		connectStart, connectDone := connectStarts[connected], connectDones[connected]
+		connectMu.Unlock()
+		stages := []float64{
+			milliseconds(dnsStart, dnsDone),
+			milliseconds(connectStart, connectDone),
+			milliseconds(tlsStart, tlsDone),
+			milliseconds(wrote, firstByte),
+		}
+		transfer := time.Since(firstByte).Seconds() * 1000
+		return latencyMsg{t, latency, sent, sampleMeta{ip: ip, stages: stages, size: size, transfer: transfer}}
+	}
+}
+
+// Responses read faster than this count as read in this many milliseconds,
+// as the body of small responses arrives with its first byte
+const minTransfer = 1.0
+
+// Small or slow responses are red, turning green as they approach the
+// largest or fastest of the session
+var transferGradient = []lipgloss.Color{"#d23105", "#edd03a", "#31f199"}
+
+// Append the size and throughput of a response, keeping as many as there
+// are samples
+func (m *model) appendTransfer(t *target, latency float64, meta sampleMeta) {
+	size, throughput := math.NaN(), math.NaN()
+	if !math.IsNaN(latency) {
+		size = float64(meta.size)
+		throughput = size / (max(meta.transfer, minTransfer) / 1000)
+	}
+	t.sizeData = append(t.sizeData, size)
+	t.sizeData = t.sizeData[max(0, len(t.sizeData)-len(t.latencyData)):]
+	t.throughputData = append(t.throughputData, throughput)
+	t.throughputData = t.throughputData[max(0, len(t.throughputData)-len(t.latencyData)):]
+}
+
+// Keep the total time of the request while the charts show one stage, as
+// exports and statistics report the total
+func (m *model) appendTotal(t *target, latency float64) {
+	if m.httpPhase < 0 {
+		return
+	}
+	t.totalData = append(t.totalData, latency)
+	t.totalData = t.totalData[max(0, len(t.totalData)-len(t.latencyData)):]
+}
+
+// Get the round trip times of the kept samples of the target, the newest
+// last, which for HTTP probes are the total time even while the charts show
+// one stage
+func (t *target) roundTrips() []float64 {
+	if t.totalData != nil {
+		return t.totalData
+	}
+	return t.latencyData
+}
+
+// Show the size and throughput of the responses, catching servers that
+// answer quickly but with truncated or trickling responses
+func (m *model) renderTransfer(t *target) string {
+	return lipgloss.JoinVertical(lipgloss.Left,
+		m.renderTransferStream(t, t.sizeData, "Size", ""),
+		m.renderTransferStream(t, t.throughputData, "Throughput", "/s"))
+}
+
+func (m *model) renderTransferStream(t *target, kept []float64, title, unit string) string {
+	largest := 0.0
+	for _, value := range kept {
+		if !math.IsNaN(value) {
+			largest = math.Max(largest, value)
+		}
+	}
+	data := m.displayedColumns(kept, 1, t.counter-len(kept))
+	cells := make([]cell, len(data))
+	for i, value := range data {
+		if math.IsNaN(value) {
+			cells[i] = lostCell
+			continue
+		}
+		cells[i] = gradientCell(transferGradient, 1)
+		if largest > 0 {
+			cells[i] = gradientCell(transferGradient, value/largest)
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		fmt.Sprintf("%s (largest %s%s):", title, formatBytes(int64(largest)), unit), renderRow(cells))
+}
+
+func milliseconds(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return math.NaN()
+	}
+	return end.Sub(start).Seconds() * 1000
+}
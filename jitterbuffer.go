@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Sizes of jitter buffers in milliseconds to compare the configured one with
+var jitterBufferSizes = []float64{20, 40, 60, 100, 200}
+
+// Codecs add about this many milliseconds of delay on their own
+const codecDelay = 10
+
+// How packets of a call would have fared through a jitter buffer
+type playout struct {
+	buffer float64
+	// Percent of packets lost on the way, and that arrived too late to play
+	lost float64
+	late float64
+	// Milliseconds from mouth to ear
+	delay float64
+	mos   float64
+}
+
+// Play the samples out through a fixed jitter buffer, as if they were the
+// packets of a call. The one way delay is taken to be half the round trip,
+// and packets delayed more than the buffer holds beyond the quickest are
+// discarded.
+func simulateJitterBuffer(rtts []float64, buffer float64) playout {
+	p := playout{buffer: buffer}
+	base := math.Inf(1)
+	for _, rtt := range rtts {
+		if !math.IsNaN(rtt) {
+			base = math.Min(base, rtt/2)
+		}
+	}
+	if len(rtts) == 0 || math.IsInf(base, 1) {
+		p.lost, p.delay, p.mos = 100, math.NaN(), 1
+		return p
+	}
+	lost, late := 0, 0
+	for _, rtt := range rtts {
+		switch {
+		case math.IsNaN(rtt):
+			lost++
+		case rtt/2 > base+buffer:
+			late++
+		}
+	}
+	p.lost = 100 * float64(lost) / float64(len(rtts))
+	p.late = 100 * float64(late) / float64(len(rtts))
+	p.delay = base + buffer
+	p.mos = meanOpinionScore(p.delay+codecDelay, p.lost+p.late)
+	return p
+}
+
+// Estimate the mean opinion score of a call, from 1 for bad to 4.5 for the
+// best a phone call gets, with a simplified E-model
+func meanOpinionScore(delay, lossPercent float64) float64 {
+	r := 93.2 - delay/40
+	if delay >= 160 {
+		r = 93.2 - (delay-120)/10
+	}
+	r = max(0, min(100, r-2.5*lossPercent))
+	return 1 + 0.035*r + 0.000007*r*(r-60)*(100-r)
+}
+
+func describeMOS(mos float64) string {
+	switch {
+	case mos >= 4:
+		return "good"
+	case mos >= 3.6:
+		return "fair"
+	case mos >= 3.1:
+		return "poor"
+	}
+	return "bad"
+}
+
+// Show how a call would fare through jitter buffers of several sizes over
+// the samples in view of the focused target
+func (m *model) renderJitterBuffer() string {
+	t := m.targets[m.focus]
+	start, end := m.displayedRange(len(t.latencyData), 1, t.counter-len(t.latencyData))
+	samples := t.latencyData[start:end]
+	sizes := slices.Clone(jitterBufferSizes)
+	if !slices.Contains(sizes, m.jitterBuffer) {
+		sizes = append(sizes, m.jitterBuffer)
+		slices.Sort(sizes)
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  buffer ms\tlost %\tlate %\tdelay ms\tMOS\t")
+	for _, size := range sizes {
+		p := simulateJitterBuffer(samples, size)
+		marker := ""
+		if size == m.jitterBuffer {
+			marker = "<"
+		}
+		fmt.Fprintf(tw, "  %g\t%.1f\t%.1f\t%.0f\t%.1f %s\t%s\n", p.buffer, p.lost, p.late, p.delay, p.mos, describeMOS(p.mos), marker)
+	}
+	tw.Flush()
+	title := fmt.Sprintf("Jitter buffer of a call to %s over the %d samples in view:", t.label, len(samples))
+	return lipgloss.JoinVertical(lipgloss.Left, title, strings.TrimRight(b.String(), "\n"))
+}
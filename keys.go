@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The actions that keys can be bound to, with their default keys
+var defaultKeys = map[string][]string{
+	"quit":            {"q", "ctrl+c"},
+	"correlation":     {"c"},
+	"outages":         {"o"},
+	"debug":           {"d"},
+	"marker":          {"m"},
+	"annotate":        {"M"},
+	"search":          {"/"},
+	"next_outage":     {"n"},
+	"previous_outage": {"N"},
+	"live":            {"esc", "end"},
+	"oldest":          {"home"},
+	"scroll_back":     {"left", "h"},
+	"scroll_forward":  {"right", "l"},
+	"select":          {"v"},
+	"clear_selection": {"V"},
+	"selection_left":  {"shift+left", "<"},
+	"selection_right": {"shift+right", ">"},
+	"export":          {"e"},
+	"loss":            {"L"},
+	"stddev":          {"S"},
+	"chart":           {"G"},
+	"delta_colors":    {"D"},
+	"acknowledge":     {"A"},
+	"column_mode":     {"a"},
+	"scale_mode":      {"g"},
+	"reset_scale":     {"r"},
+	"speed_test":      {"t"},
+	"details":         {"i"},
+	"jitter_buffer":   {"b"},
+	"histogram":       {"H"},
+	"heatmap":         {"w"},
+	"sound":           {"s"},
+	"pause":           {"p"},
+	"zoom_in":         {"+", "="},
+	"zoom_out":        {"-"},
+	"next_target":     {"tab"},
+	"previous_target": {"shift+tab"},
+}
+
+// Keys of the -vim-keys preset, which replace the defaults of their actions.
+// h and l scroll by default.
+var vimKeys = map[string][]string{
+	"next_target":     {"j", "tab"},
+	"previous_target": {"k", "shift+tab"},
+}
+
+// A keymap maps keys, as named by bubbletea, to the actions bound to them
+type keymap map[string]string
+
+// Build the keymap from the defaults, the vim preset and the bindings of the
+// config, each replacing the keys of the actions it binds
+func newKeymap(vim bool, bindings map[string][]string) (keymap, error) {
+	actions := make(map[string][]string)
+	for action, keys := range defaultKeys {
+		actions[action] = keys
+	}
+	if vim {
+		for action, keys := range vimKeys {
+			actions[action] = keys
+		}
+	}
+	for action, keys := range bindings {
+		if _, ok := defaultKeys[action]; !ok {
+			return nil, fmt.Errorf("unknown action %s in [keys], expected one of %s", action, strings.Join(actionNames(), ", "))
+		}
+		actions[action] = keys
+	}
+	keys := make(keymap)
+	for _, action := range actionNames() {
+		for _, key := range actions[action] {
+			if other, ok := keys[key]; ok {
+				return nil, fmt.Errorf("%s is bound to both %s and %s", key, other, action)
+			}
+			keys[key] = action
+		}
+	}
+	return keys, nil
+}
+
+// Get the first key bound to the action
+func (k keymap) keyFor(action string) string {
+	var keys []string
+	for key, bound := range k {
+		if bound == action {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+func actionNames() []string {
+	names := make([]string, 0, len(defaultKeys))
+	for action := range defaultKeys {
+		names = append(names, action)
+	}
+	sort.Strings(names)
+	return names
+}
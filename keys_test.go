@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestKeymap(t *testing.T) {
+	tests := []struct {
+		vim      bool
+		bindings map[string][]string
+		key      string
+		action   string
+	}{
+		{key: "h", action: "scroll_back"},
+		{key: "l", action: "scroll_forward"},
+		{key: "L", action: "loss"},
+		{key: "j", action: ""},
+		{vim: true, key: "j", action: "next_target"},
+		{vim: true, key: "tab", action: "next_target"},
+		{vim: true, key: "l", action: "scroll_forward"},
+		// The config replaces the keys of the actions it binds
+		{bindings: map[string][]string{"loss": {"x"}}, key: "x", action: "loss"},
+		{bindings: map[string][]string{"loss": {"x"}}, key: "L", action: ""},
+	}
+	for _, test := range tests {
+		keys, err := newKeymap(test.vim, test.bindings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action := keys[test.key]; action != test.action {
+			t.Errorf("with vim %t and %v, %s is bound to %q, want %q", test.vim, test.bindings, test.key, action, test.action)
+		}
+	}
+	if _, err := newKeymap(false, map[string][]string{"loss": {"h"}}); err == nil {
+		t.Error("bound h to both scrolling back and the loss stream")
+	}
+}
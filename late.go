@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// How long past its timeout a probe keeps listening for its reply, unless
+// late replies are ignored
+const lateReplyWait = 10 * time.Second
+
+// What to do with a reply that comes after its probe timed out: ignore it,
+// mark the lost sample with it, or count it instead of the loss
+var lateReplyPolicies = []string{"ignore", "mark", "count"}
+
+func parseLateReplyPolicy(policy string) (string, error) {
+	if !slices.Contains(lateReplyPolicies, policy) {
+		return "", fmt.Errorf("unknown -late-replies %q, expected one of %s", policy, strings.Join(lateReplyPolicies, ", "))
+	}
+	return policy, nil
+}
+
+// A reply that came after its probe was counted as lost
+type lateReplyMsg struct {
+	target  *target
+	sent    time.Time
+	latency float64
+	ttl     int
+}
+
+// The loss of a probe that timed out but still listens for its reply, which
+// is sent on the channel if it comes
+type awaitingReplyMsg struct {
+	latencyMsg
+	reply <-chan lateReplyMsg
+}
+
+func awaitLateReply(reply <-chan lateReplyMsg) tea.Cmd {
+	return func() tea.Msg {
+		if msg, ok := <-reply; ok {
+			return msg
+		}
+		return nil
+	}
+}
+
+// Reconcile the lost sample of a late reply with it, rather than counting
+// the reply as a sample of its own. The sample is marked late in its
+// metadata and the recording, and with the count policy it takes the
+// latency of the reply, so the charts and statistics no longer count it as
+// lost. Outages, alerts and aggregates that already counted the loss stay
+// as they were.
+func (m *model) reconcileLateReply(msg lateReplyMsg) {
+	t := msg.target
+	i, found := slices.BinarySearchFunc(t.timestamps, msg.sent, func(at, sent time.Time) int {
+		return at.Compare(sent)
+	})
+	if !found || !math.IsNaN(t.latencyData[i]) {
+		// Discarded during warmup, or already scrolled out of memory
+		return
+	}
+	n := t.counter - len(t.latencyData) + i
+	meta, _ := t.metaOf(n)
+	meta.late = msg.latency
+	latency := math.NaN()
+	if m.lateReplies == "count" {
+		latency = msg.latency
+		meta.errClass, meta.ttl = "", msg.ttl
+		m.reviseLatency(t.stream, i, latency)
+		t.countHistogram(latency)
+	}
+	t.setMeta(n, meta)
+	t.lateReplies++
+	m.record(sampleRecord(t.address, msg.sent, latency, meta))
+	m.redraw = true
+}
+
+// Replace the ith latency of the stream, updating the scales and the loss
+// rates and deviations of the windows that hold it
+func (m *model) reviseLatency(s *stream, i int, latency float64) {
+	s.latencyData[i] = latency
+	if latency > 0 && latency < m.minLatency || latency > m.maxLatency {
+		m.minLatency = math.Min(m.minLatency, latency)
+		m.maxLatency = math.Max(m.maxLatency, latency)
+		m.gradientUpdate = true
+	}
+	if latency < s.minLatency || latency > s.maxLatency {
+		s.minLatency = math.Min(s.minLatency, latency)
+		s.maxLatency = math.Max(s.maxLatency, latency)
+		m.gradientUpdate = m.gradientUpdate || m.independentScales
+	}
+	for j := i; j < len(s.lossData) && j < i+m.lossWindow; j++ {
+		s.lossData[j] = lossRate(s.latencyData[max(0, j+1-m.lossWindow) : j+1])
+	}
+	for j := i; j < len(s.stddevData) && j < i+m.stddevWindow; j++ {
+		s.stddevData[j] = relativeStddev(s.latencyData[max(0, j+1-m.stddevWindow) : j+1])
+	}
+}
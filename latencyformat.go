@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// How latencies are shown to the user: in milliseconds, in microseconds, or
+// switching to microseconds below a millisecond, with a number of decimals
+type latencyFormat struct {
+	unit string
+	// Number of decimals, or -1 for the usual number of each place
+	precision int
+}
+
+func parseLatencyFormat(unit string, precision int) (latencyFormat, error) {
+	switch unit {
+	case "auto", "ms", "us":
+	default:
+		return latencyFormat{}, fmt.Errorf("unknown unit %q, expected auto, ms or us", unit)
+	}
+	if precision < -1 || precision > 6 {
+		return latencyFormat{}, fmt.Errorf("-precision must be between 0 and 6")
+	}
+	return latencyFormat{unit, precision}, nil
+}
+
+// Get the unit to show a latency in, or latencies up to it
+func (f latencyFormat) unitOf(latency float64) string {
+	if f.unit == "us" || (f.unit == "auto" && latency < 1) {
+		return "µs"
+	}
+	return "ms"
+}
+
+// Format a latency in milliseconds as a number in the unit, with the given
+// number of decimals unless the precision is set. Lost samples are a dash.
+func (f latencyFormat) number(latency float64, unit string, decimals int) string {
+	if math.IsNaN(latency) {
+		return "-"
+	}
+	if unit == "µs" {
+		latency *= 1000
+	}
+	if f.precision >= 0 {
+		decimals = f.precision
+	}
+	return strconv.FormatFloat(latency, 'f', decimals, 64)
+}
+
+// Format a latency in milliseconds with its unit
+func (f latencyFormat) format(latency float64) string {
+	unit := f.unitOf(latency)
+	return f.number(latency, unit, 1) + " " + unit
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// Number of sources shown in the inbound panel
+	inboundSources = 5
+	// Period the rate of inbound pings is computed over
+	inboundRatePeriod = time.Minute
+)
+
+type inboundSource struct {
+	address string
+	count   int
+	recent  []time.Time
+	last    time.Time
+}
+
+type inboundMsg struct {
+	source string
+	at     time.Time
+}
+
+// Listen for echo requests sent to this host. The kernel answers them, this
+// only watches, which needs a raw socket and so root or CAP_NET_RAW.
+func listenInbound() (*icmp.PacketConn, error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("listening for inbound pings needs root or CAP_NET_RAW: %w", err)
+	}
+	return conn, nil
+}
+
+// Wait for the next inbound echo request
+func inboundCmd(conn *icmp.PacketConn) tea.Cmd {
+	return func() tea.Msg {
+		buffer := make([]byte, 1500)
+		for {
+			n, source, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return nil
+			}
+			message, err := icmp.ParseMessage(1, buffer[:n])
+			if err == nil && message.Type == ipv4.ICMPTypeEcho {
+				return inboundMsg{source.String(), time.Now()}
+			}
+		}
+	}
+}
+
+func (m *model) trackInbound(msg inboundMsg) {
+	if m.inbound == nil {
+		m.inbound = make(map[string]*inboundSource)
+	}
+	source, ok := m.inbound[msg.source]
+	if !ok {
+		source = &inboundSource{address: msg.source}
+		m.inbound[msg.source] = source
+	}
+	source.count++
+	source.last = msg.at
+	source.recent = append(source.recent, msg.at)
+}
+
+func (m *model) renderInbound(now time.Time) string {
+	lines := []string{"Inbound pings:"}
+	if len(m.inbound) == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, append(lines, "  none yet")...)
+	}
+	sources := make([]*inboundSource, 0, len(m.inbound))
+	for _, source := range m.inbound {
+		for len(source.recent) > 0 && now.Sub(source.recent[0]) > inboundRatePeriod {
+			source.recent = source.recent[1:]
+		}
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].last.After(sources[j].last)
+	})
+	for _, source := range sources[:min(len(sources), inboundSources)] {
+		lines = append(lines, fmt.Sprintf("  %-15s  %6d pings  %3d/min  last %s",
+			source.address, source.count, len(source.recent), m.timeFormat.format(source.last)))
+	}
+	if len(sources) > inboundSources {
+		lines = append(lines, fmt.Sprintf("  and %d more", len(sources)-inboundSources))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var lossGradient = []lipgloss.Color{"#ffffff", "#ff0000"}
+
+// Append the loss rate over the most recent samples of the stream
+func (m *model) appendLossRate(s *stream) {
+	s.lossData = append(s.lossData, lossRate(s.latencyData[max(0, len(s.latencyData)-m.lossWindow):]))
+}
+
+func lossRate(window []float64) float64 {
+	lost := 0
+	for _, latency := range window {
+		if math.IsNaN(latency) {
+			lost++
+		}
+	}
+	return float64(lost) / float64(len(window))
+}
+
+func (m *model) renderLossStream(data []float64) string {
+	cells := make([]cell, len(data))
+	for i, rate := range data {
+		cells[i] = gradientCell(lossGradient, rate)
+	}
+	return renderRow(cells)
+}
+
+func renderLossLegend() string {
+	entries := make([]string, 0, 11)
+	for percent := 0; percent <= 100; percent += 10 {
+		entries = append(entries, fmt.Sprintf("%s %-4d",
+			renderRow([]cell{gradientCell(lossGradient, float64(percent)/100)}), percent))
+	}
+	return lipgloss.JoinVertical(lipgloss.Top, "Loss Legend (%):",
+		lipgloss.JoinHorizontal(lipgloss.Top, entries...))
+}
@@ -1,28 +1,447 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"math"
+	"net"
 	"os"
-	"sort"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	probing "github.com/prometheus-community/pro-bing"
+	"golang.org/x/net/icmp"
 )
 
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Addresses given by repeating the flag, or several at once separated by
+// commas
+type addressList []string
+
+func (l *addressList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *addressList) Set(value string) error {
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			*l = append(*l, address)
+		}
+	}
+	return nil
+}
+
 func main() {
-	address := flag.String("address", "", "IP address or URL to ping")
+	command := ""
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compact":
+			runCompact(os.Args[2:])
+			return
+		case "merge":
+			runMerge(os.Args[2:])
+			return
+		case "query":
+			runQuery(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "config", "debug":
+			// They check the settings of the config against the flags, so
+			// they run once the flags are defined
+			command = os.Args[1]
+		case "service":
+			runService(os.Args[2:])
+			return
+		case "analyze":
+			runAnalyze(os.Args[2:])
+			return
+		case "mirror":
+			runMirror(os.Args[2:])
+			return
+		case "scenario":
+			os.Args = append(os.Args[:1], scenarioArgs(os.Args[2:])...)
+		}
+	}
+
+	var addresses addressList
+	flag.Var(&addresses, "address", "IP address or URL to ping, may be repeated or list several separated by commas")
+	probeList := flag.String("probes", "icmp", "Probes to send to each address that isn't a URL, as a comma separated list of icmp, tcp:<port>, udp[:<port>], dns, dns:<name>, http and https")
+	qname := flag.String("qname", "example.com", "Name that dns probes look up")
+	modem := flag.String("modem", "", "ModemManager modem to show the signal of, such as 0 or any")
+	var wireguardFlags stringList
+	flag.Var(&wireguardFlags, "wireguard", "WireGuard interface to ping the peers of and watch the handshakes of, may be repeated")
+	var bisectFlags stringList
+	flag.Var(&bisectFlags, "bisect", "Configuration to compare, as <name>=<command switching to it>, given twice")
+	bisectPeriod := flag.Duration("bisect-period", 5*time.Minute, "How long to probe each configuration before switching")
+	scalePercentiles := flag.String("scale-percentiles", "", "Percentiles of the latencies shown live that the ends of the color scales stand for, as <low>-<high> such as 1-99, instead of the lowest and highest latency seen")
+	scaleReset := flag.Duration("scale-reset", 0, "Time between resets of the color scales to the latencies shown live, 0 to only reset them with R")
+	minLatency := flag.Float64("min-latency", 0, "Latency in milliseconds at the low end of the shared color scale, given with -max-latency to fix the scale")
+	maxLatency := flag.Float64("max-latency", 0, "Latency in milliseconds at the high end of the shared color scale, given with -min-latency to fix the scale")
+	scaleMode := flag.String("scale-mode", "shared", "Whether streams are colored on one shared scale or each on an independent scale of its own")
+	var dependencyFlags stringList
+	flag.Var(&dependencyFlags, "depends", "Dependency of targets, as <target>=<upstream> or *=<upstream> for every target, whose outages are blamed on the upstream target while it is down, may be repeated")
+	var scaleFlags stringList
+	flag.Var(&scaleFlags, "scale", "Color scale pinned to a stream, as <stream>=<min>-<max> in milliseconds, may be repeated")
+	var budgetFlags stringList
+	flag.Var(&budgetFlags, "budget", "Latency budget of a stage of HTTP probes, as <stage>=<milliseconds>, may be repeated")
+	var backfills stringList
+	flag.Var(&backfills, "backfill", "Log of `ping -D` to show as history before pinging its address, may be repeated")
+	diff := flag.String("diff", "", "Show the latency difference between two targets, as <address>,<address>")
 	delay := flag.Int("delay", 1000, "Delay between pings in milliseconds")
+	maxPPS := flag.Float64("max-pps", 20, "Most probes to send per second across all targets, the delay is lengthened to stay within it, 0 for no limit")
+	unsafe := flag.Bool("i-know-what-im-doing", false, "Allow a -delay below 200 ms for hosts on the internet")
 	groupSize := flag.Int("group", 32, "Number of samples to aggregate together")
+	aggregationList := flag.String("aggregation", "", "Comma separated aggregations, one or more rows each, shown in aggregate charts: order_statistics, min, max, mean, median, trimmed_mean, stddev, loss or p<percentile>")
 	aggregates := flag.Int("aggregates", 2, "Number of aggregate streams")
+	groupSpans := flag.String("groups", "", "Comma separated sizes of the groups of the aggregate charts, in samples or spans of time such as 10,1m,1h, instead of -group and -aggregates")
+	outageThreshold := flag.Int("outage-after", 3, "Number of consecutive losses that count as an outage")
+	jitterBuffer := flag.Float64("jitter-buffer", 60, "Size in milliseconds of the jitter buffer of the simulated call, see b")
+	streakAlert := flag.Int("streak-alert", 0, "Run the alert command when a target loses this many samples in a row, 0 to not")
+	alertCommand := flag.String("alert-cmd", "", "Shell command to run when an outage starts or ends")
+	zoom := flag.Int("zoom", 1, "Number of samples shown in each column")
+	column := flag.String("column", "worst", "What a column shows when it holds several samples: worst, median, best or mean")
+	coloring := flag.String("color", "absolute", "What the color of raw samples shows: absolute latency or delta, the change from the previous sample")
+	timeFormatName := flag.String("time-format", "local", "How to show timestamps: iso8601, local or unix")
+	timezone := flag.String("timezone", "", "IANA time zone of timestamps, defaults to the local one")
+	noiseFloor := flag.Float64("noise-floor", 0, "Differences in latency smaller than this many milliseconds are shown the same and ignored when comparing targets")
+	paletteList := flag.String("palette", "turbo", "Colors of latencies from low to high, one of turbo, viridis, magma and grayscale, or comma separated colors such as #466be3,#edd03a,#d23105")
+	unit := flag.String("unit", "auto", "Unit to show latencies in: ms, us, or auto to switch to us below a millisecond")
+	precision := flag.Int("precision", -1, "Number of decimals of latencies, -1 for the usual number of each place")
+	recordPath := flag.String("record", "", "File to append every sample to, for later use by the subcommands")
+	lowPower := flag.Bool("low-power", false, "Probe and render less often while on battery, only probing the focused target")
+	lossWindow := flag.Int("loss-window", 60, "Number of recent samples the loss rate is computed over, 0 hides it")
+	stddevWindow := flag.Int("stddev-window", 60, "Number of recent samples the rolling standard deviation is computed over, 0 disables it")
+	correlationWindow := flag.Int("correlation-window", 120, "Number of recent samples to correlate targets over")
+	sound := flag.Bool("sound", false, "Click on every reply of the focused target, pitched by its latency")
+	speedTestURL := flag.String("speedtest-url", "", "URL to download from when a speed test is started with t")
+	speedTestUploadURL := flag.String("speedtest-upload-url", "", "URL to upload to after the download of a speed test")
+	netns := flag.String("netns", "", "Network namespace to probe from, by name or path, Linux only")
+	heartbeatURL := flag.String("heartbeat", "", "URL to post a heartbeat to while probing is healthy, for a dead man's switch")
+	heartbeatInterval := flag.Duration("heartbeat-interval", time.Minute, "Time between heartbeats")
+	hops := flag.Int("hops", 0, "Number of hops on the way to the first target to ping as well, found by tracing the route at startup, needs root or CAP_NET_RAW")
+	hopsRefresh := flag.Duration("hops-refresh", 0, "Time between traces of the route to the hops, 0 to only trace it at startup")
+	var bucketFlags stringList
+	flag.Var(&bucketFlags, "metrics-buckets", "Upper bounds of the buckets of the round trip time histograms in milliseconds, as a comma separated list for every target or <target>=<list> for one, may be repeated")
+	mirrorPath := flag.String("mirror", "", "Unix socket to serve the view at, for pingback mirror to show in another terminal")
+	metricsAddress := flag.String("metrics-listen", "", "Address to serve Prometheus metrics at, such as :9123")
+	noColorFlag := flag.Bool("no-color", false, "Draw latencies as glyphs of increasing intensity instead of colors, as when NO_COLOR is set")
+	lateReplies := flag.String("late-replies", "ignore", "What to do with ICMP replies that come after their ping timed out: ignore them, mark the lost sample with them, or count them instead of the loss")
+	chartHeight := flag.Int("chart-height", 6, "Number of rows of the chart that plots latencies by height, toggled with G")
+	chartStyle := flag.String("chart-style", "braille", "Glyphs of the chart that plots latencies by height: braille or blocks")
+	colors := flag.String("colors", "auto", "Colors the terminal can show: truecolor, 256, 16, or auto to detect them")
+	colorblind := flag.Bool("colorblind", false, "Use colors that can be read without telling red from green, and mark lost samples by shape alone")
+	datacenter := flag.Bool("datacenter", false, "Tune the defaults for hosts in a datacenter: fast probes, TCP to SSH as well, a microsecond scale and groups of a second")
+	replayPath := flag.String("replay", "", "Recorded session to play back instead of pinging")
+	replaySpeed := flag.Float64("replay-speed", 10, "How many times faster than real time to play back -replay")
+	resumePath := flag.String("resume", "", "File to save the charts to on exit and to resume them from on start, when it exists")
+	exportPath := flag.String("export", "", "File to write every sample and aggregate row to on exit, as CSV or JSON by its extension")
+	headless := flag.Bool("headless", false, "Write every sample to stdout as a line of JSON instead of showing charts, for running without a terminal")
+	quiet := flag.Bool("quiet", false, "Write no samples to stdout in headless mode, such as when running as a service that records the session instead")
+	httpTransfer := flag.Bool("http-size", false, "Show the size and throughput of the responses of HTTP probes below their stages")
+	httpPhase := flag.String("http-phase", "total", "Time of HTTP probes to show in the charts, total or one of dns, connect, tls and ttfb")
+	cpuList := flag.String("cpus", "", "CPUs to pin pingback to, such as 0-3,8 for those of one NUMA node, Linux only")
+	gomaxprocs := flag.Int("gomaxprocs", 0, "Number of threads that run Go code at once, 0 for one per CPU pingback may run on")
+	gcPercent := flag.Int("gc-percent", defaultGCPercent(), "How much the heap grows in percent before garbage is collected, -1 to only collect at -memory-limit")
+	memoryLimit := flag.Int("memory-limit", 0, "Memory in MiB past which garbage is collected more often, 0 for no limit")
+	preallocate := flag.Int("preallocate", 0, "Number of samples of each target to make room for up front, so the history doesn't grow while probing")
+	mark := flag.Uint("mark", 0, "Firewall mark to set on probes, to route them with policy routing rules, Linux only")
+	slaPath := flag.String("sla", "", "CSV file of latency and loss objectives per target")
+	listen := flag.Bool("listen", false, "Show who is pinging this host, needs root or CAP_NET_RAW")
+	warmup := flag.Int("warmup", 0, "Number of probes per target to send and discard before showing samples")
+	vim := flag.Bool("vim-keys", false, "Use j and k to switch targets, as h and l scroll")
+	configPath := flag.String("config", defaultConfigPath(), "Config file to read")
+	profile := flag.String("profile", "", "Profile of the config to take flag values from")
+	switch command {
+	case "config":
+		runConfig(os.Args[2:])
+		return
+	case "debug":
+		runDebug(os.Args[2:])
+		return
+	}
 	flag.Parse()
 
-	if *address == "" {
-		fmt.Println("Usage: pingback -address=<IP_or_URL> [-delay=<milliseconds>] [-group=<groupSize>] [-aggregates=<number>]")
+	explicitConfig := false
+	flag.Visit(func(f *flag.Flag) {
+		explicitConfig = explicitConfig || f.Name == "config"
+	})
+	cfg, err := loadConfig(*configPath, explicitConfig)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// Flags override the profile, which overrides the defaults of the config
+	if *profile != "" {
+		settings, ok := cfg.profiles[*profile]
+		if !ok {
+			fmt.Printf("profile %q is not in the config\n", *profile)
+			os.Exit(1)
+		}
+		err = applySettings(fmt.Sprintf("profiles.%q", *profile), settings)
+	}
+	if err == nil {
+		err = applySettings("defaults", cfg.defaults)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *datacenter {
+		applyDatacenterDefaults()
+	}
+	if *colorblind {
+		applyColorblind()
+	}
+	if err := applyColors(*colors); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *noColorFlag || os.Getenv("NO_COLOR") != "" {
+		applyNoColor()
+	}
+	if len(addresses) == 0 {
+		addresses = cfg.addresses
+	}
+	probes, err := parseProbes(*probeList, *qname)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var expanded []string
+	labels := make(map[string]string)
+	groups := make(map[string]string)
+	for _, address := range addresses {
+		more, moreLabels, err := expandTemplate(address, cfg.variables)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for i, address := range more {
+			if strings.Contains(address, "://") || len(probes) == 1 {
+				address := address
+				if !strings.Contains(address, "://") {
+					address = probeAddress(address, probes[0])
+				}
+				if !slices.Contains(expanded, address) {
+					expanded = append(expanded, address)
+					labels[address] = moreLabels[i]
+				}
+				continue
+			}
+			for _, probe := range probes {
+				probed := probeAddress(address, probe)
+				if !slices.Contains(expanded, probed) {
+					expanded = append(expanded, probed)
+					labels[probed] = probe
+					groups[probed] = moreLabels[i]
+				}
+			}
+		}
+	}
+	if *modem != "" {
+		if err := setupModemSignal(context.Background(), *modem); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	wireguard := make(map[string][]*wireguardPeer)
+	for _, iface := range wireguardFlags {
+		peers, err := wireguardPeers(context.Background(), iface)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		wireguard[iface] = peers
+		for _, peer := range peers {
+			if peer.address != "" && !slices.Contains(expanded, peer.address) {
+				expanded = append(expanded, peer.address)
+				labels[peer.address] = peer.address
+				groups[peer.address] = iface
+			}
+		}
+	}
+	addresses = expanded
+	var replayed *replay
+	if *replayPath != "" {
+		replayed, err = loadReplay(*replayPath, *replaySpeed)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		addresses = replayed.addresses
+	}
+	var icmp icmpAccess
+	if replayed == nil {
+		probed, access, err := ensureICMP(addresses, *netns, *headless)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for i, address := range probed {
+			if address != addresses[i] {
+				labels[address], groups[address] = labels[addresses[i]], groups[addresses[i]]
+			}
+		}
+		addresses, icmp = probed, access
+	}
+	var routeDestination string
+	hopAddresses := make(map[string]int)
+	if *hops > 0 && len(addresses) > 0 && replayed == nil {
+		routeDestination = probeHost(addresses[0])
+		route, err := traceRoute(routeDestination, *hops)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		var hopList []string
+		for i, hop := range route {
+			if hop != "" && !slices.Contains(addresses, hop) && !slices.Contains(hopList, hop) {
+				hopList = append(hopList, hop)
+				hopAddresses[hop] = i
+				labels[hop] = fmt.Sprintf("hop %d", i+1)
+				groups[hop] = "route to " + routeDestination
+			}
+		}
+		addresses = append(hopList, addresses...)
+	}
+	budgets := make(map[string]float64)
+	for stage, budget := range cfg.budgets {
+		budgets[stage] = budget
+	}
+	for _, value := range budgetFlags {
+		if err := parseBudget(value, budgets); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	scales := make(map[string]scale)
+	for name, span := range cfg.scales {
+		if err := parsePinnedScale(name+"="+span, scales); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	for _, value := range scaleFlags {
+		if err := parsePinnedScale(value, scales); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	backfillPaths := make(map[string]string)
+	for _, path := range backfills {
+		address, err := backfillAddress(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+		backfillPaths[address] = path
+	}
+
+	if len(addresses) == 0 {
+		fmt.Println("Usage: pingback -address=<IP_or_URL> [-address=<IP_or_URL>...] [-diff=<address>,<address>] [-delay=<milliseconds>] [-group=<groupSize>] [-aggregates=<number>]")
+		os.Exit(1)
+	}
+	var diffPair []string
+	if *diff != "" {
+		diffPair = strings.Split(*diff, ",")
+		if len(diffPair) != 2 || !slices.Contains(addresses, diffPair[0]) || !slices.Contains(addresses, diffPair[1]) {
+			fmt.Println("-diff expects two comma separated addresses that are also given with -address")
+			os.Exit(1)
+		}
+	}
+	timeFormat, err := parseTimeFormat(*timeFormatName, *timezone)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	latencyFormat, err := parseLatencyFormat(*unit, *precision)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	palette, err := parsePalette(*paletteList)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	columnMode, ok := parseColumnMode(*column)
+	if !ok {
+		fmt.Println("-column expects worst, median, best or mean")
+		os.Exit(1)
+	}
+	if *coloring != "absolute" && *coloring != "delta" {
+		fmt.Println("-color expects absolute or delta")
+		os.Exit(1)
+	}
+	if *scaleMode != "shared" && *scaleMode != "independent" {
+		fmt.Println("-scale-mode expects shared or independent")
+		os.Exit(1)
+	}
+	var percentiles *percentileRange
+	if *scalePercentiles != "" {
+		p, err := parsePercentileRange(*scalePercentiles)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		percentiles = &p
+	}
+	var fixedScale *scale
+	if *minLatency != 0 || *maxLatency != 0 {
+		if *minLatency <= 0 || *maxLatency <= *minLatency {
+			fmt.Println("-min-latency and -max-latency are given together, with 0 < -min-latency < -max-latency")
+			os.Exit(1)
+		}
+		fixedScale = &scale{*minLatency, *maxLatency}
+	}
+	if *exportPath != "" {
+		if err := checkExportPath(*exportPath); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *scaleReset < 0 {
+		fmt.Println("-scale-reset can't be negative")
+		os.Exit(1)
+	}
+	if *jitterBuffer < 0 {
+		fmt.Println("-jitter-buffer can't be negative")
+		os.Exit(1)
+	}
+	if *streakAlert < 0 {
+		fmt.Println("-streak-alert can't be negative")
+		os.Exit(1)
+	}
+	if *noiseFloor < 0 {
+		fmt.Println("-noise-floor can't be negative")
+		os.Exit(1)
+	}
+	if *zoom < 1 || *zoom > maxZoom {
+		fmt.Printf("-zoom must be between 1 and %d\n", maxZoom)
 		os.Exit(1)
 	}
 	// if len(os.Getenv("DEBUG")) > 0 {
@@ -34,114 +453,1045 @@ func main() {
 	// defer f.Close()
 	// }
 
-	model := initialModel(*address, time.Duration(*delay)*time.Millisecond, *groupSize, *aggregates)
-	p := tea.NewProgram(&model)
+	var rec *recorder
+	if *recordPath != "" {
+		rec, err = openRecorder(*recordPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
 
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
+	names := defaultAggregations
+	if cfg.aggregation != nil {
+		names = cfg.aggregation
+	}
+	if *aggregationList != "" {
+		names = strings.Split(*aggregationList, ",")
+	}
+	aggregations, err := parseAggregations(names)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	interval := time.Duration(*delay) * time.Millisecond
+	if replayed != nil {
+		interval = replayed.interval
+	} else if !*unsafe {
+		if err := checkInterval(addresses, interval); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	capped := capInterval(len(addresses), interval, *maxPPS)
+	if replayed != nil {
+		capped = interval
+	}
+
+	aggregateCounts := geometricGroups(*groupSize, *aggregates)
+	var aggregateNames []string
+	for _, count := range aggregateCounts {
+		aggregateNames = append(aggregateNames, fmt.Sprint(count))
+		if err := checkGroupSize(count, aggregations); err != nil && *groupSpans == "" {
+			fmt.Printf("-group %d with -aggregates %d: %v\n", *groupSize, *aggregates, err)
+			os.Exit(1)
+		}
+	}
+	if *groupSpans != "" {
+		aggregateCounts, aggregateNames, err = parseGroups(*groupSpans, capped, aggregations)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	model := initialModel(addresses, labels, groups, diffPair, backfillPaths, capped, aggregateCounts, *correlationWindow, *outageThreshold, *alertCommand, *lowPower, timeFormat, *zoom, columnMode, *lossWindow, budgets, *warmup, *coloring == "delta", aggregations)
+	model.recorder = rec
+	model.latencyFormat = latencyFormat
+	model.palette = palette
+	model.aggregateNames = aggregateNames
+	model.noiseFloor = *noiseFloor
+	model.streakAlert = *streakAlert
+	model.jitterBuffer = *jitterBuffer
+	if *stddevWindow < 0 {
+		fmt.Println("-stddev-window can't be negative")
+		os.Exit(1)
+	}
+	model.stddevWindow = *stddevWindow
+	if *chartHeight < 1 {
+		fmt.Println("-chart-height must be at least 1")
+		os.Exit(1)
+	}
+	model.chartHeight = *chartHeight
+	model.chartStyle, err = parseChartStyle(*chartStyle)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	model.lateReplies, err = parseLateReplyPolicy(*lateReplies)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if capped > interval {
+		model.status = fmt.Sprintf("Pinging every %d ms instead of %d ms to stay within -max-pps %g", capped.Milliseconds(), *delay, *maxPPS)
+	}
+	model.independentScales = *scaleMode == "independent"
+	model.scaleReset = *scaleReset
+	model.scalePercentiles = percentiles
+	if *datacenter {
+		model.fixedScale = &datacenterScale
+	}
+	if fixedScale != nil {
+		model.fixedScale = fixedScale
+	}
+	if err := model.pinScales(scales); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	dependencies := make(map[string]string)
+	for name, upstream := range cfg.dependencies {
+		dependencies[name] = upstream
+	}
+	for _, value := range dependencyFlags {
+		if err := parseDependency(value, dependencies); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if err := model.linkDependencies(dependencies); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	model.keys, err = newKeymap(*vim, cfg.keys)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	model.sound = *sound
+	model.targetMetadata = cfg.targets
+	if replayed != nil {
+		model.replay = replayed
+		model.clock = replayed.clock
+		if model.targetMetadata == nil {
+			model.targetMetadata = make(map[string]map[string]string)
+		}
+	}
+	model.speedTestURL = *speedTestURL
+	model.speedTestUploadURL = *speedTestUploadURL
+	if *slaPath != "" {
+		model.objectives, err = loadObjectives(*slaPath)
+		if err == nil {
+			err = model.checkObjectives(*slaPath)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if len(bisectFlags) > 0 {
+		model.bisection, err = parseBisection(bisectFlags, *bisectPeriod)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	model.wireguardInterfaces = wireguardFlags
+	model.wireguard = wireguard
+	model.modem = *modem
+	if *netns != "" {
+		if err := checkNetns(*netns); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		model.netns = *netns
+	}
+	if err := tuneRuntime(*cpuList, *gomaxprocs, *gcPercent, *memoryLimit); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *preallocate < 0 {
+		fmt.Println("-preallocate must not be negative")
+		os.Exit(1)
+	}
+	if err := checkMark(*mark); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	model.mark = *mark
+	model.icmpPrivileged = icmp == icmpPrivileged
+	if *hopsRefresh < 0 {
+		fmt.Println("-hops-refresh must not be negative")
+		os.Exit(1)
+	}
+	model.routeDestination = routeDestination
+	model.routeRefresh = *hopsRefresh
+	model.hopTargets = make(map[int]*target)
+	for _, t := range model.targets {
+		if i, ok := hopAddresses[t.address]; ok {
+			model.hopTargets[i] = t
+		}
+	}
+	model.httpPhase = slices.Index(httpStages, *httpPhase)
+	model.httpTransfer = *httpTransfer
+	if model.httpPhase < 0 && *httpPhase != "total" {
+		fmt.Printf("-http-phase expects total or one of %s\n", strings.Join(httpStages, ", "))
+		os.Exit(1)
+	}
+	if *heartbeatInterval <= 0 {
+		fmt.Println("-heartbeat-interval must be positive")
+		os.Exit(1)
+	}
+	if *metricsAddress != "" {
+		defaults, perTarget, err := parseMetricBuckets(bucketFlags)
+		if err == nil {
+			model.metrics, err = newMetrics(model.targets, model.aggregateCounts, model.aggregateNames, defaults, perTarget)
+		}
+		if err == nil {
+			err = model.metrics.serve(*metricsAddress)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *mirrorPath != "" {
+		model.mirror, err = listenMirror(*mirrorPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer model.mirror.close()
+	}
+	model.heartbeatURL = *heartbeatURL
+	model.heartbeatInterval = *heartbeatInterval
+	if *listen {
+		model.inboundConn, err = listenInbound()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer model.inboundConn.Close()
+	}
+	model.player = findPlayer()
+	if *sound && model.player == nil {
+		fmt.Println("-sound needs paplay, aplay or afplay to play sounds")
+		os.Exit(1)
+	}
+	if *resumePath != "" {
+		if err := model.resumeCharts(*resumePath); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	model.reserve(*preallocate)
+	ctx, cancel := context.WithCancel(context.Background())
+	model.ctx = ctx
+	model.started = model.clock.Now()
+	if *headless {
+		// Nothing is drawn and no keys are read, so no terminal is needed
+		if !*quiet {
+			model.output = &recorder{os.Stdout, bufio.NewWriter(os.Stdout)}
+		}
+		err = runHeadless(&model, headlessSignals(*resumePath != "")...)
+	} else {
+		// Panics are caught by runProgram and recoverCmd instead, which keep
+		// the history
+		p := tea.NewProgram(&model, tea.WithMouseCellMotion(), tea.WithoutCatchPanics())
+		if *resumePath != "" {
+			quitOnHangup(p)
+		}
+		err = runProgram(p, &model)
+	}
+	stopped := model.clock.Now()
+	// End the probes and other work still in flight, which may take a while
+	// when packets are being black-holed
+	cancel()
+	if !model.waitForAlerts(shutdownTimeout) {
+		fmt.Println("Gave up waiting for alert commands to finish")
+	}
+	if *resumePath != "" && model.crash == nil {
+		if saveErr := model.saveCharts(*resumePath); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Error saving the charts: %v\n", saveErr)
+		}
+	}
+	if rec != nil {
+		if closeErr := rec.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if model.crash != nil {
+		fmt.Fprintf(os.Stderr, "pingback crashed: %v\n", model.crash.value)
+		dir, dumpErr := model.dumpCrash(stopped)
+		if dumpErr != nil {
+			fmt.Fprintf(os.Stderr, "Error saving the crash report: %v\n", dumpErr)
+		}
+		if dir != "" {
+			fmt.Fprintf(os.Stderr, "The history and a crash report are in %s, please attach the report to a bug report\n", dir)
+		}
+		os.Exit(2)
+	}
+	if err == nil && *exportPath != "" {
+		err = model.exportAll(*exportPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *headless && model.err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", model.err)
 		os.Exit(1)
 	}
+	if model.err == nil && !*headless {
+		model.printSummary(os.Stdout, stopped)
+	}
 }
 
 type model struct {
-	address            string
-	interval           time.Duration
-	initialized        bool
-	err                error
-	counter            int
-	latencyData        []float64
-	aggregateCounts    []int
+	targets         []*target
+	differentials   []*differential
+	interval        time.Duration
+	initialized     bool
+	err             error
+	aggregateCounts []int
+	// What the aggregate charts are labeled with, their group sizes or spans
+	aggregateNames []string
+	aggregations   []aggregation
+	// Which rows of each aggregate chart count lost samples
+	aggregateLoss     [][]bool
+	correlationWindow int
+	showCorrelation   bool
+	outageThreshold   int
+	alertCommand      string
+	incidents         []*incident
+	showOutages       bool
+	showDebug         bool
+	focus             int
+	lowPower          bool
+	onBattery         bool
+	lastView          string
+	lastViewTime      time.Time
+	// The view shown while paused, which samples keep arriving behind
+	paused bool
+	frozen string
+	// Whether anything shown changed since the view was last built
+	changed       bool
+	timeFormat    timeFormat
+	latencyFormat latencyFormat
+	// Colors of latencies from low to high
+	palette []lipgloss.Color
+	// Size in milliseconds of the jitter buffer of the simulated call
+	jitterBuffer     float64
+	showJitterBuffer bool
+	showHistogram    bool
+	showHeatmap      bool
+	// Length of the loss streaks that run the alert command
+	streakAlert int
+	// Differences in latency smaller than this, in milliseconds, are noise
+	noiseFloor         float64
+	events             []event
+	markerCount        int
+	interfaces         interfacesMsg
+	offset             int
+	zoom               int
+	columnMode         columnMode
+	lossWindow         int
+	stddevWindow       int
+	budgets            map[string]float64
+	deltaColors        bool
+	sound              bool
+	targetMetadata     map[string]map[string]string
+	showDetails        bool
+	speedTestURL       string
+	speedTestUploadURL string
+	speedTesting       bool
+	netns              string
+	// Dead man's switch to post heartbeats to, and the number of samples of
+	// each target at the last one
+	heartbeatURL      string
+	heartbeatInterval time.Duration
+	heartbeatCounts   []int
+	// The targets of the hops on the way to the first target, by their index
+	// in the route, and how often to trace it again
+	routeDestination string
+	routeRefresh     time.Duration
+	hopTargets       map[int]*target
+	// The stage of HTTP probes shown in the charts, -1 for the total time
+	httpPhase int
+	// Whether the size and throughput of HTTP responses are shown
+	httpTransfer bool
+	// Whether ICMP probes use raw sockets, as unprivileged ones aren't allowed
+	icmpPrivileged bool
+	// What to do with replies that come after their probe timed out
+	lateReplies string
+	// The cellular modem whose signal is read, if any, and its readings
+	modem         string
+	modemReadings []modemReading
+	// Firewall mark of the probes, 0 for none
+	mark                uint
+	wireguardInterfaces []string
+	wireguard           map[string][]*wireguardPeer
+	objectives          map[string]objective
+	bisection           *bisection
+	inboundConn         *icmp.PacketConn
+	mirror              *mirror
+	inbound             map[string]*inboundSource
+	player              []string
+	showLoss            bool
+	showStddev          bool
+	showChart           bool
+	chartHeight         int
+	chartStyle          string
+	redraw              bool
+	prompt              string
+	submit              func(string)
+	input               string
+	status              string
+	selection           *selection
+	recorder            *recorder
+	// Metrics served to Prometheus, if asked for
+	metrics *metrics
+	// Where samples are written in headless mode
+	output            *recorder
+	independentScales bool
+	// The shared scale when it doesn't follow the latencies seen
+	fixedScale *scale
+	keys       keymap
+	// Time between resets of the scales, and when they were last reset
+	scaleReset   time.Duration
+	scaleResetAt time.Time
+	// Percentiles of recent latencies spanned by the scales, if not the
+	// whole range, and the shared scale spanning them
+	scalePercentiles *percentileRange
+	percentileScale  scale
+	// Canceled on exit, ending probes and other work in flight
+	ctx     context.Context
+	started time.Time
+	// The panic that ended the program, if any
+	crash *panicMsg
+	// The recording played back instead of probing, if any
+	replay *replay
+	// Time of the scheduler and of samples, which tests can drive by hand
+	clock clock
+	// Alert commands still running, which are waited for on exit
+	alerts         *sync.WaitGroup
+	dragging       bool
+	renderedLegend string
+	gradientUpdate bool
+	windowWidth    int
+	minLatency     float64
+	maxLatency     float64
+}
+
+// A stream holds the samples and aggregates of a single latency series
+type stream struct {
+	label       string
+	counter     int
+	latencyData []float64
+	timestamps  []time.Time
+	lossData    []float64
+	stddevData  []float64
+	// The range of its own latencies, its scale when scales are independent
+	minLatency float64
+	maxLatency float64
+	// A scale of its own, instead of the one shared by all streams
+	pinned             *scale
 	aggregateData      [][][]float64
 	renderedAggregates []string
-	renderedLegend     string
-	gradientUpdate     bool
-	windowWidth        int
-	minLatency         float64
-	maxLatency         float64
+	// Its own scale spanning percentiles of its recent latencies
+	percentileScale scale
 }
 
-func initialModel(address string, interval time.Duration, groupSize, aggregates int) model {
-	aggregateCounts := make([]int, aggregates)
-	aggregateCounts[0] = groupSize
-	for i := range aggregateCounts[1:] {
-		aggregateCounts[i+1] = aggregateCounts[i] * groupSize
-	}
-	aggregateData := make([][][]float64, aggregates)
+type target struct {
+	address string
+	// The address several probes were attached to, probes of the same
+	// address are shown together
+	group      string
+	ip         string
+	lossStreak int
+	lossStart  time.Time
+	outage     *outage
+	offset     time.Duration
+	nextPing   time.Time
+	skew       time.Duration
+	backfill   *backfill
+	// The longest loss streak of the session, and when it started
+	longestStreak      int
+	longestStreakStart time.Time
+	// The target this one can't be reached without
+	upstream *target
+	// Where a hop on the route is pinged now, which changes with the route
+	// while its address stays the one it is recorded and labeled by
+	hop string
+	// Number of replies whose echoed send time disagreed with the clock
+	proxiedReplies int
+	// Number of replies that came after their sample was counted as lost
+	lateReplies int
+	// Number of the replies of the session in each bucket of latencies
+	histogram []int
+	// Number of probes left to discard before samples are kept
+	warmup int
+	// Timings of the stages of HTTP probes, and which are over budget
+	stageData     [][]float64
+	budgetStreaks []int
+	overBudget    []bool
+	slaBreached   bool
+	// Whether the objective was breached over the window ending at each
+	// sample, 1 if it was and NaN before a window was full
+	slaData []float64
+	// Summary of the samples since the start, as of a counter and number of
+	// kept samples
+	summary        summary
+	summaryCounter int
+	summaryKept    int
+	// Total time of the HTTP requests while the charts show one stage
+	totalData []float64
+	// Size of the HTTP responses in bytes, and their throughput in bytes per
+	// second
+	sizeData       []float64
+	throughputData []float64
+	// Number of probes that went to each address the target resolved to
+	ips map[string]int
+	// Alerts shown in the pane of the target until acknowledged
+	banners map[string]*banner
+	// The probe in flight, and how many probes got stuck and were abandoned
+	probeID     int
+	cancelProbe context.CancelFunc
+	probeSent   time.Time
+	restarts    int
+	// Metadata of the most recent samples, from the sample numbered metaFrom
+	meta     []sampleMeta
+	metaFrom int
+	*stream
+}
+
+// A differential is derived from two targets, showing how much slower the
+// minuend is than the subtrahend
+type differential struct {
+	minuend    *target
+	subtrahend *target
+	*stream
+}
+
+// Make a stream whose aggregate charts have the given numbers of rows
+func newStream(label string, aggregateRows []int) *stream {
+	aggregateData := make([][][]float64, len(aggregateRows))
 	for i := range aggregateData {
-		streamCount := 1 + int(math.Round(math.Log2(float64(aggregateCounts[i]))))
-		aggregateData[i] = make([][]float64, streamCount)
+		aggregateData[i] = make([][]float64, aggregateRows[i])
 	}
-	renderedAggregates := make([]string, aggregates)
-	return model{
-		initialized:        false,
-		aggregateCounts:    aggregateCounts,
-		aggregateData:      aggregateData,
-		renderedAggregates: renderedAggregates,
-		renderedLegend:     "",
-		address:            address,
-		interval:           interval,
+	return &stream{
+		label:              label,
 		minLatency:         math.MaxFloat64,
 		maxLatency:         0.001,
-		gradientUpdate:     true,
+		aggregateData:      aggregateData,
+		renderedAggregates: make([]string, len(aggregateRows)),
+	}
+}
+
+// Get the nth sample ever appended to the stream
+func (s *stream) sample(n int) float64 {
+	return s.latencyData[len(s.latencyData)-(s.counter-n)]
+}
+
+func (s *stream) sampleTime(n int) time.Time {
+	return s.timestamps[len(s.timestamps)-(s.counter-n)]
+}
+
+func initialModel(addresses []string, labels, groups map[string]string, diffPair []string, backfillPaths map[string]string, interval time.Duration, aggregateCounts []int, correlationWindow, outageThreshold int, alertCommand string, lowPower bool, timeFormat timeFormat, zoom int, columnMode columnMode, lossWindow int, budgets map[string]float64, warmup int, deltaColors bool, aggregations []aggregation) model {
+	aggregateRows := make([]int, len(aggregateCounts))
+	aggregateLoss := make([][]bool, len(aggregateCounts))
+	for i, count := range aggregateCounts {
+		aggregateRows[i] = aggregationRows(aggregations, count)
+		aggregateLoss[i] = lossRows(aggregations, count)
+	}
+	targets := make([]*target, len(addresses))
+	for i, address := range addresses {
+		label := address
+		if l, ok := labels[address]; ok {
+			label = l
+		}
+		targets[i] = &target{address: address, group: groups[address], warmup: warmup, stream: newStream(label, aggregateRows)}
+		if isHTTP(address) {
+			targets[i].stageData = make([][]float64, len(httpStages))
+			targets[i].budgetStreaks = make([]int, len(httpStages))
+			targets[i].overBudget = make([]bool, len(httpStages))
+		}
+		if path, ok := backfillPaths[address]; ok {
+			targets[i].backfill = &backfill{path: path}
+		}
+	}
+	var differentials []*differential
+	if diffPair != nil {
+		minuend := targets[slices.Index(addresses, diffPair[0])]
+		subtrahend := targets[slices.Index(addresses, diffPair[1])]
+		label := fmt.Sprintf("Difference %s - %s", minuend.address, subtrahend.address)
+		differentials = append(differentials,
+			&differential{minuend, subtrahend, newStream(label, aggregateRows)})
+	}
+	return model{
+		initialized:       false,
+		targets:           targets,
+		differentials:     differentials,
+		aggregateCounts:   aggregateCounts,
+		aggregations:      aggregations,
+		aggregateLoss:     aggregateLoss,
+		correlationWindow: correlationWindow,
+		showCorrelation:   true,
+		outageThreshold:   outageThreshold,
+		alertCommand:      alertCommand,
+		showOutages:       true,
+		lowPower:          lowPower,
+		timeFormat:        timeFormat,
+		zoom:              zoom,
+		columnMode:        columnMode,
+		lossWindow:        lossWindow,
+		budgets:           budgets,
+		deltaColors:       deltaColors,
+		showLoss:          lossWindow > 0,
+		renderedLegend:    "",
+		interval:          interval,
+		minLatency:        math.MaxFloat64,
+		maxLatency:        0.001,
+		gradientUpdate:    true,
 		// minLatency:  1,
 		// maxLatency:  10000,
 		windowWidth: 80,
+		ctx:         context.Background(),
+		alerts:      &sync.WaitGroup{},
+		clock:       wallClock{},
 	}
 }
 
 func (m *model) Init() tea.Cmd {
-	return m.pingCmd()
+	m.staggerTargets(m.clock.Now())
+	m.recordMetadata(m.clock.Now())
+	if m.replay != nil {
+		return recoverCmd(replayStepCmd())
+	}
+	cmds := make([]tea.Cmd, len(m.targets))
+	for i, t := range m.targets {
+		if t.backfill != nil {
+			cmds[i] = parseBackfillCmd(t)
+		} else {
+			cmds[i] = m.schedulePing(t)
+		}
+	}
+	cmds = append(cmds, m.checkInterfacesCmd(0))
+	if m.inboundConn != nil {
+		cmds = append(cmds, inboundCmd(m.inboundConn))
+	}
+	for _, iface := range m.wireguardInterfaces {
+		cmds = append(cmds, m.checkWireguardCmd(m.ctx, iface, 0))
+	}
+	if m.modem != "" {
+		cmds = append(cmds, m.checkModemCmd(m.ctx, m.modem, 0))
+	}
+	if m.bisection != nil {
+		cmds = append(cmds, m.bisection.switchCmd(m.ctx, 0))
+	}
+	if m.lowPower {
+		cmds = append(cmds, m.checkPowerCmd(0))
+	}
+	if len(m.hopTargets) > 0 && m.routeRefresh > 0 {
+		cmds = append(cmds, m.traceRouteCmd(m.routeDestination, m.maxHop()+1, m.routeRefresh))
+	}
+	if m.heartbeatURL != "" {
+		m.heartbeatCounts = make([]int, len(m.targets))
+		cmds = append(cmds, m.heartbeatDueCmd(m.heartbeatInterval))
+	}
+	return recoverCmd(tea.Batch(cmds...))
+}
+
+// Start a probe of the target with a deadline of its own, so a hung probe
+// only ever delays its own target
+func (m *model) pingCmd(t *target) tea.Cmd {
+	ctx, cancel := context.WithTimeout(m.ctx, m.interval)
+	t.probeID++
+	t.cancelProbe = cancel
+	t.probeSent = m.clock.Now()
+	var probe tea.Cmd
+	switch {
+	case isHTTP(t.address):
+		probe = m.httpCmd(ctx, t)
+	case isTCP(t.address):
+		probe = m.tcpCmd(ctx, t)
+	case isUDP(t.address):
+		probe = m.udpCmd(ctx, t)
+	case isDNS(t.address):
+		probe = m.dnsCmd(ctx, t)
+	case isGame(t.address):
+		probe = m.gameCmd(ctx, t)
+	default:
+		probe = m.icmpCmd(ctx, t)
+	}
+	if m.netns != "" {
+		netns, run := m.netns, probe
+		probe = func() tea.Msg {
+			var msg tea.Msg
+			if err := inNetns(netns, func() { msg = run() }); err != nil {
+				return errMsg{err}
+			}
+			return msg
+		}
+	}
+	id := t.probeID
+	return tea.Batch(func() tea.Msg {
+		return probeDoneMsg{t, id, probe()}
+	}, m.watchdogCmd(t, id))
 }
 
-func (m *model) pingCmd() tea.Cmd {
+func (m *model) icmpCmd(ctx context.Context, t *target) tea.Cmd {
+	// The address of a hop changes when the route does
+	address, netns := t.address, m.netns
+	if t.hop != "" {
+		address = t.hop
+	}
 	return func() tea.Msg {
-		pinger, err := probing.NewPinger(m.address)
-		if err != nil {
+		sent := m.clock.Now()
+		pinger := probing.New(address)
+		pinger.ResolveTimeout = m.interval
+		if err := pinger.Resolve(); err != nil {
+			// A slow or failing resolver is lost like a packet, but a name
+			// that doesn't exist is a mistake
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary) {
+				return latencyMsg{t, math.NaN(), sent, sampleMeta{errClass: "dns"}}
+			}
 			return errMsg{err}
 		}
 		pinger.Count = 1
+		pinger.SetMark(m.mark)
+		pinger.SetPrivileged(m.icmpPrivileged)
 		pinger.Timeout = m.interval
-		err = pinger.Run()
+		ttl := 0
+		// The round trip pro-bing reports is told by the send time the reply
+		// echoes, which is checked against the clock
+		var sentAt time.Time
+		proxied := false
+		pinger.OnSend = func(*probing.Packet) {
+			sentAt = time.Now()
+		}
+		pinger.OnRecv = func(packet *probing.Packet) {
+			ttl = packet.TTL
+			proxied = proxiedReply(time.Since(sentAt), packet.Rtt)
+		}
+		var err error
+		if m.lateReplies == "" || m.lateReplies == "ignore" {
+			err = pinger.RunWithContext(ctx)
+		} else {
+			// Keep listening past the deadline of the probe, reporting the
+			// loss at the deadline and the reply if it comes after all
+			pinger.Timeout += lateReplyWait
+			listenCtx, cancel := context.WithTimeout(m.ctx, pinger.Timeout)
+			done := make(chan error, 1)
+			go func() {
+				defer cancel()
+				if err := inNetns(netns, func() { done <- pinger.RunWithContext(listenCtx) }); err != nil {
+					done <- err
+				}
+			}()
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				reply := make(chan lateReplyMsg, 1)
+				go func() {
+					defer close(reply)
+					err := <-done
+					if rtts := pinger.Statistics().Rtts; err == nil && len(rtts) > 0 {
+						reply <- lateReplyMsg{t, sent, rtts[0].Seconds() * 1000, ttl}
+					}
+				}()
+				return awaitingReplyMsg{latencyMsg{t, math.NaN(), sent, sampleMeta{ip: pinger.IPAddr().String(), errClass: "timeout"}}, reply}
+			}
+		}
+		ip := pinger.IPAddr().String()
+		if err != nil && ctx.Err() != nil {
+			// Cut off by the deadline of the probe
+			return latencyMsg{t, math.NaN(), sent, sampleMeta{ip: ip, errClass: "timeout"}}
+		}
 		if err != nil {
 			return errMsg{err}
 		}
 		stats := pinger.Statistics()
 		if len(stats.Rtts) > 0 {
-			latency := stats.Rtts[0].Seconds() * 1000
-			m.initialized = true
-			return latencyMsg{latency}
+			return latencyMsg{t, stats.Rtts[0].Seconds() * 1000, sent, sampleMeta{ip: ip, ttl: ttl, proxied: proxied}}
 		}
-		return latencyMsg{math.NaN()}
+		return latencyMsg{t, math.NaN(), sent, sampleMeta{ip: ip, errClass: "timeout"}}
 	}
 }
 
 type (
-	latencyMsg struct{ latency float64 }
+	latencyMsg struct {
+		target  *target
+		latency float64
+		sent    time.Time
+		meta    sampleMeta
+	}
 	errMsg     struct{ err error }
+	pingDueMsg struct{ target *target }
+	// The result of a probe, which is dropped if the watchdog gave up on it
+	probeDoneMsg struct {
+		target *target
+		id     int
+		msg    tea.Msg
+	}
 )
 
+// Tell whether the message may change what is shown. Probe results are
+// dispatched to Update again, which tells by their contents.
+func changesView(msg tea.Msg) bool {
+	switch msg.(type) {
+	case pingDueMsg, probeDoneMsg, probeWatchdogMsg, powerMsg, interfacesMsg, bisectDueMsg, heartbeatDueMsg:
+		return false
+	}
+	return true
+}
+
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(panicMsg); ok {
+		m.crash = &msg
+		return m, tea.Quit
+	}
+	model, cmd := m.update(msg)
+	return model, recoverCmd(cmd)
+}
+
+func (m *model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.changed = m.changed || changesView(msg)
 	switch msg := msg.(type) {
+	case pingDueMsg:
+		if m.suspended(msg.target) {
+			now := m.clock.Now()
+			m.advanceSchedule(msg.target, now, now)
+			return m, m.schedulePing(msg.target)
+		}
+		return m, m.pingCmd(msg.target)
+	case probeDoneMsg:
+		if !m.finishProbe(msg.target, msg.id) {
+			return m, nil
+		}
+		return m.update(msg.msg)
+	case probeWatchdogMsg:
+		if !m.finishProbe(msg.target, msg.id) {
+			return m, nil
+		}
+		m.changed = true
+		msg.target.restarts++
+		m.status = fmt.Sprintf("Restarted the stuck probe of %s", msg.target.label)
+		return m.update(latencyMsg{msg.target, math.NaN(), msg.target.probeSent, sampleMeta{errClass: "stuck"}})
+	case awaitingReplyMsg:
+		model, cmd := m.update(msg.latencyMsg)
+		return model, tea.Batch(cmd, awaitLateReply(msg.reply))
+	case lateReplyMsg:
+		m.reconcileLateReply(msg)
+		return m, nil
+	case powerMsg:
+		if msg.onBattery != m.onBattery {
+			m.onBattery = msg.onBattery
+			m.lastView = ""
+			m.changed = true
+		}
+		return m, m.checkPowerCmd(powerCheckInterval)
+	case routeMsg:
+		return m, m.updateRoute(msg)
+	case heartbeatDueMsg:
+		return m, m.beat()
+	case heartbeatMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Heartbeat failed: %v", msg.err)
+		}
+		return m, nil
+	case backfillParsedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, tea.Quit
+		}
+		return m, m.startBackfill(msg)
+	case replayStepMsg:
+		return m, m.stepReplay()
+	case backfillStepMsg:
+		return m, m.stepBackfill(msg.target)
+	case interfacesMsg:
+		events := len(m.events)
+		m.trackInterfaces(msg, m.clock.Now())
+		m.changed = m.changed || len(m.events) != events
+		return m, m.checkInterfacesCmd(interfaceCheckInterval)
 	case latencyMsg:
-		m.processLatency(msg.latency)
-		return m, tea.Tick(m.interval, func(t time.Time) tea.Msg {
-			return m.pingCmd()()
-		})
+		if msg.target.warmup > 0 {
+			msg.target.warmup--
+			m.advanceSchedule(msg.target, msg.sent, m.clock.Now())
+			return m, m.schedulePing(msg.target)
+		}
+		now := m.clock.Now()
+		// Only the charts show the chosen phase of HTTP probes
+		latency := m.shownLatency(msg)
+		if !math.IsNaN(msg.latency) {
+			m.initialized = true
+		}
+		m.trackAddress(msg.target, msg.meta.ip, msg.sent)
+		m.processLatency(msg.target, latency, msg.sent)
+		m.observe(msg.target, msg.latency)
+		msg.target.countHistogram(msg.latency)
+		msg.target.appendMeta(msg.meta)
+		if msg.meta.proxied {
+			msg.target.proxiedReplies++
+		}
+		m.record(sampleRecord(msg.target.address, msg.sent, msg.latency, msg.meta))
+		outputCmd := m.writeOutput(sampleRecord(msg.target.address, msg.sent, msg.latency, msg.meta))
+		m.trackBisection(msg.latency, msg.sent)
+		m.advanceSchedule(msg.target, msg.sent, now)
+		var budgetCmd tea.Cmd
+		if msg.target.stageData != nil {
+			m.appendStages(msg.target, msg.meta.stages)
+			budgetCmd = m.trackBudgets(msg.target, msg.meta.stages, now)
+			m.appendTransfer(msg.target, msg.latency, msg.meta)
+			m.appendTotal(msg.target, msg.latency)
+		}
+		var clickCmd tea.Cmd
+		if msg.target == m.targets[m.focus] {
+			clickCmd = m.clickCmd(latency)
+		}
+		// The streak is tracked by trackOutage
+		outageCmd := m.trackOutage(msg.target, msg.latency, now)
+		return m, tea.Batch(outageCmd, m.trackStreak(msg.target, now),
+			budgetCmd, m.trackSLA(msg.target, now), clickCmd, outputCmd, m.schedulePing(msg.target))
+	case bisectSwitchedMsg:
+		return m, m.switchedBisection(msg, m.clock.Now())
+	case bisectDueMsg:
+		return m, m.bisection.switchCmd(m.ctx, 1-m.bisection.active)
+	case wireguardMsg:
+		return m, m.trackWireguard(msg, m.clock.Now())
+	case modemMsg:
+		return m, m.trackModem(msg)
+	case inboundMsg:
+		m.trackInbound(msg)
+		return m, inboundCmd(m.inboundConn)
+	case speedTestMsg:
+		return m, m.finishSpeedTest(msg, m.clock.Now())
 	case errMsg:
 		m.err = msg.err
 		return m, tea.Quit
 	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" || msg.String() == "q" {
+		m.lastView = ""
+		m.status = ""
+		if m.prompt != "" {
+			m.updatePrompt(msg)
+			return m, nil
+		}
+		switch m.keys[msg.String()] {
+		case "quit":
 			return m, tea.Quit
+		case "correlation":
+			m.showCorrelation = !m.showCorrelation
+		case "outages":
+			m.showOutages = !m.showOutages
+		case "debug":
+			m.showDebug = !m.showDebug
+		case "marker":
+			m.addMarker(m.clock.Now())
+		case "search":
+			m.startPrompt("/", m.search)
+		case "annotate":
+			m.startPrompt("Annotation: ", func(text string) {
+				m.annotate(text, m.clock.Now())
+			})
+		case "next_outage":
+			m.jumpToOutage(true)
+		case "previous_outage":
+			m.jumpToOutage(false)
+		case "live":
+			m.scrollTo(0)
+		case "pause":
+			m.paused = !m.paused
+			m.frozen = ""
+			if m.paused {
+				m.status = "Paused, samples are still collected, " + m.keys.keyFor("pause") + " resumes"
+			} else {
+				// Aggregates completed while paused weren't drawn
+				m.redraw = true
+			}
+		case "zoom_in":
+			m.zoomBy(0.5)
+		case "zoom_out":
+			m.zoomBy(2)
+		case "oldest":
+			m.scrollTo(math.MaxInt)
+		case "scroll_back":
+			m.scrollTo(m.offset + m.scrollStep())
+		case "scroll_forward":
+			m.scrollTo(m.offset - m.scrollStep())
+		case "select":
+			m.startSelection()
+		case "clear_selection":
+			m.selection = nil
+		case "loss":
+			m.showLoss = m.lossWindow > 0 && !m.showLoss
+		case "stddev":
+			m.showStddev = m.stddevWindow > 0 && !m.showStddev
+		case "chart":
+			m.showChart = !m.showChart
+		case "delta_colors":
+			m.deltaColors = !m.deltaColors
+		case "speed_test":
+			return m, m.startSpeedTest(m.clock.Now())
+		case "details":
+			m.showDetails = !m.showDetails
+		case "jitter_buffer":
+			m.showJitterBuffer = !m.showJitterBuffer
+		case "histogram":
+			m.showHistogram = !m.showHistogram
+		case "heatmap":
+			m.showHeatmap = len(m.aggregateCounts) > 0 && !m.showHeatmap
+		case "sound":
+			m.sound = m.player != nil && !m.sound
+		case "acknowledge":
+			m.acknowledgeBanners(m.targets[m.focus])
+		case "scale_mode":
+			m.independentScales = !m.independentScales
+			m.gradientUpdate = true
+		case "reset_scale":
+			m.resetScale(m.clock.Now())
+		case "column_mode":
+			m.columnMode = (m.columnMode + 1) % columnMode(len(columnModeNames))
+			m.redraw = true
+		case "selection_left":
+			m.moveSelection(-1)
+		case "selection_right":
+			m.moveSelection(1)
+		case "export":
+			if m.selection != nil {
+				name, err := m.exportSelection()
+				if err != nil {
+					m.status = "Export failed: " + err.Error()
+				} else {
+					m.status = "Exported selection to " + name
+				}
+			}
+		case "next_target":
+			m.focus = (m.focus + 1) % len(m.targets)
+			m.gradientUpdate = true
+		case "previous_target":
+			m.focus = (m.focus + len(m.targets) - 1) % len(m.targets)
+			m.gradientUpdate = true
 		}
+	case tea.MouseMsg:
+		m.updateMouse(msg)
 	case tea.WindowSizeMsg:
 		m.windowWidth = msg.Width
+		// The aggregate charts fit the width they were rendered at
+		m.redraw = true
 	}
 	return m, nil
 }
 
-func (m *model) processLatency(latency float64) {
-	if !math.IsNaN(latency) {
+func (m *model) processLatency(t *target, latency float64, at time.Time) {
+	if m.scaleReset > 0 && at.Sub(m.scaleResetAt) >= m.scaleReset {
+		m.resetScale(at)
+	}
+	// Phases of HTTP probes that took no time would collapse the logarithmic
+	// scale
+	if latency > 0 {
 		if latency < m.minLatency {
 			m.minLatency = latency
 			m.gradientUpdate = true
@@ -152,56 +1502,311 @@ func (m *model) processLatency(latency float64) {
 		}
 	}
 
-	m.latencyData = append(m.latencyData, latency)
+	m.appendLatency(t.stream, latency, at)
+	if m.scalePercentiles != nil && t.counter%percentileScaleRefresh == 0 {
+		m.updatePercentileScales()
+	}
+	if m.offset > 0 && t == m.targets[m.focus] {
+		m.offset++
+	}
+
+	for _, d := range m.differentials {
+		if d.minuend != t && d.subtrahend != t {
+			continue
+		}
+		for d.counter < min(d.minuend.counter, d.subtrahend.counter) {
+			m.appendLatency(d.stream, d.minuend.sample(d.counter)-d.subtrahend.sample(d.counter),
+				d.minuend.sampleTime(d.counter))
+		}
+	}
+}
 
-	if len(m.latencyData) > m.windowWidth*65536 {
-		m.latencyData = m.latencyData[1:]
+func (m *model) appendLatency(s *stream, latency float64, at time.Time) {
+	s.latencyData = append(s.latencyData, latency)
+	s.timestamps = append(s.timestamps, at)
+	// Differences between targets can be negative, which no scale covers
+	if latency > 0 && (latency < s.minLatency || latency > s.maxLatency) {
+		s.minLatency = math.Min(s.minLatency, latency)
+		s.maxLatency = math.Max(s.maxLatency, latency)
+		m.gradientUpdate = m.gradientUpdate || m.independentScales
 	}
-	m.counter += 1
+	if m.lossWindow > 0 {
+		m.appendLossRate(s)
+	}
+	if m.stddevWindow > 0 {
+		m.appendStddev(s)
+	}
+
+	limit := max(m.windowWidth*65536, maxGroupSize)
+	s.latencyData = trimHistory(s.latencyData, limit)
+	s.timestamps = trimHistory(s.timestamps, limit)
+	s.lossData = trimHistory(s.lossData, limit)
+	s.stddevData = trimHistory(s.stddevData, limit)
+	s.counter += 1
 	for i := range m.aggregateCounts {
-		if m.counter%m.aggregateCounts[i] == 0 && len(m.latencyData) > 0 {
-			aggregate := aggregate(m.latencyData[len(m.latencyData)-m.aggregateCounts[i]:])
-			for j := range m.aggregateData[i] {
-				m.aggregateData[i][j] = append(m.aggregateData[i][j], aggregate[j])
+		if s.counter%m.aggregateCounts[i] == 0 && len(s.latencyData) > 0 {
+			aggregate := aggregate(m.aggregations, s.latencyData[len(s.latencyData)-m.aggregateCounts[i]:])
+			for j := range s.aggregateData[i] {
+				s.aggregateData[i][j] = append(s.aggregateData[i][j], aggregate[j])
 			}
 		}
 
 	}
 }
 
-func (m *model) getDisplayableStreamEnd(stream []float64) []float64 {
-	return stream[max(0, len(stream)-m.windowWidth):]
+func (m *model) View() string {
+	view := m.renderView()
+	if m.mirror != nil {
+		m.mirror.publish(view)
+	}
+	return view
 }
 
-func (m *model) View() string {
+func (m *model) renderView() string {
 	if m.err != nil {
 		return fmt.Sprintf("Error: %v\n", m.err)
 	}
+	progress := m.backfillProgress()
 	if !m.initialized {
+		if progress != "" {
+			return progress
+		}
+		for _, t := range m.targets {
+			if t.warmup > 0 {
+				return "Warming up"
+			}
+		}
 		return "Waiting for first reply"
 	}
+	if m.paused && m.frozen != "" {
+		return m.frozen
+	}
+	// Rebuilding the view is most of the work, so skip it while nothing
+	// changed, such as between the samples of a long interval
+	if m.lastView != "" && !m.changed {
+		return m.lastView
+	}
+	if m.lowPowerActive() && m.lastView != "" && m.clock.Now().Sub(m.lastViewTime) < lowPowerRenderPeriod {
+		return m.lastView
+	}
+	m.changed = false
 
-	header := fmt.Sprintf("Pinging %s every %v ms\n",
-		m.address, m.interval.Milliseconds())
+	addresses := make([]string, len(m.targets))
+	streams := make([]*stream, 0, len(m.targets)+len(m.differentials))
+	for i, t := range m.targets {
+		addresses[i] = t.address
+		streams = append(streams, t.stream)
+	}
+	for _, d := range m.differentials {
+		streams = append(streams, d.stream)
+	}
 
-	renderedStreams := lipgloss.JoinVertical(lipgloss.Left,
-		"Raw Data:", m.renderStream(m.getDisplayableStreamEnd(m.latencyData)),
-	)
+	header := fmt.Sprintf("Pinging %s every %v ms",
+		strings.Join(addresses, ", "), m.currentInterval().Milliseconds())
+	if r := m.replay; r != nil {
+		header = fmt.Sprintf("Replaying %s at %gx, at %s", r.path, r.speed, m.timeFormat.format(r.clock.Now()))
+	}
+	if m.lowPowerActive() {
+		header += " (on battery, low power)"
+	}
+	if m.zoom > 1 {
+		header += fmt.Sprintf(" (%d samples per column, showing the %s)", m.zoom, m.columnMode)
+	}
+	if m.offset > 0 {
+		header += fmt.Sprintf(" (scrolled back to %s, esc returns to live)", m.timeFormat.format(m.viewCenter()))
+	}
+	if progress != "" {
+		header += "\n" + progress
+	}
+	header += "\n"
 
-	for i, agg := range m.aggregateData {
-		if m.counter%m.aggregateCounts[i] != 0 && !m.gradientUpdate {
+	renderedStreams := make([]string, len(streams))
+	for i, s := range streams {
+		label := ""
+		if len(streams) > 1 {
+			style := lipgloss.NewStyle().Bold(true)
+			if i == m.focus && len(m.targets) > 1 {
+				style = style.Reverse(true)
+			}
+			label = style.Render(s.label)
+			if i < len(m.targets) && m.suspended(m.targets[i]) {
+				label += " (suspended)"
+			}
+			if i < len(m.targets) && m.targets[i].group != "" && (i == 0 || m.targets[i-1].group != m.targets[i].group) {
+				label = lipgloss.JoinVertical(lipgloss.Left,
+					lipgloss.NewStyle().Bold(true).Underline(true).Render(m.targets[i].group), label)
+			}
+		}
+		if i < len(m.targets) {
+			if objective := m.renderObjective(m.targets[i]); objective != "" {
+				if label != "" {
+					label += "  "
+				}
+				label += objective
+			}
+		}
+		if s.pinned != nil {
+			if label != "" {
+				label += "  "
+			}
+			label += "Scale " + s.pinned.String()
+		}
+		if i < len(m.targets) && m.targets[i].stageData != nil && m.httpPhase >= 0 {
+			if label != "" {
+				label += "  "
+			}
+			label += "Showing " + strings.ToUpper(httpStages[m.httpPhase])
+		}
+		if i < len(m.targets) {
+			if banners := m.renderBanners(m.targets[i], m.clock.Now()); len(banners) > 0 {
+				if label != "" {
+					banners = append([]string{label}, banners...)
+				}
+				label = lipgloss.JoinVertical(lipgloss.Left, banners...)
+			}
+		}
+		renderedStreams[i] = m.renderStreamBlock(s, label)
+		if i < len(m.targets) && m.targets[i].slaData != nil {
+			renderedStreams[i] = lipgloss.JoinVertical(lipgloss.Left, renderedStreams[i], m.renderObjectiveBand(m.targets[i]))
+		}
+		if i < len(m.targets) && m.targets[i].stageData != nil {
+			renderedStreams[i] = lipgloss.JoinVertical(lipgloss.Left, renderedStreams[i], m.renderStages(m.targets[i]))
+			if m.httpTransfer {
+				renderedStreams[i] = lipgloss.JoinVertical(lipgloss.Left, renderedStreams[i], m.renderTransfer(m.targets[i]))
+			}
+		}
+	}
+
+	if m.gradientUpdate {
+		m.renderedLegend = m.renderScaleLegend()
+		m.gradientUpdate = false
+	}
+
+	sections := []string{header, lipgloss.JoinVertical(lipgloss.Top, renderedStreams...)}
+	if lane := m.renderEventLane(m.displayedTimes(m.targets[m.focus].stream)); lane != "" {
+		sections = append(sections, lane)
+	}
+	if hints := m.renderHints(); hints != "" {
+		sections = append(sections, hints)
+	}
+	if m.selection != nil {
+		sections = append(sections, m.renderSelection())
+	}
+	if m.showCorrelation && len(m.targets) > 1 {
+		sections = append(sections, m.renderCorrelations())
+	}
+	if m.showOutages && len(m.incidents) > 0 {
+		sections = append(sections, m.renderOutageLog(m.clock.Now()))
+	}
+	if m.bisection != nil {
+		sections = append(sections, m.renderBisection())
+	}
+	if len(m.wireguardInterfaces) > 0 {
+		sections = append(sections, m.renderWireguard(m.clock.Now()))
+	}
+	if m.modem != "" {
+		sections = append(sections, m.renderModem())
+	}
+	if m.inboundConn != nil {
+		sections = append(sections, m.renderInbound(m.clock.Now()))
+	}
+	if m.showDetails {
+		sections = append(sections, m.renderDetails())
+	}
+	if m.showJitterBuffer {
+		sections = append(sections, m.renderJitterBuffer())
+	}
+	if m.showHistogram {
+		sections = append(sections, m.renderHistogram())
+	}
+	if m.showDebug {
+		sections = append(sections, m.renderDebug())
+	}
+	sections = append(sections, m.renderedLegend, m.renderSpanLegend())
+	if m.deltaColors {
+		sections = append(sections, renderDeltaLegend())
+	}
+	if m.showLoss {
+		sections = append(sections, renderLossLegend())
+	}
+	if m.showStddev {
+		sections = append(sections, renderStddevLegend())
+	}
+	if m.showHeatmap {
+		sections = append(sections, renderHeatmapLegend())
+	}
+	if footer := m.renderFooter(); footer != "" {
+		sections = append(sections, footer)
+	}
+	if streaks := m.renderStreaks(); streaks != "" {
+		sections = append(sections, streaks)
+	}
+	if m.prompt != "" {
+		sections = append(sections, m.prompt+m.input)
+	} else if m.status != "" {
+		sections = append(sections, m.status)
+	}
+	m.redraw = false
+
+	m.lastView = lipgloss.JoinVertical(lipgloss.Top, sections...)
+	m.lastViewTime = m.clock.Now()
+	if m.paused {
+		m.frozen = m.lastView
+	}
+	return m.lastView
+
+}
+
+func (m *model) renderStreamBlock(s *stream, label string) string {
+	sc := m.streamScale(s)
+	raw := m.displayedColumns(s.latencyData, 1, s.counter-len(s.latencyData))
+	renderedRaw := m.renderStream(raw, sc)
+	if m.deltaColors {
+		renderedRaw = m.renderDeltaStream(raw)
+	}
+	title := "Raw Data:"
+	if m.showChart {
+		title, renderedRaw = m.chartTitle(sc), m.renderChart(raw, sc)
+	}
+	rows := []string{title, renderedRaw}
+	if m.selection != nil {
+		rows = append(rows, m.renderGuide(s, len(s.latencyData), 1, s.counter-len(s.latencyData), m.windowWidth))
+	}
+	rows = append(rows, m.renderTimeAxis(m.displayedTimes(s)))
+	renderedStreams := lipgloss.JoinVertical(lipgloss.Left, rows...)
+	if m.showLoss {
+		renderedStreams = lipgloss.JoinVertical(lipgloss.Left, renderedStreams,
+			fmt.Sprintf("Loss (last %d):", m.lossWindow),
+			m.renderLossStream(m.displayedColumns(s.lossData, 1, s.counter-len(s.lossData))))
+	}
+	if m.showStddev {
+		renderedStreams = lipgloss.JoinVertical(lipgloss.Left, renderedStreams,
+			m.stddevTitle(s),
+			m.renderStddevStream(m.displayedColumns(s.stddevData, 1, s.counter-len(s.stddevData))))
+	}
+	if label != "" {
+		renderedStreams = lipgloss.JoinVertical(lipgloss.Left, label, renderedStreams)
+	}
+
+	labelWidth := m.aggregateLabelWidth()
+	for i, agg := range s.aggregateData {
+		if s.counter%m.aggregateCounts[i] != 0 && !m.gradientUpdate && !m.redraw {
 			continue
 		}
 
-		renderedAggregate := "Aggregated " + fmt.Sprint(m.aggregateCounts[i]) + ":"
+		renderedAggregate := "Aggregated " + m.aggregateNames[i] + ":"
+		labels := aggregationRowLabels(m.aggregations, m.aggregateCounts[i])
 		for j, data := range agg {
-			if j == len(agg)-1 {
-				data = m.getDisplayableStreamEnd(data)
-				glyphs := make([]string, len(data))
+			// The newest columns that fit beside the label
+			data = m.displayedColumns(data, m.aggregateCounts[i], 0)
+			data = data[max(0, len(data)-(m.windowWidth-labelWidth)):]
+			label := aggregateLabelStyle.Width(labelWidth).Render(labels[j])
+			if m.aggregateLoss[i][j] {
+				cells := make([]cell, len(data))
 				anyDrop := false
 				for k, drops := range data {
 					if drops == 0 {
-						glyphs[k] = " "
+						cells[k] = cell{glyph: " "}
 					} else {
 						character := " "
 						if drops < 10 {
@@ -209,37 +1814,37 @@ func (m *model) View() string {
 						} else {
 							character = string(mapToAlphabet((drops - 10) / (float64(m.aggregateCounts[i]) - 10)))
 						}
-						glyphs[k] = lipgloss.NewStyle().
-							Background(lipgloss.Color("#600060")).Render(character)
+						cells[k] = cell{character, lostCell.color, true}
 						anyDrop = true
 					}
 				}
 				if anyDrop {
-					renderedStream := lipgloss.JoinHorizontal(lipgloss.Top, glyphs...)
+					renderedStream := label + renderRow(cells)
 					renderedAggregate = lipgloss.JoinVertical(
 						lipgloss.Top, renderedAggregate, renderedStream)
 				}
 			} else {
-				renderedStream := m.renderStream(m.getDisplayableStreamEnd(data))
+				renderedStream := label + m.renderStream(data, sc)
 				renderedAggregate = lipgloss.JoinVertical(
 					lipgloss.Top, renderedAggregate, renderedStream)
 			}
 		}
-		m.renderedAggregates[i] = renderedAggregate
+		s.renderedAggregates[i] = renderedAggregate
 	}
-	for _, agg := range m.renderedAggregates {
+	for i, agg := range s.renderedAggregates {
 		renderedStreams = lipgloss.JoinVertical(
 			lipgloss.Top, renderedStreams, agg)
+		if m.selection != nil && len(s.aggregateData[i]) > 0 && len(s.aggregateData[i][0]) > 0 {
+			guide := m.renderGuide(s, len(s.aggregateData[i][0]), m.aggregateCounts[i], 0, m.windowWidth-labelWidth)
+			renderedStreams = lipgloss.JoinVertical(
+				lipgloss.Top, renderedStreams, strings.Repeat(" ", labelWidth)+guide)
+		}
 	}
-
-	if m.gradientUpdate {
-		m.renderedLegend = lipgloss.JoinVertical(lipgloss.Top, "Latency Legend (ms):", m.renderLegend())
-		m.gradientUpdate = false
+	if m.showHeatmap {
+		renderedStreams = lipgloss.JoinVertical(lipgloss.Left, renderedStreams,
+			m.heatmapTitle(sc), m.renderHeatmap(s, sc))
 	}
-
-	return lipgloss.JoinVertical(lipgloss.Top, header,
-		renderedStreams, m.renderedLegend)
-
+	return renderedStreams
 }
 
 func mapToAlphabet(value float64) rune {
@@ -250,21 +1855,30 @@ func mapToAlphabet(value float64) rune {
 	}
 	return rune('a' + int(value*25)) // 25 = number of steps between 'a' and 'z'
 }
-func (m *model) renderStream(data []float64) string {
-	glyphs := make([]string, len(data))
+func (m *model) renderStream(data []float64, sc scale) string {
+	cells := make([]cell, len(data))
 	for i, lat := range data {
-		glyphs[i] = m.latencyToGlyph(lat)
+		cells[i] = m.latencyToCell(lat, sc)
 	}
-	return lipgloss.JoinHorizontal(lipgloss.Top, glyphs...)
+	return renderRow(cells)
+}
+
+func (m *model) latencyToGlyph(latency float64, sc scale) string {
+	return renderRow([]cell{m.latencyToCell(latency, sc)})
 }
 
-func (m *model) latencyToGlyph(latency float64) string {
+func (m *model) latencyToCell(latency float64, sc scale) cell {
 	if math.IsNaN(latency) {
-		return lipgloss.NewStyle().
-			Background(lipgloss.Color("#600060")).Render("X")
+		return lostCell
 	}
-	color := m.latencyToColor(latency)
-	return lipgloss.NewStyle().Foreground(color).Render("█")
+	// Phases of HTTP probes that didn't happen took no time
+	if latency == 0 {
+		return cell{" ", "", false}
+	}
+	if noColor {
+		return gradientCell(m.palette, m.latencyRatio(latency, sc))
+	}
+	return cell{"█", m.latencyToColor(latency, sc), false}
 }
 
 // Linear interpolation between two float64 values
@@ -274,18 +1888,26 @@ func lerp(a, b, t float64) float64 {
 
 // Linear interpolation between two lipgloss.Color values
 func lerpColor(colorA, colorB lipgloss.Color, t float64) lipgloss.Color {
-	r1, g1, b1, _ := colorA.RGBA()
-	r2, g2, b2, _ := colorB.RGBA()
+	r1, g1, b1 := hexRGB(colorA)
+	r2, g2, b2 := hexRGB(colorB)
 
-	r := int(lerp(float64(r1/256), float64(r2/256), t))
-	g := int(lerp(float64(g1/256), float64(g2/256), t))
-	b := int(lerp(float64(b1/256), float64(b2/256), t))
+	r := int(lerp(r1, r2, t))
+	g := int(lerp(g1, g2, t))
+	b := int(lerp(b1, b2, t))
 
 	return lipgloss.Color(fmt.Sprintf("#%02X%02X%02X", r, g, b))
 }
 
-// Get gradient color based on ratio
+// Get gradient color based on ratio, one of a few distinct ones on
+// terminals without true color
 func getGradientColor(colors []lipgloss.Color, ratio float64) lipgloss.Color {
+	if steps := terminalGradient(colors); steps != nil {
+		return steps[min(len(steps)-1, int(math.Max(0, ratio)*float64(len(steps))))]
+	}
+	return interpolateGradient(colors, ratio)
+}
+
+func interpolateGradient(colors []lipgloss.Color, ratio float64) lipgloss.Color {
 	if ratio <= 0 {
 		return colors[0]
 	}
@@ -299,33 +1921,89 @@ func getGradientColor(colors []lipgloss.Color, ratio float64) lipgloss.Color {
 }
 
 // Updated latencyToColor function
-func (m *model) latencyToColor(latency float64) lipgloss.Color {
-	if m.minLatency == m.maxLatency {
+func (m *model) latencyToColor(latency float64, sc scale) lipgloss.Color {
+	if sc.min == sc.max {
 		return lipgloss.Color("#00FF00") // Default to green
 	}
 
-	ratio := math.Log(latency/m.minLatency) / math.Log(m.maxLatency/m.minLatency)
+	return getGradientColor(m.palette, m.latencyRatio(latency, sc))
+}
 
-	gradientHexcodes := []string{
-		// "#30123b",
-		"#466be3",
-		"#29bbec",
-		"#31f199",
-		"#a3fd3d",
-		"#edd03a",
-		"#fb8022",
-		"#d23105",
-		"#7a0403",
+// Get where the latency falls on the scale, from 0 at its low end to 1 at
+// its high end
+func (m *model) latencyRatio(latency float64, sc scale) float64 {
+	if sc.min == sc.max {
+		return 0
 	}
-	gradientColors := make([]lipgloss.Color, len(gradientHexcodes))
-	for i, hexcode := range gradientHexcodes {
-		gradientColors[i] = lipgloss.Color(hexcode)
+	// Differences between targets can be negative
+	latency = math.Max(m.quantize(latency), sc.min)
+	ratio := math.Log(latency/sc.min) / math.Log(sc.max/sc.min)
+	// A scale down to 0 ms has no logarithm
+	if math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+		return 0
+	}
+	return ratio
+}
+
+// Round the latency to a multiple of the noise floor, so differences the
+// measurements can't tell apart look the same
+func (m *model) quantize(latency float64) float64 {
+	if m.noiseFloor <= 0 {
+		return latency
 	}
+	return math.Round(latency/m.noiseFloor) * m.noiseFloor
+}
+
+// The colors of latencies from low to high unless configured otherwise
+var defaultPalette = []lipgloss.Color{
+	// "#30123b",
+	"#466be3",
+	"#29bbec",
+	"#31f199",
+	"#a3fd3d",
+	"#edd03a",
+	"#fb8022",
+	"#d23105",
+	"#7a0403",
+}
+
+// Palettes that can be picked by name. The darkest end of magma is left
+// out, as it can't be told from the background of a dark terminal.
+var namedPalettes = map[string][]lipgloss.Color{
+	"turbo":     defaultPalette,
+	"viridis":   {"#440154", "#46327e", "#365c8d", "#277f8e", "#1fa187", "#4ac16d", "#a0da39", "#fde725"},
+	"magma":     {"#1c1044", "#4f127b", "#812581", "#b5367a", "#e55064", "#fb8761", "#fec287", "#fcfdbf"},
+	"grayscale": {"#3a3a3a", "#ffffff"},
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
 
-	return getGradientColor(gradientColors, ratio)
+// Parse a palette by name, or a comma separated list of colors such as
+// #466be3, from low latency to high, where an empty list is the default
+// palette
+func parsePalette(list string) ([]lipgloss.Color, error) {
+	if list == "" {
+		return defaultPalette, nil
+	}
+	if palette, ok := namedPalettes[list]; ok {
+		return palette, nil
+	}
+	var palette []lipgloss.Color
+	for _, color := range strings.Split(list, ",") {
+		color = strings.TrimSpace(color)
+		if !hexColorPattern.MatchString(color) {
+			return nil, fmt.Errorf("palette color %q is not of the form #rrggbb, and the palettes with names are turbo, viridis, magma and grayscale", color)
+		}
+		palette = append(palette, lipgloss.Color(color))
+	}
+	if len(palette) < 2 {
+		return nil, fmt.Errorf("a palette needs at least two colors")
+	}
+	return palette, nil
 }
 
-func (m *model) renderLegend() string {
+func (m *model) renderLegend(sc scale) string {
+	unit := m.legendUnit(sc)
 	// Number of gradient steps
 	steps := 90 - 1
 	// Collect legend entries
@@ -333,12 +2011,13 @@ func (m *model) renderLegend() string {
 	lengths := make([]int, steps+1)
 	for i := 0; i <= steps; i++ {
 		ratio := float64(i) / float64(steps)
-		latency := m.minLatency * math.Exp(ratio*math.Log(m.maxLatency/m.minLatency))
-		label := fmt.Sprintf("%.1f", latency)
-		if latency >= 100 {
-			label = fmt.Sprintf("%.0f", latency)
+		latency := sc.min * math.Exp(ratio*math.Log(sc.max/sc.min))
+		decimals := 1
+		if latency >= 100 || (unit == "µs" && latency >= 0.1) {
+			decimals = 0
 		}
-		entries[i] = m.latencyToGlyph(latency) + " " + label + " "
+		label := m.latencyFormat.number(latency, unit, decimals)
+		entries[i] = m.latencyToGlyph(latency, sc) + " " + label + " "
 		lengths[i] = 3 + len(label)
 	}
 
@@ -380,50 +2059,3 @@ func (m *model) renderLegend() string {
 
 	return lipgloss.JoinHorizontal(lipgloss.Left, rowsJoined...)
 }
-
-func aggregate(data []float64) []float64 {
-	innerData := make([]float64, len(data))
-	copy(innerData, data)
-	lost := 0
-	sort.Float64s(innerData)
-	for _, v := range innerData {
-		if math.IsNaN(v) {
-			lost++
-		}
-	}
-	innerData = append(innerData[lost:], innerData[:lost]...)
-	result := make([]float64, 0)
-	samples := math.Log2(float64(len(innerData)))
-	for i := 0; i < int(samples); i++ {
-		index := (int(math.Round(float64(i) / ((samples - 1) / (float64(len(innerData)) - 1)))))
-		result = append(result, innerData[index])
-	}
-	result = append(result, float64(lost))
-	return result
-}
-
-// func aggregate(data []float64) []float64 {
-// 	min := math.MaxFloat64
-// 	max := 0.0
-// 	sum := 0.0
-// 	count := 0.0
-// 	lost := 0.
-// 	for _, v := range data {
-// 		if !math.IsNaN(v) {
-// 			min = math.Min(min, v)
-// 			max = math.Max(max, v)
-// 			sum += v
-// 			count++
-// 		} else {
-// 			lost++
-// 		}
-// 	}
-// 	result := make([]float64, 0)
-// 	result = append(result, lost)
-// 	if count == 0 {
-// 		result = append(result, math.NaN(), math.NaN(), math.NaN())
-// 	} else {
-// 		result = append(result, min, sum/count, max)
-// 	}
-// 	return result
-// }
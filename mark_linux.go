@@ -0,0 +1,28 @@
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func checkMark(mark uint) error {
+	return nil
+}
+
+// Get a dialer control function that marks sockets with the firewall mark,
+// so policy routing rules such as `ip rule add fwmark` can route them
+func markControl(mark uint) func(network, address string, c syscall.RawConn) error {
+	if mark == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if controlErr := c.Control(func(fd uintptr) {
+			err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, int(mark))
+		}); controlErr != nil {
+			return controlErr
+		}
+		return err
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Firewall marks only exist on linux
+func checkMark(mark uint) error {
+	if mark == 0 {
+		return nil
+	}
+	return errors.New("firewall marks are only supported on Linux")
+}
+
+func markControl(mark uint) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func runMerge(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("o", "", "Session to write the merged sessions to")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback merge <session> <session>... -o <session>")
+		flags.PrintDefaults()
+	}
+	var paths []string
+	for len(args) > 0 {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) > 0 {
+			paths = append(paths, args[0])
+			args = args[1:]
+		}
+	}
+	if len(paths) < 2 || *output == "" {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	sessions := make([][]record, len(paths))
+	for i, path := range paths {
+		records, err := readSession(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sessions[i] = records
+	}
+	merged, renamed := merge(paths, sessions)
+	if err := writeSession(*output, merged); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	total := 0
+	for _, records := range sessions {
+		total += len(records)
+	}
+	fmt.Printf("Merged %d records from %d sessions into %d records in %s\n",
+		total, len(paths), len(merged), *output)
+	for _, line := range renamed {
+		fmt.Println(line)
+	}
+}
+
+// Merge sessions into one timeline, dropping records that several sessions
+// share. A target that was recorded by several sessions at the same time,
+// such as from different machines, is kept apart by suffixing it with the
+// name of its session.
+func merge(paths []string, sessions [][]record) ([]record, []string) {
+	type key struct {
+		event  string
+		target string
+		label  string
+		time   int64
+	}
+	seen := make(map[key]bool)
+	unique := make([][]record, len(sessions))
+	for i, records := range sessions {
+		for _, rec := range records {
+			k := key{rec.Event, rec.Target, rec.Label, rec.Time.UnixNano()}
+			if !seen[k] {
+				seen[k] = true
+				unique[i] = append(unique[i], rec)
+			}
+		}
+	}
+
+	type span struct{ first, last time.Time }
+	spans := make([]map[string]span, len(unique))
+	for i, records := range unique {
+		spans[i] = make(map[string]span)
+		for _, rec := range records {
+			if rec.Event != "" {
+				continue
+			}
+			s, ok := spans[i][rec.Target]
+			if !ok || rec.Time.Before(s.first) {
+				s.first = rec.Time
+			}
+			if !ok || rec.Time.After(s.last) {
+				s.last = rec.Time
+			}
+			spans[i][rec.Target] = s
+		}
+	}
+	overlapping := make(map[string]bool)
+	for i := range unique {
+		for j := i + 1; j < len(unique); j++ {
+			for target, a := range spans[i] {
+				if b, ok := spans[j][target]; ok && !a.first.After(b.last) && !b.first.After(a.last) {
+					overlapping[target] = true
+				}
+			}
+		}
+	}
+
+	sessionNames := sessionNames(paths)
+	var renamed []string
+	for target := range overlapping {
+		var names []string
+		for i := range paths {
+			if _, ok := spans[i][target]; ok {
+				names = append(names, target+"@"+sessionNames[i])
+			}
+		}
+		renamed = append(renamed, fmt.Sprintf("%s was recorded by several sessions at once, kept apart as %s",
+			target, strings.Join(names, ", ")))
+	}
+	sort.Strings(renamed)
+
+	var merged []record
+	for i, records := range unique {
+		for _, rec := range records {
+			if overlapping[rec.Target] {
+				rec.Target += "@" + sessionNames[i]
+			}
+			merged = append(merged, rec)
+		}
+	}
+	return merged, renamed
+}
+
+// Name each session by the end of its path without the extension, such as
+// a for a.jsonl, taking in as many directories as it takes to tell sessions
+// of the same name apart, such as hostA/session, and numbering sessions by
+// their place among the arguments when their paths are the same
+func sessionNames(paths []string) []string {
+	parts := make([][]string, len(paths))
+	depths := make([]int, len(paths))
+	for i, path := range paths {
+		path = filepath.ToSlash(filepath.Clean(path))
+		parts[i] = strings.Split(strings.TrimSuffix(path, filepath.Ext(path)), "/")
+		depths[i] = 1
+	}
+	names := make([]string, len(paths))
+	clashes := func(i int) bool {
+		for j := range names {
+			if i != j && names[i] == names[j] {
+				return true
+			}
+		}
+		return false
+	}
+	for deeper := true; deeper; {
+		for i := range paths {
+			names[i] = strings.Join(parts[i][len(parts[i])-depths[i]:], "/")
+		}
+		deeper = false
+		for i := range paths {
+			if clashes(i) && depths[i] < len(parts[i]) {
+				depths[i]++
+				deeper = true
+			}
+		}
+	}
+	numbered := make([]string, len(paths))
+	for i := range paths {
+		numbered[i] = names[i]
+		if clashes(i) {
+			numbered[i] += fmt.Sprintf("#%d", i+1)
+		}
+	}
+	return numbered
+}
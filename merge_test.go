@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSessionNames(t *testing.T) {
+	tests := []struct {
+		paths, names []string
+	}{
+		{[]string{"a.jsonl", "b.jsonl"}, []string{"a", "b"}},
+		{[]string{"hostA/session.jsonl", "hostB/session.jsonl"}, []string{"hostA/session", "hostB/session"}},
+		{[]string{"/srv/a/x/s.jsonl", "/srv/b/x/s.jsonl", "t.jsonl"}, []string{"a/x/s", "b/x/s", "t"}},
+		{[]string{"session.jsonl", "hostA/session.jsonl"}, []string{"session", "hostA/session"}},
+		{[]string{"s.jsonl", "./s.jsonl"}, []string{"s#1", "s#2"}},
+	}
+	for _, test := range tests {
+		if names := sessionNames(test.paths); !slices.Equal(names, test.names) {
+			t.Errorf("sessions %q were named %q, want %q", test.paths, names, test.names)
+		}
+	}
+}
+
+func TestMergeKeepsOverlappingTargetsApart(t *testing.T) {
+	rtt := 10.0
+	session := func(at ...time.Time) []record {
+		var records []record
+		for _, sent := range at {
+			records = append(records, record{Time: sent, Target: "example.com", RTT: &rtt})
+		}
+		return records
+	}
+	paths := []string{"hostA/session.jsonl", "hostB/session.jsonl", "later.jsonl"}
+	merged, renamed := merge(paths, [][]record{
+		session(testEpoch, testEpoch.Add(2*time.Second)),
+		session(testEpoch.Add(time.Second), testEpoch.Add(3*time.Second)),
+		// Once kept apart, the target is kept apart in every session
+		session(testEpoch.Add(time.Hour)),
+	})
+	var targets []string
+	for _, rec := range merged {
+		if !slices.Contains(targets, rec.Target) {
+			targets = append(targets, rec.Target)
+		}
+	}
+	slices.Sort(targets)
+	want := []string{"example.com@hostA/session", "example.com@hostB/session", "example.com@later"}
+	if !slices.Equal(targets, want) {
+		t.Errorf("merged into targets %q, want %q", targets, want)
+	}
+	if len(renamed) != 1 {
+		t.Errorf("reported %q, want the renamed target reported once", renamed)
+	}
+	if len(merged) != 5 {
+		t.Errorf("merged into %d records, want all 5", len(merged))
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Upper bounds of the buckets of the round trip time histogram unless
+// configured otherwise, in seconds as Prometheus expects
+var rttBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// Percentiles exported for the latest group of each aggregate
+var metricPercentiles = []float64{50, 90, 95, 99}
+
+type targetMetrics struct {
+	// The labels of the target's metrics
+	labels string
+	// Upper bounds of the buckets of its histogram, in seconds
+	bounds []float64
+	sent   int
+	lost   int
+	// Replies in each bucket, not counting those of the buckets below it
+	buckets []int
+	// Sum of the round trip times of the replies, in seconds
+	sum float64
+	// Round trip times of the latest samples, as many as the largest group
+	// holds, which the charts don't keep when showing a stage of HTTP probes
+	recent []float64
+	// Percentiles of the latest group of each aggregate, nil until it has
+	// one
+	percentiles [][]float64
+}
+
+// Metrics of every target for Prometheus to scrape, which are written by the
+// model and read by the server on a goroutine of its own
+type metrics struct {
+	mu      sync.Mutex
+	targets []*targetMetrics
+	groups  []int
+	// The names the groups were given with -groups, such as 1m
+	names []string
+}
+
+// Parse the upper bounds of histogram buckets, given as a comma separated
+// list of milliseconds, into seconds. The milliseconds are scaled as decimals,
+// so the bounds are exported as they were given.
+func parseBuckets(value string) ([]float64, error) {
+	var bounds []float64
+	for _, field := range strings.Split(value, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field)+"e-3", 64)
+		if err != nil || bound <= 0 || (len(bounds) > 0 && bound <= bounds[len(bounds)-1]) {
+			return nil, fmt.Errorf("buckets %q are not an ascending list of milliseconds, such as 0.1,1,10", value)
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
+// Parse the buckets of -metrics-buckets, each either the buckets of every
+// target or those of one target given as <target>=<buckets>
+func parseMetricBuckets(values []string) (defaults []float64, perTarget map[string][]float64, err error) {
+	defaults = rttBuckets
+	perTarget = make(map[string][]float64)
+	for _, value := range values {
+		// Bounds have no =, but URLs may
+		i := strings.LastIndex(value, "=")
+		bounds, err := parseBuckets(value[i+1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		if i < 0 {
+			defaults = bounds
+		} else {
+			perTarget[value[:i]] = bounds
+		}
+	}
+	return defaults, perTarget, nil
+}
+
+// Make the metrics of the targets, with the buckets of each target named by
+// its address, label or group, and the default buckets for the rest
+func newMetrics(targets []*target, groups []int, names []string, defaults []float64, perTarget map[string][]float64) (*metrics, error) {
+	x := &metrics{groups: groups, names: names}
+	used := make(map[string]bool)
+	for _, t := range targets {
+		bounds := defaults
+		for _, name := range []string{t.address, t.label, t.group} {
+			if b, ok := perTarget[name]; ok && name != "" {
+				bounds = b
+				used[name] = true
+				break
+			}
+		}
+		x.targets = append(x.targets, &targetMetrics{
+			labels:      fmt.Sprintf(`target="%s",label="%s"`, labelEscaper.Replace(t.address), labelEscaper.Replace(t.label)),
+			bounds:      bounds,
+			buckets:     make([]int, len(bounds)+1),
+			percentiles: make([][]float64, len(groups)),
+		})
+	}
+	for name := range perTarget {
+		if !used[name] {
+			return nil, fmt.Errorf("-metrics-buckets %s matches no target", name)
+		}
+	}
+	return x, nil
+}
+
+// Serve the metrics at /metrics, failing right away if the address can't be
+// listened on
+func (x *metrics) serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", x)
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// Count a sample of the target, and compute the percentiles of the groups it
+// completes
+func (m *model) observe(t *target, latency float64) {
+	if m.metrics == nil {
+		return
+	}
+	i := slices.Index(m.targets, t)
+	m.metrics.mu.Lock()
+	defer m.metrics.mu.Unlock()
+	tm := m.metrics.targets[i]
+	tm.sent++
+	if math.IsNaN(latency) {
+		tm.lost++
+	} else {
+		seconds := latency / 1000
+		tm.buckets[sort.SearchFloat64s(tm.bounds, seconds)]++
+		tm.sum += seconds
+	}
+	if len(m.metrics.groups) > 0 {
+		tm.recent = trimHistory(append(tm.recent, latency), slices.Max(m.metrics.groups))
+	}
+	for i, size := range m.metrics.groups {
+		if t.counter%size != 0 || len(tm.recent) < size {
+			continue
+		}
+		group := replies(tm.recent[len(tm.recent)-size:])
+		values := make([]float64, len(metricPercentiles))
+		for j, p := range metricPercentiles {
+			values[j] = percentile(group, p) / 1000
+		}
+		tm.percentiles[i] = values
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (x *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	number := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	fmt.Fprintln(w, "# HELP pingback_probes_total Probes sent to the target.")
+	fmt.Fprintln(w, "# TYPE pingback_probes_total counter")
+	for _, tm := range x.targets {
+		fmt.Fprintf(w, "pingback_probes_total{%s} %d\n", tm.labels, tm.sent)
+	}
+	fmt.Fprintln(w, "# HELP pingback_lost_total Probes to the target that were lost.")
+	fmt.Fprintln(w, "# TYPE pingback_lost_total counter")
+	for _, tm := range x.targets {
+		fmt.Fprintf(w, "pingback_lost_total{%s} %d\n", tm.labels, tm.lost)
+	}
+
+	fmt.Fprintln(w, "# HELP pingback_rtt_seconds Round trip times of the replies of the target.")
+	fmt.Fprintln(w, "# TYPE pingback_rtt_seconds histogram")
+	for _, tm := range x.targets {
+		count := 0
+		for i, bound := range tm.bounds {
+			count += tm.buckets[i]
+			fmt.Fprintf(w, "pingback_rtt_seconds_bucket{%s,le=\"%s\"} %d\n", tm.labels, number(bound), count)
+		}
+		count += tm.buckets[len(tm.bounds)]
+		fmt.Fprintf(w, "pingback_rtt_seconds_bucket{%s,le=\"+Inf\"} %d\n", tm.labels, count)
+		fmt.Fprintf(w, "pingback_rtt_seconds_sum{%s} %s\n", tm.labels, number(tm.sum))
+		fmt.Fprintf(w, "pingback_rtt_seconds_count{%s} %d\n", tm.labels, count)
+	}
+
+	fmt.Fprintln(w, "# HELP pingback_aggregate_rtt_seconds Percentiles of the round trip times of the latest group of samples of each aggregate.")
+	fmt.Fprintln(w, "# TYPE pingback_aggregate_rtt_seconds gauge")
+	for _, tm := range x.targets {
+		for i, values := range tm.percentiles {
+			if values == nil {
+				continue
+			}
+			for j, p := range metricPercentiles {
+				fmt.Fprintf(w, "pingback_aggregate_rtt_seconds{%s,group=\"%s\",percentile=\"%s\"} %s\n",
+					tm.labels, labelEscaper.Replace(x.names[i]), number(p), number(values[j]))
+			}
+		}
+	}
+}
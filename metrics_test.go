@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Scrape the metrics of the model after the samples of its first target
+func scrapeMetrics(t *testing.T, m *model, latencies ...float64) string {
+	t.Helper()
+	var err error
+	m.metrics, err = newMetrics(m.targets, m.aggregateCounts, m.aggregateNames, rttBuckets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, latency := range latencies {
+		m.update(latencyMsg{m.targets[0], latency, m.clock.Now(), sampleMeta{}})
+	}
+	recorder := httptest.NewRecorder()
+	m.metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	m, _ := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	m.aggregateNames = []string{"4"}
+	body := scrapeMetrics(t, m, 3, math.NaN(), 20, 1)
+	for _, want := range []string{
+		`pingback_probes_total{target="10.0.0.1",label="10.0.0.1"} 4`,
+		`pingback_lost_total{target="10.0.0.1",label="10.0.0.1"} 1`,
+		`pingback_rtt_seconds_bucket{target="10.0.0.1",label="10.0.0.1",le="0.005"} 2`,
+		`pingback_rtt_seconds_count{target="10.0.0.1",label="10.0.0.1"} 3`,
+		`pingback_rtt_seconds_sum{target="10.0.0.1",label="10.0.0.1"} 0.024`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("the metrics have no line %q:\n%s", want, body)
+		}
+	}
+	if !strings.Contains(body, "pingback_aggregate_rtt_seconds{") {
+		t.Errorf("the metrics have no percentiles of the completed group:\n%s", body)
+	}
+}
+
+func TestMetricsWithoutAggregates(t *testing.T) {
+	m, _ := newTestModel(t, []string{"10.0.0.1"}, time.Second, nil)
+	body := scrapeMetrics(t, m, 3, 4)
+	if !strings.Contains(body, `pingback_probes_total{target="10.0.0.1",label="10.0.0.1"} 2`) {
+		t.Errorf("the probes weren't counted:\n%s", body)
+	}
+	if strings.Contains(body, "pingback_aggregate_rtt_seconds{") {
+		t.Errorf("percentiles of aggregates with none configured:\n%s", body)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Largest frame a mirror accepts, far more than any terminal shows
+const maxMirrorFrame = 16 << 20
+
+// Serves the view of the session to the terminals mirroring it over a Unix
+// socket, each frame prefixed by its length
+type mirror struct {
+	path     string
+	listener net.Listener
+	mu       sync.Mutex
+	clients  map[chan string]struct{}
+	frame    string
+}
+
+// Listen on the socket, replacing it if it was left behind by a session that
+// is gone. Only the user can connect to it, as it shows what is monitored,
+// so it is made in a directory only the user can enter and moved into place.
+func listenMirror(path string) (*mirror, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another session is mirrored at %s", path)
+		}
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".pingback-mirror-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	listener, err := net.Listen("unix", filepath.Join(dir, "socket"))
+	if err != nil {
+		return nil, err
+	}
+	// The listener would remove the socket by the name it was made under
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	err = os.Chmod(filepath.Join(dir, "socket"), 0600)
+	if err == nil {
+		err = os.Rename(filepath.Join(dir, "socket"), path)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	x := &mirror{path: path, listener: listener, clients: make(map[chan string]struct{})}
+	go x.accept()
+	return x, nil
+}
+
+func (x *mirror) accept() {
+	for {
+		conn, err := x.listener.Accept()
+		if err != nil {
+			return
+		}
+		frames := make(chan string, 1)
+		x.mu.Lock()
+		x.clients[frames] = struct{}{}
+		if x.frame != "" {
+			frames <- x.frame
+		}
+		x.mu.Unlock()
+		go x.send(conn, frames)
+	}
+}
+
+// Write the frames to the connection until it or the mirror is closed
+func (x *mirror) send(conn net.Conn, frames chan string) {
+	defer conn.Close()
+	for frame := range frames {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(frame)))
+		if _, err := conn.Write(append(length[:], frame...)); err != nil {
+			break
+		}
+	}
+	x.mu.Lock()
+	if _, ok := x.clients[frames]; ok {
+		delete(x.clients, frames)
+		close(frames)
+	}
+	x.mu.Unlock()
+}
+
+// Send the view to every mirror, replacing the frame a slow one hasn't been
+// sent yet rather than holding up the session
+func (x *mirror) publish(view string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if view == x.frame {
+		return
+	}
+	x.frame = view
+	for frames := range x.clients {
+		select {
+		case <-frames:
+		default:
+		}
+		frames <- view
+	}
+}
+
+func (x *mirror) close() {
+	x.listener.Close()
+	x.mu.Lock()
+	for frames := range x.clients {
+		delete(x.clients, frames)
+		close(frames)
+	}
+	x.mu.Unlock()
+	os.Remove(x.path)
+}
+
+type mirrorFrameMsg string
+
+type mirrorEndMsg struct {
+	err error
+}
+
+// A read-only copy of the view of a session, cropped to this terminal
+type mirrorView struct {
+	reader *bufio.Reader
+	frame  string
+	width  int
+	height int
+	err    error
+}
+
+func runMirror(args []string) {
+	flags := flag.NewFlagSet("mirror", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback mirror <socket>")
+		fmt.Fprintln(flags.Output(), "Shows a read-only live copy of the view of a session started with -mirror <socket>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	conn, err := net.Dial("unix", flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	final, err := tea.NewProgram(&mirrorView{reader: bufio.NewReader(conn)}, tea.WithAltScreen()).Run()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if view := final.(*mirrorView); view.err != nil {
+		if errors.Is(view.err, io.EOF) {
+			fmt.Println("The session ended")
+		} else {
+			fmt.Println(view.err)
+			os.Exit(1)
+		}
+	}
+}
+
+func (v *mirrorView) readFrame() tea.Msg {
+	var length [4]byte
+	if _, err := io.ReadFull(v.reader, length[:]); err != nil {
+		return mirrorEndMsg{err}
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxMirrorFrame {
+		return mirrorEndMsg{fmt.Errorf("a frame of %d bytes is too large", size)}
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(v.reader, frame); err != nil {
+		return mirrorEndMsg{err}
+	}
+	return mirrorFrameMsg(frame)
+}
+
+func (v *mirrorView) Init() tea.Cmd {
+	return v.readFrame
+}
+
+func (v *mirrorView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case mirrorFrameMsg:
+		v.frame = string(msg)
+		return v, v.readFrame
+	case mirrorEndMsg:
+		v.err = msg.err
+		return v, tea.Quit
+	case tea.WindowSizeMsg:
+		v.width, v.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return v, tea.Quit
+		}
+	}
+	return v, nil
+}
+
+func (v *mirrorView) View() string {
+	if v.frame == "" {
+		return "Waiting for the view of the session"
+	}
+	return lipgloss.NewStyle().MaxWidth(v.width).MaxHeight(v.height).Render(v.frame)
+}
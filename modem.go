@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	modemCheckInterval = 5 * time.Second
+	// Number of the most recent signal readings kept, hours of them
+	maxModemReadings = 1 << 12
+	// A reading stands for the columns until the next one, or until it's
+	// this old when none followed
+	modemReadingAge = 3 * modemCheckInterval
+)
+
+// The quality of the radio signal of a cellular modem at a point in time,
+// where unknown values are NaN
+type modemReading struct {
+	at         time.Time
+	technology string
+	// Reference signal received power in dBm and quality in dB, and signal
+	// to interference plus noise ratio in dB
+	rsrp float64
+	rsrq float64
+	sinr float64
+}
+
+// A signal value shown below the charts, colored from red at bad to green
+// at excellent
+type modemSignal struct {
+	name string
+	unit string
+	bad  float64
+	good float64
+	get  func(modemReading) float64
+}
+
+var modemSignals = []modemSignal{
+	{"RSRP", "dBm", -120, -80, func(r modemReading) float64 { return r.rsrp }},
+	{"RSRQ", "dB", -20, -5, func(r modemReading) float64 { return r.rsrq }},
+	{"SINR", "dB", 0, 20, func(r modemReading) float64 { return r.sinr }},
+}
+
+type modemMsg struct {
+	reading modemReading
+	err     error
+}
+
+func mmcli(ctx context.Context, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, "mmcli", args...).Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("mmcli %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exit.Stderr)))
+		}
+		return "", fmt.Errorf("mmcli %s: %w", strings.Join(args, " "), err)
+	}
+	return string(output), nil
+}
+
+// Ask ModemManager to refresh the signal of the modem as often as it's read,
+// which it doesn't by default
+func setupModemSignal(ctx context.Context, modem string) error {
+	_, err := mmcli(ctx, "-m", modem, fmt.Sprintf("--signal-setup=%d", int(modemCheckInterval.Seconds())))
+	return err
+}
+
+// Read the signal of the modem with `mmcli -m <modem> --signal-get`,
+// preferring 5G to LTE when the modem is on both
+func readModemSignal(ctx context.Context, modem string, now time.Time) (modemReading, error) {
+	output, err := mmcli(ctx, "-m", modem, "--signal-get", "--output-keyvalue")
+	if err != nil {
+		return modemReading{}, err
+	}
+	values := make(map[string]float64)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if number, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			values[strings.TrimSpace(key)] = number
+		}
+	}
+	reading := modemReading{at: now, rsrp: math.NaN(), rsrq: math.NaN(), sinr: math.NaN()}
+	for _, technology := range []string{"5g", "lte"} {
+		prefix := "modem.signal." + technology + "."
+		rsrp, ok := values[prefix+"rsrp"]
+		if !ok {
+			continue
+		}
+		reading.technology = strings.ToUpper(technology)
+		reading.rsrp = rsrp
+		if rsrq, ok := values[prefix+"rsrq"]; ok {
+			reading.rsrq = rsrq
+		}
+		if sinr, ok := values[prefix+"snr"]; ok {
+			reading.sinr = sinr
+		}
+		break
+	}
+	return reading, nil
+}
+
+func (m *model) checkModemCmd(ctx context.Context, modem string, delay time.Duration) tea.Cmd {
+	return m.clock.Tick(delay, func(now time.Time) tea.Msg {
+		reading, err := readModemSignal(ctx, modem, now)
+		return modemMsg{reading, err}
+	})
+}
+
+func (m *model) trackModem(msg modemMsg) tea.Cmd {
+	if msg.err != nil {
+		m.status = msg.err.Error()
+	} else {
+		m.modemReadings = append(m.modemReadings, msg.reading)
+		if len(m.modemReadings) > maxModemReadings {
+			m.modemReadings = m.modemReadings[1:]
+		}
+	}
+	return m.checkModemCmd(m.ctx, m.modem, modemCheckInterval)
+}
+
+// Render the signal of the modem as rows lined up with the columns of the
+// focused target, each column showing the reading at its time
+func (m *model) renderModem() string {
+	title := fmt.Sprintf("Signal of modem %s:", m.modem)
+	if len(m.modemReadings) == 0 {
+		return title + " no reading yet"
+	}
+	latest := m.modemReadings[len(m.modemReadings)-1]
+	if latest.technology == "" {
+		title = fmt.Sprintf("Signal of modem %s: not on LTE or 5G", m.modem)
+	} else {
+		title = fmt.Sprintf("Signal of modem %s (%s):", m.modem, latest.technology)
+	}
+	times := m.displayedTimes(m.targets[m.focus].stream)
+	rows := []string{title}
+	for _, signal := range modemSignals {
+		cells := make([]cell, len(times))
+		for i, at := range times {
+			cells[i] = cell{" ", "", false}
+			index, found := slices.BinarySearchFunc(m.modemReadings, at, func(r modemReading, target time.Time) int {
+				return r.at.Compare(target)
+			})
+			if !found {
+				index--
+			}
+			if index < 0 {
+				continue
+			}
+			reading := m.modemReadings[index]
+			stale := index == len(m.modemReadings)-1 && at.Sub(reading.at) > modemReadingAge
+			if value := signal.get(reading); !stale && !math.IsNaN(value) {
+				cells[i] = gradientCell(transferGradient, (value-signal.bad)/(signal.good-signal.bad))
+			}
+		}
+		now := "unknown"
+		if value := signal.get(latest); !math.IsNaN(value) {
+			now = fmt.Sprintf("%.0f %s", value, signal.unit)
+		}
+		rows = append(rows, fmt.Sprintf("%s, now %s:", signal.name, now), renderRow(cells))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+func (m *model) scrollTo(offset int) {
+	focused := m.targets[m.focus]
+	m.offset = max(0, min(offset, len(focused.latencyData)-m.windowWidth*m.zoom))
+	m.redraw = true
+}
+
+// Scroll by a quarter of the view, in samples
+func (m *model) scrollStep() int {
+	return max(1, m.windowWidth/4) * m.zoom
+}
+
+// Get the time of the sample in the middle of the view
+func (m *model) viewCenter() time.Time {
+	focused := m.targets[m.focus]
+	times := m.displayedTimes(focused.stream)
+	if len(times) == 0 {
+		return focused.timestamps[len(focused.timestamps)-1]
+	}
+	return times[len(times)/2]
+}
+
+// Scroll so that the sample taken at the given time is in the middle of
+// the view
+func (m *model) jumpTo(at time.Time) bool {
+	timestamps := m.targets[m.focus].timestamps
+	if len(timestamps) == 0 || at.Before(timestamps[0]) || at.After(timestamps[len(timestamps)-1]) {
+		return false
+	}
+	i, _ := slices.BinarySearchFunc(timestamps, at, func(t, target time.Time) int {
+		return t.Compare(target)
+	})
+	m.scrollTo(len(timestamps) - 1 - i - m.windowWidth*m.zoom/2)
+	return true
+}
+
+func (m *model) jumpToOutage(forward bool) {
+	if len(m.targets[m.focus].timestamps) == 0 {
+		return
+	}
+	center := m.viewCenter()
+	incidents := slices.Clone(m.incidents)
+	if !forward {
+		slices.Reverse(incidents)
+	}
+	for _, inc := range incidents {
+		if forward && inc.start.After(center) || !forward && inc.start.Before(center) {
+			if m.jumpTo(inc.start) {
+				return
+			}
+		}
+	}
+	direction := "after"
+	if !forward {
+		direction = "before"
+	}
+	m.status = fmt.Sprintf("No outage %s %s", direction, m.timeFormat.format(center))
+}
+
+// Parse a time of day, which refers to the most recent such time
+func (m *model) parseTimeOfDay(query string, now time.Time) (time.Time, bool) {
+	location := m.timeFormat.zone()
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04", time.TimeOnly, "15:04"} {
+		parsed, err := time.ParseInLocation(layout, query, location)
+		if err != nil {
+			continue
+		}
+		if parsed.Year() != 0 {
+			return parsed, true
+		}
+		now = now.In(location)
+		at := time.Date(now.Year(), now.Month(), now.Day(),
+			parsed.Hour(), parsed.Minute(), parsed.Second(), 0, location)
+		if at.After(now) {
+			at = at.AddDate(0, 0, -1)
+		}
+		return at, true
+	}
+	return time.Time{}, false
+}
+
+// Jump to a time, the next or previous outage, or a marker
+func (m *model) search(query string) {
+	query = strings.TrimSpace(query)
+	switch query {
+	case "":
+		return
+	case "next":
+		m.jumpToOutage(true)
+		return
+	case "prev":
+		m.jumpToOutage(false)
+		return
+	}
+	if at, ok := m.parseTimeOfDay(query, m.clock.Now()); ok {
+		if !m.jumpTo(at) {
+			m.status = "No samples at " + m.timeFormat.format(at)
+		}
+		return
+	}
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if e := m.events[i]; e.kind == markerEvent && e.label == query {
+			if !m.jumpTo(e.time) {
+				m.status = "Marker " + query + " is no longer in the history"
+			}
+			return
+		}
+	}
+	m.status = "No marker named " + query
+}
+
+func (m *model) startPrompt(prompt string, submit func(string)) {
+	m.prompt = prompt
+	m.submit = submit
+	m.input = ""
+}
+
+// Edit the input of the prompt, submitting it on enter
+func (m *model) updatePrompt(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.prompt = ""
+		m.submit(m.input)
+	case tea.KeyEsc:
+		m.prompt = ""
+	case tea.KeyBackspace:
+		if len(m.input) > 0 {
+			runes := []rune(m.input)
+			m.input = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.input += string(msg.Runes)
+	}
+}
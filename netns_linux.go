@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Get the path of a named network namespace, as created by `ip netns add`,
+// or of a namespace given by path such as /proc/<pid>/ns/net
+func netnsPath(name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+	return filepath.Join("/var/run/netns", name)
+}
+
+func checkNetns(name string) error {
+	file, err := os.Open(netnsPath(name))
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Run the function on a thread of its own that has entered the network
+// namespace, so the sockets it creates belong to the namespace. The thread
+// is never unlocked, so it exits along with the goroutine instead of being
+// reused in the wrong namespace.
+func inNetns(name string, fn func()) error {
+	if name == "" {
+		fn()
+		return nil
+	}
+	errs := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		file, err := os.Open(netnsPath(name))
+		if err == nil {
+			err = unix.Setns(int(file.Fd()), unix.CLONE_NEWNET)
+			file.Close()
+		}
+		if err == nil {
+			fn()
+		}
+		errs <- err
+	}()
+	return <-errs
+}
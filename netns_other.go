@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Network namespaces only exist on linux
+func checkNetns(name string) error {
+	return errors.New("network namespaces are only supported on Linux")
+}
+
+func inNetns(name string, fn func()) error {
+	if name == "" {
+		fn()
+		return nil
+	}
+	return checkNetns(name)
+}
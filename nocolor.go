@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Glyphs of increasing intensity, which stand in for the colors of a
+// gradient when there are none
+const intensityRamp = " .:-=+*#%@"
+
+// Whether charts are drawn with the intensity ramp instead of colors
+var noColor bool
+
+// Get the cell of a ratio of a gradient, a block of its color, or a glyph of
+// the intensity ramp without colors
+func gradientCell(colors []lipgloss.Color, ratio float64) cell {
+	if noColor {
+		index := int(math.Round(math.Max(0, math.Min(1, ratio)) * float64(len(intensityRamp)-1)))
+		return cell{intensityRamp[index : index+1], "", false}
+	}
+	return cell{"█", getGradientColor(colors, ratio), false}
+}
+
+// Draw without colors, for dumb terminals and logs, as asked for by -no-color
+// or by setting NO_COLOR to anything
+func applyNoColor() {
+	noColor = true
+	lostCell = cell{"X", "", false}
+	lipgloss.SetColorProfile(termenv.Ascii)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Number of incidents shown in the outage log
+const outageLogLength = 5
+
+// An outage is a run of consecutive losses on one target
+type outage struct {
+	target *target
+	start  time.Time
+	end    time.Time
+	// The target this one depends on that was down as well, which the
+	// outage is blamed on rather than alerted
+	upstream *target
+	incident *incident
+}
+
+// An incident groups the outages of targets that were down at the same time
+// and share a tag, so a shared cause is reported once
+type incident struct {
+	outages []*outage
+	start   time.Time
+	end     time.Time
+	// Whether the alert command was told, which it isn't while every outage
+	// is blamed on an upstream target
+	alerted bool
+}
+
+func (inc *incident) ongoing() bool {
+	return inc.end.IsZero()
+}
+
+func (inc *incident) addresses() []string {
+	addresses := make([]string, len(inc.outages))
+	for i, o := range inc.outages {
+		addresses[i] = o.target.address
+	}
+	return addresses
+}
+
+// Get the addresses of the targets that are down in their own right, and of
+// those that are down because a target they depend on is
+func (inc *incident) blame() (down, suppressed []string) {
+	for _, o := range inc.outages {
+		if o.upstream == nil {
+			down = append(down, o.target.address)
+		} else {
+			suppressed = append(suppressed, o.target.address)
+		}
+	}
+	return down, suppressed
+}
+
+// Alert on the incident once one of its outages isn't blamed on an upstream
+// target
+func (m *model) alertIncident(inc *incident) tea.Cmd {
+	if inc.alerted {
+		return nil
+	}
+	if down, _ := inc.blame(); len(down) == 0 {
+		return nil
+	}
+	inc.alerted = true
+	return m.alertCmd("down", inc)
+}
+
+// Get the ongoing incident an outage of the target joins, the latest one
+// with a target that shares a tag with it
+func (m *model) openIncident(t *target) *incident {
+	for _, inc := range slices.Backward(m.incidents) {
+		if !inc.ongoing() {
+			continue
+		}
+		for _, o := range inc.outages {
+			if m.sharesTag(t, o.target) {
+				return inc
+			}
+		}
+	}
+	return nil
+}
+
+// Get the tags of the target, listed comma separated as tags in its metadata
+func (m *model) tags(t *target) []string {
+	var tags []string
+	for _, tag := range strings.Split(m.metadata(t)["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Whether the targets share a tag, which targets without tags do with every
+// target
+func (m *model) sharesTag(a, b *target) bool {
+	tagsA, tagsB := m.tags(a), m.tags(b)
+	if len(tagsA) == 0 || len(tagsB) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(tagsA, func(tag string) bool { return slices.Contains(tagsB, tag) })
+}
+
+func (m *model) trackOutage(t *target, latency float64, now time.Time) tea.Cmd {
+	if !math.IsNaN(latency) {
+		t.lossStreak = 0
+		if t.outage == nil {
+			return nil
+		}
+		m.resolveBanner(t, "outage", now)
+		t.outage.end = now
+		inc := t.outage.incident
+		t.outage = nil
+		for _, o := range inc.outages {
+			if o.end.IsZero() {
+				return nil
+			}
+		}
+		inc.end = now
+		if !inc.alerted {
+			return nil
+		}
+		return m.alertCmd("resolved", inc)
+	}
+
+	if t.lossStreak == 0 {
+		t.lossStart = now
+	}
+	t.lossStreak++
+	// An upstream target that is losing packets as well may just not have
+	// been handled yet, and is blamed for as long as its streak lasts
+	upstream := t.upstreamFailing()
+	if t.lossStreak >= m.outageThreshold {
+		if upstream != nil {
+			m.raiseBanner(t, "outage", "Unreachable", fmt.Sprintf("%d lost in a row, %s is failing", t.lossStreak, upstream.label), t.lossStart)
+		} else {
+			m.raiseBanner(t, "outage", "Down", fmt.Sprintf("%d lost in a row", t.lossStreak), t.lossStart)
+		}
+	}
+	if t.outage != nil && t.outage.upstream != nil {
+		// Still down after the upstream target came back, or never went
+		// down, so down in its own right
+		if t.outage.upstream = upstream; upstream == nil {
+			return m.alertIncident(t.outage.incident)
+		}
+	}
+	if t.lossStreak != m.outageThreshold {
+		return nil
+	}
+	inc := m.openIncident(t)
+	if inc == nil {
+		inc = &incident{start: t.lossStart}
+		m.incidents = append(m.incidents, inc)
+	}
+	t.outage = &outage{target: t, start: t.lossStart, upstream: upstream, incident: inc}
+	inc.outages = append(inc.outages, t.outage)
+	return m.alertIncident(inc)
+}
+
+// Keep track of the longest loss streak of the target, and run the alert
+// command when the streak reaches -streak-alert
+func (m *model) trackStreak(t *target, now time.Time) tea.Cmd {
+	if t.lossStreak > t.longestStreak {
+		t.longestStreak = t.lossStreak
+		t.longestStreakStart = t.lossStart
+	}
+	if m.streakAlert == 0 || t.lossStreak != m.streakAlert || t.upstreamDown() != nil {
+		return nil
+	}
+	return m.runAlert(append(os.Environ(),
+		"PINGBACK_EVENT=streak",
+		"PINGBACK_TARGETS="+t.address,
+		"PINGBACK_START="+m.timeFormat.format(t.lossStart),
+		fmt.Sprintf("PINGBACK_STREAK=%d", t.lossStreak),
+	))
+}
+
+var (
+	streakStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("#d23105")).Bold(true)
+	longestStreakStyle = lipgloss.NewStyle().Bold(true)
+)
+
+// Show the longest current loss streak and the longest of the session, as
+// one long streak is far worse than the same loss spread out
+func (m *model) renderStreaks() string {
+	var current, longest *target
+	for _, t := range m.targets {
+		if t.lossStreak > 0 && (current == nil || t.lossStreak > current.lossStreak) {
+			current = t
+		}
+		if t.longestStreak > 0 && (longest == nil || t.longestStreak > longest.longestStreak) {
+			longest = t
+		}
+	}
+	if longest == nil {
+		return ""
+	}
+	line := "Loss streak: none now"
+	if current != nil {
+		line = "Loss streak: " + streakStyle.Render(fmt.Sprintf("%d lost in a row", current.lossStreak)) + " on " + current.label
+	}
+	return line + "  longest " + longestStreakStyle.Render(fmt.Sprint(longest.longestStreak)) +
+		fmt.Sprintf(" on %s at %s", longest.label, m.timeFormat.format(longest.longestStreakStart))
+}
+
+// Run the alert command with the incident described in its environment
+func (m *model) alertCmd(event string, inc *incident) tea.Cmd {
+	if m.alertCommand == "" {
+		return nil
+	}
+	down, suppressed := inc.blame()
+	env := append(os.Environ(),
+		"PINGBACK_EVENT="+event,
+		"PINGBACK_TARGETS="+strings.Join(down, ","),
+		"PINGBACK_START="+m.timeFormat.format(inc.start),
+	)
+	if len(suppressed) > 0 {
+		env = append(env, "PINGBACK_SUPPRESSED="+strings.Join(suppressed, ","))
+	}
+	if !inc.ongoing() {
+		env = append(env, "PINGBACK_DURATION="+inc.end.Sub(inc.start).Round(time.Second).String())
+	}
+	return m.runAlert(env)
+}
+
+func (m *model) runAlert(env []string) tea.Cmd {
+	if m.alertCommand == "" {
+		return nil
+	}
+	command, alerts := m.alertCommand, m.alerts
+	alerts.Add(1)
+	return func() tea.Msg {
+		defer alerts.Done()
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = env
+		_ = cmd.Run()
+		return nil
+	}
+}
+
+func (m *model) renderOutageLog(now time.Time) string {
+	if len(m.incidents) == 0 {
+		return ""
+	}
+	lines := []string{"Outages:"}
+	for _, inc := range m.incidents[max(0, len(m.incidents)-outageLogLength):] {
+		state := "down"
+		end := inc.end
+		if inc.ongoing() {
+			state = "ongoing"
+			end = now
+		}
+		targets := make([]string, len(inc.outages))
+		for i, o := range inc.outages {
+			targets[i] = o.target.address
+			if o.upstream != nil {
+				targets[i] += " (behind " + o.upstream.label + ")"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("  %s  %s %v  %s",
+			m.timeFormat.format(inc.start), state, end.Sub(inc.start).Round(time.Second),
+			strings.Join(targets, ", ")))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Lose a packet of each of the targets in turn, a second apart
+func loseRound(m *model, clock *manualClock, targets ...*target) {
+	for _, t := range targets {
+		m.update(latencyMsg{t, math.NaN(), clock.Now(), sampleMeta{}})
+	}
+	clock.Advance(time.Second)
+}
+
+func TestOutageBehindUpstreamFailingInTheSameRound(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1", "10.0.0.2"}, time.Second, []int{4})
+	m.outageThreshold = 3
+	if err := m.linkDependencies(map[string]string{"10.0.0.2": "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	gateway, behind := m.targets[0], m.targets[1]
+
+	// The target behind the gateway is handled first, and reaches an outage
+	// while the gateway is a loss short of one
+	for range m.outageThreshold {
+		loseRound(m, clock, behind, gateway)
+	}
+	if len(m.incidents) != 1 {
+		t.Fatalf("got %d incidents, want one", len(m.incidents))
+	}
+	inc := m.incidents[0]
+	down, suppressed := inc.blame()
+	if !slices.Equal(down, []string{gateway.address}) || !slices.Equal(suppressed, []string{behind.address}) {
+		t.Errorf("blamed %v and suppressed %v, want the gateway blamed and the target behind it suppressed", down, suppressed)
+	}
+
+	loseRound(m, clock, behind, gateway)
+	if down, suppressed = inc.blame(); !slices.Equal(suppressed, []string{behind.address}) {
+		t.Errorf("blamed %v and suppressed %v while the gateway stays down", down, suppressed)
+	}
+}
+
+func TestOutageBehindUpstreamThatLostAPacket(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1", "10.0.0.2"}, time.Second, []int{4})
+	m.outageThreshold = 3
+	if err := m.linkDependencies(map[string]string{"*": "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	gateway, behind := m.targets[0], m.targets[1]
+
+	loseRound(m, clock, behind)
+	loseRound(m, clock, behind, gateway)
+	loseRound(m, clock, behind)
+	inc := m.incidents[0]
+	if inc.alerted {
+		t.Error("alerted while the gateway was losing packets as well")
+	}
+
+	// The gateway replies again without going down, so the target is down in
+	// its own right
+	m.update(latencyMsg{gateway, 1, clock.Now(), sampleMeta{}})
+	loseRound(m, clock, behind)
+	if down, _ := inc.blame(); !inc.alerted || !slices.Equal(down, []string{behind.address}) {
+		t.Errorf("blamed %v, alerted %v, want the target behind the gateway alerted", down, inc.alerted)
+	}
+}
+
+func TestOutagesGroupedByTags(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, time.Second, []int{4})
+	m.outageThreshold = 2
+	m.targetMetadata = map[string]map[string]string{
+		"10.0.0.1": {"tags": "core, reykjavik"},
+		"10.0.0.2": {"tags": "office"},
+		"10.0.0.3": {"tags": "reykjavik"},
+	}
+	core, office, city := m.targets[0], m.targets[1], m.targets[2]
+
+	for range m.outageThreshold {
+		loseRound(m, clock, core, office, city)
+	}
+	if len(m.incidents) != 2 {
+		t.Fatalf("got %d incidents, want one for the shared tag and one for the office", len(m.incidents))
+	}
+	if core.outage.incident != city.outage.incident {
+		t.Error("targets sharing a tag are in different incidents")
+	}
+	if core.outage.incident == office.outage.incident {
+		t.Error("targets without a shared tag are in the same incident")
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// Link types of the captures that can be read
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkSLL      = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+// Largest packet a capture is read with, the snapshot length tcpdump
+// captures whole packets with
+const maxCapturedPacket = 262144
+
+// Largest pcapng block read, a packet of the largest size with room for the
+// header and options of its block
+const maxCaptureBlock = maxCapturedPacket + 65536
+
+// A packet of a capture, from its IP header on
+type capturedPacket struct {
+	at time.Time
+	ip []byte
+}
+
+// Read the IP packets of a capture in the pcap or pcapng format, leaving out
+// packets of other protocols and of links that can't be read
+func readCapture(r io.Reader) ([]capturedPacket, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(4)
+	if err != nil {
+		return nil, errors.New("not a capture, it's too short")
+	}
+	if binary.LittleEndian.Uint32(magic) == 0x0a0d0d0a {
+		return readPcapng(reader)
+	}
+	return readPcap(reader)
+}
+
+func readPcap(reader io.Reader) ([]capturedPacket, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, errors.New("not a capture, it's too short")
+	}
+	var order binary.ByteOrder
+	var resolution time.Duration
+	switch {
+	case binary.LittleEndian.Uint32(header) == 0xa1b2c3d4:
+		order, resolution = binary.LittleEndian, time.Microsecond
+	case binary.BigEndian.Uint32(header) == 0xa1b2c3d4:
+		order, resolution = binary.BigEndian, time.Microsecond
+	case binary.LittleEndian.Uint32(header) == 0xa1b23c4d:
+		order, resolution = binary.LittleEndian, time.Nanosecond
+	case binary.BigEndian.Uint32(header) == 0xa1b23c4d:
+		order, resolution = binary.BigEndian, time.Nanosecond
+	default:
+		return nil, errors.New("not a capture in the pcap or pcapng format")
+	}
+	link := order.Uint32(header[20:]) & 0xffff
+	// Records are no longer than the snapshot length of the capture
+	limit := order.Uint32(header[16:])
+	if limit == 0 || limit > maxCapturedPacket {
+		limit = maxCapturedPacket
+	}
+	var packets []capturedPacket
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(reader, record); err == io.EOF {
+			return packets, nil
+		} else if err != nil {
+			return packets, fmt.Errorf("capture cut short: %w", err)
+		}
+		at := time.Unix(int64(order.Uint32(record)), 0).Add(time.Duration(order.Uint32(record[4:])) * resolution)
+		length := order.Uint32(record[8:])
+		if length > limit {
+			return packets, fmt.Errorf("malformed capture, a packet of %d bytes is longer than %d", length, limit)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return packets, fmt.Errorf("capture cut short: %w", err)
+		}
+		if ip := linkPayload(link, data); ip != nil {
+			packets = append(packets, capturedPacket{at, ip})
+		}
+	}
+}
+
+// Read the enhanced packets of a pcapng capture, with the link type and
+// time resolution of the interface each was captured on
+func readPcapng(reader io.Reader) ([]capturedPacket, error) {
+	type iface struct {
+		link uint32
+		// Ticks of the timestamps are 10^-exponent seconds, or
+		// 2^-exponent when binary
+		exponent uint
+		binary   bool
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	var ifaces []iface
+	var packets []capturedPacket
+	head := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, head); err == io.EOF {
+			return packets, nil
+		} else if err != nil {
+			return packets, fmt.Errorf("capture cut short: %w", err)
+		}
+		if binary.LittleEndian.Uint32(head) == 0x0a0d0d0a {
+			// A section header, which sets the byte order of its section
+			magic := make([]byte, 4)
+			if _, err := io.ReadFull(reader, magic); err != nil {
+				return packets, fmt.Errorf("capture cut short: %w", err)
+			}
+			order = binary.LittleEndian
+			if binary.BigEndian.Uint32(magic) == 0x1a2b3c4d {
+				order = binary.BigEndian
+			}
+			length := order.Uint32(head[4:])
+			if length < 16 {
+				return packets, errors.New("malformed pcapng section")
+			}
+			if _, err := io.CopyN(io.Discard, reader, int64(length-12)); err != nil {
+				return packets, fmt.Errorf("capture cut short: %w", err)
+			}
+			ifaces = nil
+			continue
+		}
+		kind, length := order.Uint32(head), order.Uint32(head[4:])
+		if length < 12 || length%4 != 0 {
+			return packets, errors.New("malformed pcapng block")
+		}
+		if length > maxCaptureBlock {
+			return packets, fmt.Errorf("malformed pcapng, a block of %d bytes is longer than %d", length, maxCaptureBlock)
+		}
+		body := make([]byte, length-8)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return packets, fmt.Errorf("capture cut short: %w", err)
+		}
+		body = body[:len(body)-4]
+		switch kind {
+		case 1:
+			// An interface description, whose time resolution is a power of
+			// ten or two given by an option, microseconds by default
+			if len(body) < 8 {
+				return packets, errors.New("malformed pcapng interface")
+			}
+			i := iface{link: uint32(order.Uint16(body)), exponent: 6}
+			for options := body[8:]; len(options) >= 4; {
+				code, size := order.Uint16(options), int(order.Uint16(options[2:]))
+				if code == 0 || len(options) < 4+size {
+					break
+				}
+				if code == 9 && size >= 1 {
+					i.exponent, i.binary = uint(options[4]&0x7f), options[4]&0x80 != 0
+				}
+				options = options[4+(size+3)/4*4:]
+			}
+			ifaces = append(ifaces, i)
+		case 6:
+			// An enhanced packet
+			if len(body) < 20 {
+				return packets, errors.New("malformed pcapng packet")
+			}
+			index := order.Uint32(body)
+			if int(index) >= len(ifaces) {
+				return packets, errors.New("pcapng packet of an undescribed interface")
+			}
+			i := ifaces[index]
+			ticks := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			var at time.Time
+			switch {
+			case i.binary:
+				fraction := float64(ticks&(1<<i.exponent-1)) / math.Exp2(float64(i.exponent))
+				at = time.Unix(int64(ticks>>i.exponent), int64(fraction*1e9))
+			case i.exponent <= 9:
+				at = time.Unix(0, int64(ticks)*int64(math.Pow10(int(9-i.exponent))))
+			default:
+				at = time.Unix(0, int64(ticks/uint64(math.Pow10(int(i.exponent-9)))))
+			}
+			captured := int(order.Uint32(body[12:]))
+			if 20+captured > len(body) {
+				return packets, errors.New("malformed pcapng packet")
+			}
+			if ip := linkPayload(i.link, body[20:20+captured]); ip != nil {
+				packets = append(packets, capturedPacket{at, ip})
+			}
+		}
+	}
+}
+
+// Get the IP packet that a frame of the link type carries, or nil
+func linkPayload(link uint32, frame []byte) []byte {
+	var ip []byte
+	switch link {
+	case linkEthernet:
+		offset := 12
+		for len(frame) >= offset+2 && (frame[offset] == 0x81 || frame[offset] == 0x88) && (frame[offset+1] == 0x00 || frame[offset+1] == 0xa8) {
+			// VLAN tags
+			offset += 4
+		}
+		if len(frame) < offset+2 {
+			return nil
+		}
+		if kind := binary.BigEndian.Uint16(frame[offset:]); kind != 0x0800 && kind != 0x86dd {
+			return nil
+		}
+		ip = frame[offset+2:]
+	case linkNull:
+		if len(frame) < 4 {
+			return nil
+		}
+		ip = frame[4:]
+	case linkRaw, linkIPv4, linkIPv6:
+		ip = frame
+	case linkSLL:
+		if len(frame) < 16 {
+			return nil
+		}
+		ip = frame[16:]
+	case linkSLL2:
+		if len(frame) < 20 {
+			return nil
+		}
+		ip = frame[20:]
+	default:
+		return nil
+	}
+	if len(ip) == 0 || (ip[0]>>4 != 4 && ip[0]>>4 != 6) {
+		return nil
+	}
+	return ip
+}
+
+// The addresses, protocol and payload of an IP packet, and its time to live
+type ipPacket struct {
+	src      netip.Addr
+	dst      netip.Addr
+	protocol byte
+	ttl      int
+	payload  []byte
+}
+
+// Parse the header of an IP packet. Fragments after the first and IPv6
+// extension headers are left out.
+func parseIP(data []byte) (ipPacket, bool) {
+	switch data[0] >> 4 {
+	case 4:
+		length := int(data[0]&0x0f) * 4
+		if len(data) < 20 || length < 20 || len(data) < length {
+			return ipPacket{}, false
+		}
+		if binary.BigEndian.Uint16(data[6:])&0x1fff != 0 {
+			return ipPacket{}, false
+		}
+		end := min(len(data), max(length, int(binary.BigEndian.Uint16(data[2:]))))
+		return ipPacket{
+			src:      netip.AddrFrom4([4]byte(data[12:16])),
+			dst:      netip.AddrFrom4([4]byte(data[16:20])),
+			protocol: data[9],
+			ttl:      int(data[8]),
+			payload:  data[length:end],
+		}, true
+	case 6:
+		if len(data) < 40 {
+			return ipPacket{}, false
+		}
+		end := min(len(data), 40+int(binary.BigEndian.Uint16(data[4:])))
+		return ipPacket{
+			src:      netip.AddrFrom16([16]byte(data[8:24])),
+			dst:      netip.AddrFrom16([16]byte(data[24:40])),
+			protocol: data[6],
+			ttl:      int(data[7]),
+			payload:  data[40:end],
+		}, true
+	}
+	return ipPacket{}, false
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/net/icmp"
+)
+
+// How ICMP probes can be sent
+type icmpAccess int
+
+const (
+	// Unprivileged datagram sockets, allowed by ping_group_range
+	icmpUnprivileged icmpAccess = iota
+	// Raw sockets, allowed by root or CAP_NET_RAW
+	icmpPrivileged
+	icmpDenied
+)
+
+// The sockets ICMP probes of a family can be sent over, unprivileged first
+type icmpSocket struct {
+	network string
+	address string
+	access  icmpAccess
+}
+
+var (
+	icmp4Sockets = []icmpSocket{{"udp4", "0.0.0.0", icmpUnprivileged}, {"ip4:icmp", "0.0.0.0", icmpPrivileged}}
+	icmp6Sockets = []icmpSocket{{"udp6", "::", icmpUnprivileged}, {"ip6:ipv6-icmp", "::", icmpPrivileged}}
+)
+
+// Find out how ICMP probes can be sent from the namespace to the addresses,
+// preferring unprivileged sockets, in each family the addresses are in.
+// Names are pinged over IPv4 when they have an IPv4 address, so only
+// literal IPv6 addresses need IPv6. Errors other than being denied are left
+// to the probes to report.
+func detectICMP(netns string, addresses []string) icmpAccess {
+	ipv4, ipv6 := false, false
+	for _, address := range addresses {
+		if !isICMP(address) {
+			continue
+		}
+		if ip, err := netip.ParseAddr(address); err == nil && ip.Is6() && !ip.Is4In6() {
+			ipv6 = true
+		} else {
+			ipv4 = true
+		}
+	}
+	access := icmpUnprivileged
+	for _, family := range []struct {
+		used    bool
+		sockets []icmpSocket
+	}{{ipv4, icmp4Sockets}, {ipv6, icmp6Sockets}} {
+		if !family.used {
+			continue
+		}
+		// Raw sockets are used for every family if any needs them
+		switch detectICMPSockets(netns, family.sockets) {
+		case icmpDenied:
+			return icmpDenied
+		case icmpPrivileged:
+			access = icmpPrivileged
+		}
+	}
+	return access
+}
+
+func detectICMPSockets(netns string, sockets []icmpSocket) icmpAccess {
+	access := icmpUnprivileged
+	err := inNetns(netns, func() {
+		for _, socket := range sockets {
+			conn, err := icmp.ListenPacket(socket.network, socket.address)
+			if err == nil {
+				conn.Close()
+				access = socket.access
+				return
+			}
+			if !errors.Is(err, os.ErrPermission) {
+				return
+			}
+		}
+		access = icmpDenied
+	})
+	if err != nil {
+		return icmpUnprivileged
+	}
+	return access
+}
+
+func isICMP(address string) bool {
+	return !strings.Contains(address, "://")
+}
+
+// Explain how to allow pings, for the guided setup and headless mode
+func permissionHelp() string {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "pingback"
+	}
+	return fmt.Sprintf(`Pingback can't send pings, as this system doesn't let you open ICMP sockets.
+
+Either allow every user to ping, until the next reboot:
+  sudo sysctl -w net.ipv4.ping_group_range="0 2147483647"
+or let Pingback open raw sockets:
+  sudo setcap cap_net_raw+ep %s
+Without either, Pingback can time TCP connections or UDP datagrams instead.`, executable)
+}
+
+// What the user chose in the guided setup
+type permissionChoice int
+
+const (
+	permissionRetry permissionChoice = iota
+	permissionTCP
+	permissionUDP
+	permissionQuit
+)
+
+var permissionChoices = []string{
+	"Check again, after running one of the commands above",
+	"Probe TCP port 443 instead of pinging",
+	"Probe UDP port " + defaultUDPPort + " instead of pinging, timing the port unreachable replies",
+	"Quit",
+}
+
+// A guided setup shown before starting when pings aren't allowed, instead of
+// failing on the first probe
+type permissionModel struct {
+	netns     string
+	addresses []string
+	cursor    int
+	access    icmpAccess
+	choice    permissionChoice
+	attempts  int
+	// Whether the setup is over, leaving the screen to the charts
+	done bool
+}
+
+func (p *permissionModel) Init() tea.Cmd {
+	return nil
+}
+
+func (p *permissionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+	switch key.String() {
+	case "up", "k":
+		p.cursor = max(0, p.cursor-1)
+	case "down", "j":
+		p.cursor = min(len(permissionChoices)-1, p.cursor+1)
+	case "r":
+		p.cursor = int(permissionRetry)
+		return p.choose()
+	case "enter":
+		return p.choose()
+	case "q", "esc", "ctrl+c":
+		p.choice = permissionQuit
+		p.done = true
+		return p, tea.Quit
+	}
+	return p, nil
+}
+
+func (p *permissionModel) choose() (tea.Model, tea.Cmd) {
+	p.choice = permissionChoice(p.cursor)
+	if p.choice != permissionRetry {
+		p.done = true
+		return p, tea.Quit
+	}
+	p.attempts++
+	if p.access = detectICMP(p.netns, p.addresses); p.access != icmpDenied {
+		p.done = true
+		return p, tea.Quit
+	}
+	return p, nil
+}
+
+func (p *permissionModel) View() string {
+	if p.done {
+		return ""
+	}
+	lines := []string{permissionHelp(), ""}
+	for i, choice := range permissionChoices {
+		if i == p.cursor {
+			lines = append(lines, lipgloss.NewStyle().Bold(true).Render("> "+choice))
+		} else {
+			lines = append(lines, "  "+choice)
+		}
+	}
+	if p.attempts > 0 {
+		lines = append(lines, "", "Still not allowed to ping")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Make sure the ICMP targets can be pinged before starting, guiding the user
+// through allowing it or probing them over TCP instead. Headless, there is
+// no one to ask, so the help is printed instead.
+func ensureICMP(addresses []string, netns string, headless bool) ([]string, icmpAccess, error) {
+	if runtime.GOOS != "linux" {
+		return addresses, icmpUnprivileged, nil
+	}
+	pinged := false
+	for _, address := range addresses {
+		pinged = pinged || isICMP(address)
+	}
+	if !pinged {
+		return addresses, icmpUnprivileged, nil
+	}
+	access := detectICMP(netns, addresses)
+	if access != icmpDenied {
+		return addresses, access, nil
+	}
+	if headless {
+		return nil, access, errors.New(permissionHelp())
+	}
+	setup := &permissionModel{netns: netns, addresses: addresses, access: access}
+	if _, err := tea.NewProgram(setup).Run(); err != nil {
+		return nil, access, err
+	}
+	switch {
+	case setup.choice == permissionTCP, setup.choice == permissionUDP:
+		probe := "tcp:443"
+		if setup.choice == permissionUDP {
+			probe = "udp"
+		}
+		probed := make([]string, len(addresses))
+		for i, address := range addresses {
+			probed[i] = address
+			if isICMP(address) {
+				probed[i] = probeAddress(address, probe)
+			}
+		}
+		return probed, icmpUnprivileged, nil
+	case setup.access == icmpDenied:
+		return nil, setup.access, errors.New("not allowed to ping")
+	}
+	return addresses, setup.access, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+const (
+	powerCheckInterval = 30 * time.Second
+	// Intervals are this many times longer on battery
+	lowPowerSlowdown = 4
+	// Minimum time between renders on battery
+	lowPowerRenderPeriod = 5 * time.Second
+)
+
+type powerMsg struct{ onBattery bool }
+
+func (m *model) checkPowerCmd(delay time.Duration) tea.Cmd {
+	return m.clock.Tick(delay, func(time.Time) tea.Msg {
+		return powerMsg{onBattery()}
+	})
+}
+
+func (m *model) lowPowerActive() bool {
+	return m.lowPower && m.onBattery
+}
+
+func (m *model) currentInterval() time.Duration {
+	if m.lowPowerActive() {
+		return m.interval * lowPowerSlowdown
+	}
+	return m.interval
+}
+
+// On battery only the focused target keeps being probed
+func (m *model) suspended(t *target) bool {
+	return m.lowPowerActive() && m.targets[m.focus] != t
+}
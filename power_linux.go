@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Report whether the machine runs on battery, that is, it has a battery and
+// no external power supply is online
+func onBattery() bool {
+	supplies, err := filepath.Glob("/sys/class/power_supply/*")
+	if err != nil {
+		return false
+	}
+	battery := false
+	for _, supply := range supplies {
+		kind, err := os.ReadFile(filepath.Join(supply, "type"))
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(string(kind)) {
+		case "Mains", "USB":
+			online, err := os.ReadFile(filepath.Join(supply, "online"))
+			if err == nil && strings.TrimSpace(string(online)) == "1" {
+				return false
+			}
+		case "Battery":
+			battery = true
+		}
+	}
+	return battery
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// Power supply detection is only implemented on linux
+func onBattery() bool {
+	return false
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func isTCP(address string) bool {
+	return strings.HasPrefix(address, "tcp://")
+}
+
+func isUDP(address string) bool {
+	return strings.HasPrefix(address, "udp://")
+}
+
+// Port that UDP probes are sent to unless given one, the first one
+// traceroute sends to, which is unlikely to be open
+const defaultUDPPort = "33434"
+
+// Parse a list of probes, such as icmp,tcp:443,https, where dns probes look
+// up the given name unless they name one of their own, and game servers are
+// queried on their usual port unless given one
+func parseProbes(list, qname string) ([]string, error) {
+	probes := strings.Split(list, ",")
+	for _, probe := range probes {
+		switch {
+		case probe == "icmp", probe == "http", probe == "https", probe == "dns", probe == "udp", probe == "source", probe == "minecraft":
+		case strings.HasPrefix(probe, "tcp:"), strings.HasPrefix(probe, "udp:"), strings.HasPrefix(probe, "source:"), strings.HasPrefix(probe, "minecraft:"):
+			_, portText, _ := strings.Cut(probe, ":")
+			port, err := strconv.Atoi(portText)
+			if err != nil || port < 1 || port > 65535 {
+				return nil, fmt.Errorf("probe %q must have a port between 1 and 65535", probe)
+			}
+		case strings.HasPrefix(probe, "dns:"):
+			if _, err := dnsmessage.NewName(strings.TrimPrefix(probe, "dns:") + "."); err != nil || probe == "dns:" {
+				return nil, fmt.Errorf("probe %q must have a name to look up", probe)
+			}
+		default:
+			return nil, fmt.Errorf("unknown probe %q, expected icmp, tcp:<port>, udp[:<port>], dns, dns:<name>, http, https, source[:<port>] or minecraft[:<port>]", probe)
+		}
+	}
+	for i, probe := range probes {
+		if probe == "dns" {
+			probes[i] = "dns:" + qname
+		}
+	}
+	return probes, nil
+}
+
+// Get the address that probes the host with the given probe
+func probeAddress(host, probe string) string {
+	switch {
+	case probe == "icmp":
+		return host
+	case strings.HasPrefix(probe, "tcp:"):
+		return "tcp://" + net.JoinHostPort(host, strings.TrimPrefix(probe, "tcp:"))
+	case strings.HasPrefix(probe, "udp"):
+		_, port, ok := strings.Cut(probe, ":")
+		if !ok {
+			port = defaultUDPPort
+		}
+		return "udp://" + net.JoinHostPort(host, port)
+	case strings.HasPrefix(probe, "dns:"):
+		return "dns://" + net.JoinHostPort(host, "53") + "/" + strings.TrimPrefix(probe, "dns:")
+	case strings.HasPrefix(probe, "source"), strings.HasPrefix(probe, "minecraft"):
+		game, port, ok := strings.Cut(probe, ":")
+		if !ok {
+			port = gamePorts[game]
+		}
+		return game + "://" + net.JoinHostPort(host, port)
+	default:
+		return probe + "://" + host + "/"
+	}
+}
+
+// Time how long it takes to open a TCP connection, which takes one round
+// trip. Refused connections count as lost.
+func (m *model) tcpCmd(ctx context.Context, t *target) tea.Cmd {
+	mark := m.mark
+	return func() tea.Msg {
+		parsed, err := url.Parse(t.address)
+		if err != nil {
+			return errMsg{err}
+		}
+		sent, start := m.clock.Now(), time.Now()
+		conn, err := probeDialer(mark).DialContext(ctx, "tcp", parsed.Host)
+		if err != nil {
+			return latencyMsg{t, math.NaN(), sent, sampleMeta{errClass: classifyError(err)}}
+		}
+		latency := time.Since(start).Seconds() * 1000
+		ip := conn.RemoteAddr().(*net.TCPAddr).IP.String()
+		conn.Close()
+		return latencyMsg{t, latency, sent, sampleMeta{ip: ip}}
+	}
+}
+
+// Time how long a datagram to a port of the host takes to be answered, which
+// a closed port does with an ICMP port unreachable. A connected socket reads
+// that as refused, so unlike pings this needs no privileges. Hosts whose
+// firewall drops datagrams to closed ports without a word can't be probed
+// this way, nor can those that limit how often they say unreachable.
+func (m *model) udpCmd(ctx context.Context, t *target) tea.Cmd {
+	mark := m.mark
+	return func() tea.Msg {
+		parsed, err := url.Parse(t.address)
+		if err != nil {
+			return errMsg{err}
+		}
+		host := parsed.Host
+		if parsed.Port() == "" {
+			host = net.JoinHostPort(parsed.Hostname(), defaultUDPPort)
+		}
+		sent := m.clock.Now()
+		conn, err := probeDialer(mark).DialContext(ctx, "udp", host)
+		if err != nil {
+			return latencyMsg{t, math.NaN(), sent, sampleMeta{errClass: classifyError(err)}}
+		}
+		defer conn.Close()
+		ip := conn.RemoteAddr().(*net.UDPAddr).IP.String()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		start := time.Now()
+		if _, err := conn.Write([]byte("pingback")); err != nil {
+			return latencyMsg{t, math.NaN(), sent, sampleMeta{ip: ip, errClass: classifyError(err)}}
+		}
+		_, err = conn.Read(make([]byte, 1500))
+		// An answer from an open port takes a round trip as well
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			return latencyMsg{t, time.Since(start).Seconds() * 1000, sent, sampleMeta{ip: ip}}
+		}
+		return latencyMsg{t, math.NaN(), sent, sampleMeta{ip: ip, errClass: classifyError(err)}}
+	}
+}
+
+// Get a dialer that tries addresses one after the other on the calling
+// goroutine, so its sockets are created in the network namespace of the
+// probe, marking them with the firewall mark unless it is 0
+func probeDialer(mark uint) *net.Dialer {
+	return &net.Dialer{FallbackDelay: -1, Control: markControl(mark)}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// How far the round trip measured by the clock may be from the one told by
+// the send time echoed in the reply
+const proxyTolerance = 2 * time.Millisecond
+
+// Check the round trip told by the send time echoed in a reply against the
+// one measured by the clock. The payload of an echo request carries the
+// time it was sent, which a host echoes back as is, so a reply whose time
+// doesn't match was made by something else, such as a middlebox answering
+// on behalf of the target with a payload of its own.
+func proxiedReply(measured, echoed time.Duration) bool {
+	return measured-echoed > proxyTolerance || echoed-measured > proxyTolerance
+}
+
+// Warn that replies of the target seem to come from something between
+func proxyHint(t *target) string {
+	return fmt.Sprintf(
+		"%d replies from %s carried a send time other than the one in the ping, so something between may be answering on its behalf, and its latency would be that of the middlebox.",
+		t.proxiedReplies, t.address)
+}
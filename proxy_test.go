@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProxiedReply(t *testing.T) {
+	tests := []struct {
+		measured, echoed time.Duration
+		proxied          bool
+	}{
+		{10 * time.Millisecond, 10 * time.Millisecond, false},
+		// The clock is read a little after the echoed time was written
+		{10*time.Millisecond + 300*time.Microsecond, 10 * time.Millisecond, false},
+		{12 * time.Millisecond, 10 * time.Millisecond, false},
+		// A middlebox close by answering with a payload of its own
+		{30 * time.Millisecond, 2 * time.Millisecond, true},
+		{2 * time.Millisecond, 30 * time.Millisecond, true},
+	}
+	for _, test := range tests {
+		if got := proxiedReply(test.measured, test.echoed); got != test.proxied {
+			t.Errorf("measured %v and echoed %v: proxied is %t, want %t", test.measured, test.echoed, got, test.proxied)
+		}
+	}
+}
+
+func TestProxiedRepliesCounted(t *testing.T) {
+	m, clock := newTestModel(t, []string{"10.0.0.1"}, time.Second, []int{4})
+	target := m.targets[0]
+	for _, proxied := range []bool{false, true, false, true} {
+		m.update(latencyMsg{target, 5, clock.Now(), sampleMeta{ttl: 56, proxied: proxied}})
+		clock.Advance(time.Second)
+	}
+	if target.proxiedReplies != 2 {
+		t.Errorf("counted %d proxied replies, want 2", target.proxiedReplies)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func runQuery(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	target := flags.String("target", "", "Target to query, all targets by default")
+	timeRange := flags.String("range", "", "Time range to query, as <from>..<to> where either end may be left out")
+	stat := flags.String("stat", "median", "Statistic to compute: count, loss, min, mean, median, max, jitter or p<percentile>")
+	step := flags.Duration("step", 0, "Compute the statistic for every step of this length, once over the whole range by default")
+	asJSON := flags.Bool("json", false, "Print JSON instead of a table")
+	timeFormatName := flags.String("time-format", "local", "How to show timestamps: iso8601, local or unix")
+	timezone := flags.String("timezone", "", "IANA time zone of timestamps and the range, defaults to the local one")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback query [-target=<address>] [-range=<from>..<to>] [-stat=<stat>] [-step=<duration>] [-json] <session>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	timeFormat, err := parseTimeFormat(*timeFormatName, *timezone)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	from, to, err := parseRange(*timeRange, timeFormat.zone())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	compute, err := parseStat(*stat)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var records []record
+	for _, path := range flags.Args() {
+		session, err := readSession(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		records = append(records, session...)
+	}
+	rows := query(records, *target, from, to, *step, timeFormat.zone(), compute)
+	if len(rows) == 0 {
+		fmt.Println("No samples in the range")
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		type row struct {
+			Time   time.Time `json:"timestamp"`
+			Target string    `json:"target"`
+			Stat   string    `json:"stat"`
+			Value  *float64  `json:"value"`
+		}
+		out := make([]row, len(rows))
+		for i, r := range rows {
+			out[i] = row{r.time, r.target, *stat, nil}
+			if !math.IsNaN(r.value) {
+				out[i].Value = &rows[i].value
+			}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(out)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "time\ttarget\t%s\n", *stat)
+	for _, r := range rows {
+		value := "-"
+		if !math.IsNaN(r.value) {
+			value = strconv.FormatFloat(r.value, 'f', 2, 64)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", timeFormat.format(r.time), r.target, value)
+	}
+	w.Flush()
+}
+
+// Parse a range of times such as 2024-05-01..2024-05-02, where a missing end
+// is unbounded and an end given as a date includes that whole day
+func parseRange(s string, location *time.Location) (time.Time, time.Time, error) {
+	if s == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	first, last, ok := strings.Cut(s, "..")
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("range %q is not of the form <from>..<to>", s)
+	}
+	var bounds [2]time.Time
+	for i, part := range []string{first, last} {
+		if part == "" {
+			continue
+		}
+		var err error
+		for _, layout := range []string{time.RFC3339, time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+			if bounds[i], err = time.ParseInLocation(layout, part, location); err == nil {
+				if i == 1 && layout == time.DateOnly {
+					bounds[i] = bounds[i].AddDate(0, 0, 1)
+				}
+				break
+			}
+		}
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%q is not a date or time such as 2024-05-01 or 2024-05-01 14:32", part)
+		}
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// Parse the name of a statistic into a function computing it
+func parseStat(name string) (func([]float64) float64, error) {
+	switch name {
+	case "count":
+		return func(data []float64) float64 { return float64(len(data)) }, nil
+	case "loss":
+		return func(data []float64) float64 { return summarize(data).lossPercent() }, nil
+	case "min":
+		return func(data []float64) float64 { return summarize(data).min }, nil
+	case "mean":
+		return func(data []float64) float64 { return summarize(data).mean }, nil
+	case "median":
+		return func(data []float64) float64 { return summarize(data).median }, nil
+	case "max":
+		return func(data []float64) float64 { return summarize(data).max }, nil
+	case "jitter":
+		return func(data []float64) float64 { return summarize(data).jitter }, nil
+	}
+	if p, err := strconv.ParseFloat(strings.TrimPrefix(name, "p"), 64); err == nil && strings.HasPrefix(name, "p") && p >= 0 && p <= 100 {
+		return func(data []float64) float64 {
+			var replies []float64
+			for _, latency := range data {
+				if !math.IsNaN(latency) {
+					replies = append(replies, latency)
+				}
+			}
+			sort.Float64s(replies)
+			return percentile(replies, p)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown statistic %q, expected count, loss, min, mean, median, max, jitter or p<percentile>", name)
+}
+
+type queryRow struct {
+	time   time.Time
+	target string
+	value  float64
+}
+
+// Compute the statistic for each target and step within the range, where a
+// zero time is unbounded and a zero step covers the whole range. Steps start
+// on the wall clock of the location, so days start at its midnight.
+func query(records []record, target string, from, to time.Time, step time.Duration, location *time.Location, compute func([]float64) float64) []queryRow {
+	type key struct {
+		target string
+		start  int64
+	}
+	buckets := make(map[key][]float64)
+	firsts := make(map[key]time.Time)
+	for _, rec := range records {
+		if rec.Event != "" || target != "" && rec.Target != target ||
+			!from.IsZero() && rec.Time.Before(from) || !to.IsZero() && !rec.Time.Before(to) {
+			continue
+		}
+		k := key{target: rec.Target}
+		if step > 0 {
+			k.start = truncateIn(rec.Time, step, location).UnixNano()
+		}
+		buckets[k] = append(buckets[k], rec.samples()...)
+		if first, ok := firsts[k]; !ok || rec.Time.Before(first) {
+			firsts[k] = rec.Time
+		}
+	}
+	rows := make([]queryRow, 0, len(buckets))
+	for k, data := range buckets {
+		start := firsts[k]
+		if step > 0 {
+			start = time.Unix(0, k.start)
+		}
+		rows = append(rows, queryRow{start, k.target, compute(data)})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].time.Equal(rows[j].time) {
+			return rows[i].time.Before(rows[j].time)
+		}
+		return rows[i].target < rows[j].target
+	})
+	return rows
+}
+
+// Truncate the time to a multiple of the step on the wall clock of the
+// location rather than since the zero time in UTC
+func truncateIn(t time.Time, step time.Duration, location *time.Location) time.Time {
+	t = t.In(location)
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).Truncate(step)
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), location)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	utc := func(s string) time.Time {
+		at, err := time.Parse(time.DateTime, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+	tests := []struct {
+		input    string
+		from, to time.Time
+		wantErr  bool
+	}{
+		{"", time.Time{}, time.Time{}, false},
+		// An end given as a date includes that day
+		{"2024-05-01..2024-05-02", utc("2024-05-01 00:00:00"), utc("2024-05-03 00:00:00"), false},
+		{"2024-05-01..2024-05-02 14:30", utc("2024-05-01 00:00:00"), utc("2024-05-02 14:30:00"), false},
+		{"2024-05-01 08:00:00..", utc("2024-05-01 08:00:00"), time.Time{}, false},
+		{"..2024-05-01T08:00:00Z", time.Time{}, utc("2024-05-01 08:00:00"), false},
+		{"2024-05-01", time.Time{}, time.Time{}, true},
+		{"yesterday..today", time.Time{}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		from, to, err := parseRange(test.input, time.UTC)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseRange(%q) returned error %v", test.input, err)
+			continue
+		}
+		if !from.Equal(test.from) || !to.Equal(test.to) {
+			t.Errorf("parseRange(%q) = %v..%v, want %v..%v", test.input, from, to, test.from, test.to)
+		}
+	}
+}
+
+func TestQueryStepsStartInLocation(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	rtt := 10.0
+	var records []record
+	// Either side of midnight in Kolkata, which is 18:30 in UTC
+	for _, at := range []string{"2024-05-01T18:00:00Z", "2024-05-01T19:00:00Z"} {
+		sent, _ := time.Parse(time.RFC3339, at)
+		records = append(records, record{Time: sent, Target: "example.com", RTT: &rtt})
+	}
+	count := func(data []float64) float64 { return float64(len(data)) }
+	rows := query(records, "", time.Time{}, time.Time{}, 24*time.Hour, location, count)
+	if len(rows) != 2 {
+		t.Fatalf("got %d days, want the samples on either side of midnight apart", len(rows))
+	}
+	if want := time.Date(2024, 5, 2, 0, 0, 0, 0, location); !rows[1].time.Equal(want) {
+		t.Errorf("the second day starts at %v, want %v", rows[1].time, want)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Number of recent samples searched for signs of rate limiting
+const rateLimitWindow = 200
+
+// Detect runs of loss that start with a fixed period, which is typical of a
+// remote host rate limiting its ICMP replies rather than of congestion.
+// Returns the period in samples.
+func rateLimitPeriod(data []float64) (int, bool) {
+	var runStarts []int
+	lost := 0
+	for i, latency := range data {
+		if !math.IsNaN(latency) {
+			continue
+		}
+		lost++
+		if i == 0 || !math.IsNaN(data[i-1]) {
+			runStarts = append(runStarts, i)
+		}
+	}
+	if len(runStarts) < 4 || lost*2 > len(data) {
+		return 0, false
+	}
+	gaps := make([]float64, len(runStarts)-1)
+	mean := 0.0
+	for i := range gaps {
+		gaps[i] = float64(runStarts[i+1] - runStarts[i])
+		mean += gaps[i] / float64(len(gaps))
+	}
+	variance := 0.0
+	for _, gap := range gaps {
+		variance += (gap - mean) * (gap - mean) / float64(len(gaps))
+	}
+	if math.Sqrt(variance)/mean > 0.1 {
+		return 0, false
+	}
+	return int(math.Round(mean)), true
+}
+
+func (m *model) renderHints() string {
+	var lines []string
+	for _, t := range m.targets {
+		recent := t.latencyData[max(0, len(t.latencyData)-rateLimitWindow):]
+		if period, ok := rateLimitPeriod(recent); ok {
+			lines = append(lines, fmt.Sprintf(
+				"%s loses packets every %d pings, which suggests it rate limits ICMP rather than being unhealthy. A longer -delay should make the loss disappear.",
+				t.address, period))
+		} else if m.blocked(t) {
+			lines = append(lines, fmt.Sprintf(
+				"%s stopped replying after %d pings while other targets still reply, it may be dropping us for pinging too often. Try a longer -delay.",
+				t.address, len(t.latencyData)-t.lossStreak))
+		}
+		if t.proxiedReplies > 0 {
+			lines = append(lines, proxyHint(t))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return lipgloss.NewStyle().Width(m.windowWidth).Render(
+		lipgloss.JoinVertical(lipgloss.Left, append([]string{"Hints:"}, lines...)...))
+}
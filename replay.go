@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// Time between steps of a replay
+	replayFrame = 50 * time.Millisecond
+	// Gaps in a recording longer than this are skipped, such as while
+	// pingback wasn't running
+	replayGap = time.Minute
+)
+
+// A recorded session fed through the model instead of probing, on a clock
+// that runs a number of times faster than the wall clock
+type replay struct {
+	path      string
+	records   []record
+	next      int
+	speed     float64
+	clock     *manualClock
+	addresses []string
+	// The median time between samples of a target
+	interval time.Duration
+}
+
+type replayStepMsg struct{}
+
+func loadReplay(path string, speed float64) (*replay, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("-replay-speed must be positive")
+	}
+	records, err := readSession(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	r := &replay{path: path, records: records, speed: speed}
+	var gaps []time.Duration
+	last := make(map[string]time.Time)
+	for _, rec := range records {
+		if rec.Event != "" {
+			continue
+		}
+		if !slices.Contains(r.addresses, rec.Target) {
+			r.addresses = append(r.addresses, rec.Target)
+		}
+		if previous, ok := last[rec.Target]; ok && rec.Count == 0 {
+			gaps = append(gaps, rec.Time.Sub(previous))
+		}
+		last[rec.Target] = rec.Time
+	}
+	if len(r.addresses) == 0 {
+		return nil, fmt.Errorf("%s holds no samples to replay", path)
+	}
+	r.interval = time.Second
+	if len(gaps) > 0 {
+		slices.Sort(gaps)
+		r.interval = max(gaps[len(gaps)/2], time.Millisecond)
+	}
+	r.clock = newManualClock(records[0].Time)
+	return r, nil
+}
+
+// Frames pass on the wall clock, as they are what moves the clock of the
+// replay
+func replayStepCmd() tea.Cmd {
+	return tea.Tick(replayFrame, func(time.Time) tea.Msg {
+		return replayStepMsg{}
+	})
+}
+
+// Advance the clock of the replay by a frame, feeding the model the samples
+// and events recorded up to then as if they had just happened
+func (m *model) stepReplay() tea.Cmd {
+	r := m.replay
+	if r.next < len(r.records) && r.records[r.next].Time.Sub(r.clock.Now()) > replayGap {
+		r.clock.Set(r.records[r.next].Time)
+	} else {
+		r.clock.Advance(time.Duration(float64(replayFrame) * r.speed))
+	}
+	var cmds []tea.Cmd
+	for ; r.next < len(r.records) && !r.records[r.next].Time.After(r.clock.Now()); r.next++ {
+		rec := r.records[r.next]
+		switch rec.Event {
+		case "":
+			for _, t := range m.targets {
+				if t.address != rec.Target {
+					continue
+				}
+				for _, latency := range rec.samples() {
+					_, cmd := m.update(latencyMsg{t, latency, rec.Time, rec.meta()})
+					cmds = append(cmds, cmd)
+				}
+			}
+		case "metadata":
+			if _, ok := m.targetMetadata[rec.Target]; !ok {
+				m.targetMetadata[rec.Target] = rec.Metadata
+			}
+		default:
+			for kind, name := range eventKindNames {
+				if name == rec.Event {
+					m.events = append(m.events, event{rec.Time, kind, rec.Target, rec.Label})
+				}
+			}
+		}
+	}
+	if r.next == len(r.records) {
+		m.status = "Replay finished"
+		return tea.Batch(cmds...)
+	}
+	return tea.Batch(append(cmds, replayStepCmd())...)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Differences with a p-value below this are reported as significant
+const significanceLevel = 0.05
+
+func runReport(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "diff":
+			runReportDiff(args[1:])
+			return
+		case "md":
+			runReportMarkdown(args[1:])
+			return
+		}
+	}
+	fmt.Println("Usage: pingback report diff <before> <after>")
+	fmt.Println("       pingback report md [-o <file>] [-svg] <session>...")
+	os.Exit(1)
+}
+
+func runReportDiff(args []string) {
+	flags := flag.NewFlagSet("report diff", flag.ExitOnError)
+	outageThreshold := flags.Int("outage-after", 3, "Number of consecutive losses that count as an outage")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback report diff [-outage-after=<number>] <before> <after>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	var sessions [2]map[string][]float64
+	for i, path := range flags.Args() {
+		records, err := readSession(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sessions[i] = samplesByTarget(records)
+	}
+	before, after := sessions[0], sessions[1]
+
+	var targets []string
+	for target := range before {
+		if _, ok := after[target]; ok {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	if len(targets) == 0 {
+		fmt.Println("The sessions have no targets in common")
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, target := range targets {
+		a, b := before[target], after[target]
+		sa, sb := summarize(a), summarize(b)
+		fmt.Fprintf(w, "%s\tbefore\tafter\tchange\n", target)
+		fmt.Fprintf(w, "  samples\t%d\t%d\t\n", sa.count, sb.count)
+		for _, p := range []float64{50, 95, 99} {
+			pa, pb := percentile(replies(a), p), percentile(replies(b), p)
+			fmt.Fprintf(w, "  p%g ms\t%.2f\t%.2f\t%s\n", p, pa, pb, formatChange(pa, pb))
+		}
+		fmt.Fprintf(w, "  jitter ms\t%.2f\t%.2f\t%s\n", sa.jitter, sb.jitter, formatChange(sa.jitter, sb.jitter))
+		fmt.Fprintf(w, "  loss %%\t%.2f\t%.2f\t%+.2f points\n", sa.lossPercent(), sb.lossPercent(), sb.lossPercent()-sa.lossPercent())
+		fmt.Fprintf(w, "  outages\t%d\t%d\t%+d\n", countOutages(a, *outageThreshold), countOutages(b, *outageThreshold),
+			countOutages(b, *outageThreshold)-countOutages(a, *outageThreshold))
+		fmt.Fprintf(w, "  latency\t\t\t%s\n", significance(mannWhitney(replies(a), replies(b))))
+		fmt.Fprintf(w, "  loss\t\t\t%s\n", significance(proportionTest(sa.lost, sa.count, sb.lost, sb.count)))
+		fmt.Fprintln(w, "\t\t\t")
+	}
+	w.Flush()
+	var only []string
+	for _, session := range sessions {
+		for target := range session {
+			if _, ok := before[target]; !ok {
+				only = append(only, target)
+			} else if _, ok := after[target]; !ok {
+				only = append(only, target)
+			}
+		}
+	}
+	if len(only) > 0 {
+		sort.Strings(only)
+		fmt.Printf("Only in one of the sessions: %s\n", strings.Join(only, ", "))
+	}
+}
+
+// Gather the samples of each target in time order
+func samplesByTarget(records []record) map[string][]float64 {
+	records = append([]record(nil), records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	samples := make(map[string][]float64)
+	for _, rec := range records {
+		if rec.Event == "" {
+			samples[rec.Target] = append(samples[rec.Target], rec.samples()...)
+		}
+	}
+	return samples
+}
+
+// Get the sorted latencies of the replies among the samples
+func replies(data []float64) []float64 {
+	var received []float64
+	for _, latency := range data {
+		if !math.IsNaN(latency) {
+			received = append(received, latency)
+		}
+	}
+	sort.Float64s(received)
+	return received
+}
+
+func countOutages(data []float64, threshold int) int {
+	outages, streak := 0, 0
+	for _, latency := range data {
+		if !math.IsNaN(latency) {
+			streak = 0
+			continue
+		}
+		streak++
+		if streak == threshold {
+			outages++
+		}
+	}
+	return outages
+}
+
+func formatChange(before, after float64) string {
+	if math.IsNaN(before) || math.IsNaN(after) || before == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", 100*(after-before)/before)
+}
+
+func significance(p float64) string {
+	switch {
+	case math.IsNaN(p):
+		return "too few samples to compare"
+	case p < significanceLevel:
+		return fmt.Sprintf("significant change (p=%.3g)", p)
+	default:
+		return fmt.Sprintf("no significant change (p=%.3g)", p)
+	}
+}
+
+// Test whether the latencies of one sorted sample tend to differ from those
+// of the other with the Mann-Whitney U test, returning the two-sided p-value
+// of the normal approximation
+func mannWhitney(a, b []float64) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if len(a) < 8 || len(b) < 8 {
+		return math.NaN()
+	}
+	// Sum the ranks of a in the merged samples, ties sharing their mean rank
+	rankSum := 0.0
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		value := math.Inf(1)
+		if i < len(a) {
+			value = a[i]
+		}
+		if j < len(b) {
+			value = math.Min(value, b[j])
+		}
+		start := i + j
+		inA := 0
+		for i < len(a) && a[i] == value {
+			i++
+			inA++
+		}
+		for j < len(b) && b[j] == value {
+			j++
+		}
+		meanRank := float64(start+1+i+j) / 2
+		rankSum += meanRank * float64(inA)
+	}
+	u := rankSum - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	deviation := math.Sqrt(n1 * n2 * (n1 + n2 + 1) / 12)
+	return math.Erfc(math.Abs(u-mean) / deviation / math.Sqrt2)
+}
+
+// Test whether two loss rates differ with a two-proportion z-test, returning
+// the two-sided p-value
+func proportionTest(lostA, countA, lostB, countB int) float64 {
+	if countA == 0 || countB == 0 {
+		return math.NaN()
+	}
+	pooled := float64(lostA+lostB) / float64(countA+countB)
+	deviation := math.Sqrt(pooled * (1 - pooled) * (1/float64(countA) + 1/float64(countB)))
+	if deviation == 0 {
+		return 1
+	}
+	z := (float64(lostA)/float64(countA) - float64(lostB)/float64(countB)) / deviation
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Size of the charts of Markdown reports, and the margins their axes are
+// labeled in
+const (
+	svgWidth       = 720
+	svgHeight      = 160
+	svgLeftMargin  = 64
+	svgBottomSpace = 20
+)
+
+// A sample of a session at the time it was sent
+type timedSample struct {
+	at      time.Time
+	latency float64
+}
+
+// A run of lost samples of a target
+type lossRun struct {
+	start time.Time
+	end   time.Time
+	lost  int
+}
+
+func runReportMarkdown(args []string) {
+	flags := flag.NewFlagSet("report md", flag.ExitOnError)
+	output := flags.String("o", "", "File to write the report to instead of the standard output")
+	outageThreshold := flags.Int("outage-after", 3, "Number of consecutive losses that count as an outage")
+	svg := flags.Bool("svg", false, "Embed a chart of the latency of each target as inline SVG")
+	timeFormatName := flags.String("time-format", "local", "How to show timestamps: iso8601, local or unix")
+	timezone := flags.String("timezone", "", "IANA time zone of timestamps, defaults to the local one")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback report md [-o <file>] [-outage-after=<number>] [-svg] [-time-format=<format>] [-timezone=<zone>] <session>...")
+		fmt.Fprintln(flags.Output(), "Writes a Markdown report of the sessions, to paste into a ticket or a wiki page")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	timeFormat, err := parseTimeFormat(*timeFormatName, *timezone)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var records []record
+	for _, path := range flags.Args() {
+		session, err := readSession(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		records = append(records, session...)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeMarkdownReport(w, records, *outageThreshold, *svg, timeFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Write the statistics of every target, in the order they were first
+// probed, their outages and the events of the session as Markdown
+func writeMarkdownReport(w io.Writer, records []record, outageThreshold int, svg bool, timeFormat timeFormat) error {
+	var targets []string
+	samples := make(map[string][]timedSample)
+	var events []record
+	for _, rec := range records {
+		switch {
+		case rec.Event == "":
+			if _, ok := samples[rec.Target]; !ok {
+				targets = append(targets, rec.Target)
+			}
+			for _, latency := range rec.samples() {
+				samples[rec.Target] = append(samples[rec.Target], timedSample{rec.Time, latency})
+			}
+		case rec.Event != "metadata":
+			events = append(events, rec)
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("the sessions hold no samples")
+	}
+
+	var b strings.Builder
+	start, end := records[0].Time, records[len(records)-1].Time
+	fmt.Fprintf(&b, "# Pingback report\n\n")
+	fmt.Fprintf(&b, "From %s to %s (%v).\n\n", timeFormat.format(start), timeFormat.format(end),
+		end.Sub(start).Round(time.Second))
+
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| Target | Samples | Loss % | Min ms | Median ms | p95 ms | p99 ms | Max ms | Jitter ms | Outages |\n")
+	b.WriteString("|---|--:|--:|--:|--:|--:|--:|--:|--:|--:|\n")
+	for _, target := range targets {
+		latencies := make([]float64, len(samples[target]))
+		for i, sample := range samples[target] {
+			latencies[i] = sample.latency
+		}
+		stats := summarize(latencies)
+		fmt.Fprintf(&b, "| %s | %d | %.2f | %s | %s | %s | %s | %s | %s | %d |\n", markdownEscape(target), stats.count,
+			stats.lossPercent(), markdownNumber(stats.min), markdownNumber(stats.median), markdownNumber(stats.p95),
+			markdownNumber(percentile(replies(latencies), 99)), markdownNumber(stats.max), markdownNumber(stats.jitter),
+			countOutages(latencies, outageThreshold))
+	}
+
+	b.WriteString("\n## Outages\n\n")
+	anyOutage := false
+	for _, target := range targets {
+		runs := lossRuns(samples[target], outageThreshold)
+		if len(runs) == 0 {
+			continue
+		}
+		if !anyOutage {
+			b.WriteString("| Target | Start | Duration | Lost |\n|---|---|--:|--:|\n")
+			anyOutage = true
+		}
+		for _, run := range runs {
+			fmt.Fprintf(&b, "| %s | %s | %v | %d |\n", markdownEscape(target), timeFormat.format(run.start),
+				run.end.Sub(run.start).Round(time.Second), run.lost)
+		}
+	}
+	if !anyOutage {
+		fmt.Fprintf(&b, "No target lost %d samples in a row.\n", outageThreshold)
+	}
+
+	if len(events) > 0 {
+		b.WriteString("\n## Events\n\n| Time | Event | Target | Note |\n|---|---|---|---|\n")
+		for _, event := range events {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", timeFormat.format(event.Time), event.Event,
+				markdownEscape(event.Target), markdownEscape(event.Label))
+		}
+	}
+
+	if svg {
+		b.WriteString("\n## Charts\n")
+		for _, target := range targets {
+			fmt.Fprintf(&b, "\n### %s\n\n%s\n", markdownEscape(target), svgChart(samples[target], timeFormat))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Find the runs of at least the threshold of lost samples, each ending
+// with the first reply after it, or the last loss
+func lossRuns(samples []timedSample, threshold int) []lossRun {
+	var runs []lossRun
+	var run lossRun
+	for _, sample := range samples {
+		if math.IsNaN(sample.latency) {
+			if run.lost == 0 {
+				run.start = sample.at
+			}
+			run.lost++
+			run.end = sample.at
+			continue
+		}
+		if run.lost >= threshold {
+			run.end = sample.at
+			runs = append(runs, run)
+		}
+		run = lossRun{}
+	}
+	if run.lost >= threshold {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// Escape the characters that would end a cell of a Markdown table
+func markdownEscape(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
+
+func markdownNumber(value float64) string {
+	if math.IsNaN(value) {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+// Draw the latencies as an SVG line chart on a logarithmic scale, with a
+// column of pixels to each span of time holding the worst of its replies,
+// and losses marked in red below the line
+func svgChart(samples []timedSample, timeFormat timeFormat) string {
+	plotWidth, plotHeight := svgWidth-svgLeftMargin, svgHeight-svgBottomSpace
+	start, end := samples[0].at, samples[len(samples)-1].at
+	span := max(end.Sub(start), time.Second)
+	worst := make([]float64, plotWidth)
+	lost := make([]bool, plotWidth)
+	for i := range worst {
+		worst[i] = math.NaN()
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, sample := range samples {
+		x := min(plotWidth-1, int(float64(plotWidth)*float64(sample.at.Sub(start))/float64(span)))
+		if math.IsNaN(sample.latency) {
+			lost[x] = true
+			continue
+		}
+		if math.IsNaN(worst[x]) || sample.latency > worst[x] {
+			worst[x] = sample.latency
+		}
+		low, high = math.Min(low, sample.latency), math.Max(high, sample.latency)
+	}
+	if math.IsInf(low, 1) {
+		low, high = 1, 10
+	}
+	low, high = math.Max(low, 0.001), math.Max(high, 0.001)
+	if high/low < 1.1 {
+		low, high = low/1.05, high*1.05
+	}
+	y := func(latency float64) float64 {
+		ratio := math.Log(math.Max(latency, low)/low) / math.Log(high/low)
+		return float64(plotHeight) - 1 - ratio*float64(plotHeight-2)
+	}
+
+	var path strings.Builder
+	drawing := false
+	for x, latency := range worst {
+		if lost[x] {
+			drawing = false
+		}
+		if math.IsNaN(latency) {
+			// Columns between samples are bridged, losses aren't
+			continue
+		}
+		command := "L"
+		if !drawing {
+			command = "M"
+		}
+		fmt.Fprintf(&path, "%s%d %.1f ", command, svgLeftMargin+x, y(latency))
+		drawing = true
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`,
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	b.WriteString("\n")
+	fmt.Fprintf(&b, `<rect x="%d" y="0" width="%d" height="%d" fill="none" stroke="#cccccc"/>`+"\n", svgLeftMargin, plotWidth, plotHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="11" text-anchor="end">%.1f ms</text>`+"\n", svgLeftMargin-4, high)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%.1f ms</text>`+"\n", svgLeftMargin-4, plotHeight, low)
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", svgLeftMargin, svgHeight-4, timeFormat.format(start))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", svgWidth, svgHeight-4, timeFormat.format(end))
+	if path.Len() > 0 {
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#466be3" stroke-width="1"/>`+"\n", strings.TrimSpace(path.String()))
+	}
+	for x, l := range lost {
+		if l {
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="6" fill="#d23105"/>`+"\n", svgLeftMargin+x, plotHeight-6)
+		}
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// The charts of a stream as saved by -resume
+type savedStream struct {
+	Counter    int
+	Latencies  []float64
+	Timestamps []time.Time
+	Min, Max   float64
+	Aggregates [][][]float64
+}
+
+// The charts of every stream, which are only valid with the same groups and
+// aggregations they were saved with
+type savedCharts struct {
+	AggregateCounts []int
+	AggregateRows   []int
+	Min, Max        float64
+	Streams         map[string]savedStream
+}
+
+// Name the stream of every target by its address and every differential by
+// the addresses it is the difference of
+func (m *model) namedStreams() map[string]*stream {
+	streams := make(map[string]*stream)
+	for _, t := range m.targets {
+		streams[t.address] = t.stream
+	}
+	for _, d := range m.differentials {
+		streams[d.minuend.address+" - "+d.subtrahend.address] = d.stream
+	}
+	return streams
+}
+
+func (m *model) aggregateRows() []int {
+	rows := make([]int, len(m.aggregateCounts))
+	for i, count := range m.aggregateCounts {
+		rows[i] = aggregationRows(m.aggregations, count)
+	}
+	return rows
+}
+
+// Save the charts, replacing the file atomically so a crash while saving
+// leaves the last save intact
+func (m *model) saveCharts(path string) error {
+	charts := savedCharts{m.aggregateCounts, m.aggregateRows(), m.minLatency, m.maxLatency, make(map[string]savedStream)}
+	for name, s := range m.namedStreams() {
+		charts.Streams[name] = savedStream{s.counter, s.latencyData, s.timestamps, s.minLatency, s.maxLatency, s.aggregateData}
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	err = gob.NewEncoder(file).Encode(charts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Quit when the terminal goes away, instead of dying, so the charts are
+// saved
+func quitOnHangup(p *tea.Program) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		<-hangup
+		p.Quit()
+	}()
+}
+
+// Load the charts saved to the path, where a missing file starts afresh.
+// Streams that weren't saved start empty.
+func (m *model) resumeCharts(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var charts savedCharts
+	if err := gob.NewDecoder(file).Decode(&charts); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if !slices.Equal(charts.AggregateCounts, m.aggregateCounts) || !slices.Equal(charts.AggregateRows, m.aggregateRows()) {
+		return fmt.Errorf("%s was saved with other -group, -aggregates or aggregation settings", path)
+	}
+	m.minLatency = math.Min(m.minLatency, charts.Min)
+	m.maxLatency = math.Max(m.maxLatency, charts.Max)
+	m.gradientUpdate = true
+	for name, s := range m.namedStreams() {
+		saved, ok := charts.Streams[name]
+		if !ok {
+			continue
+		}
+		s.counter = saved.Counter
+		s.latencyData = saved.Latencies
+		s.timestamps = saved.Timestamps
+		s.minLatency = saved.Min
+		s.maxLatency = saved.Max
+		s.aggregateData = saved.Aggregates
+		// The loss and stddev windows may have changed
+		s.lossData = nil
+		if m.lossWindow > 0 {
+			for i := range s.latencyData {
+				s.lossData = append(s.lossData, lossRate(s.latencyData[max(0, i+1-m.lossWindow):i+1]))
+			}
+		}
+		s.stddevData = nil
+		if m.stddevWindow > 0 {
+			for i := range s.latencyData {
+				s.stddevData = append(s.stddevData, relativeStddev(s.latencyData[max(0, i+1-m.stddevWindow):i+1]))
+			}
+		}
+	}
+	return nil
+}
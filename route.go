@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// How long to wait for each hop to answer
+const traceTimeout = time.Second
+
+// Find the hops on the way to the destination by sending pings that live for
+// one more hop each time, up to the given number of hops or until the
+// destination answers, which is left out. Hops that don't answer are empty.
+// Reading the answers of the hops needs a raw socket.
+func traceRoute(destination string, hops int) ([]string, error) {
+	addr, err := net.ResolveIPAddr("ip4", destination)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("tracing the route needs root or CAP_NET_RAW: %w", err)
+	}
+	defer conn.Close()
+	id := os.Getpid() & 0xffff
+	buffer := make([]byte, 1500)
+	var route []string
+	for ttl := 1; ttl <= hops; ttl++ {
+		if err := conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+			return nil, err
+		}
+		request, err := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: ttl}}).Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.WriteTo(request, addr); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(traceTimeout))
+		hop := ""
+	read:
+		for {
+			n, peer, err := conn.ReadFrom(buffer)
+			if err != nil {
+				break
+			}
+			message, err := icmp.ParseMessage(1, buffer[:n])
+			if err != nil {
+				continue
+			}
+			switch body := message.Body.(type) {
+			case *icmp.Echo:
+				if message.Type == ipv4.ICMPTypeEchoReply && body.ID == id && body.Seq == ttl {
+					return route, nil
+				}
+			case *icmp.TimeExceeded:
+				if echoID(body.Data) == id {
+					hop = peer.String()
+					break read
+				}
+			}
+		}
+		route = append(route, hop)
+	}
+	return route, nil
+}
+
+// Get the ID of the echo request whose IP header and start are quoted in an
+// ICMP error, or -1
+func echoID(quoted []byte) int {
+	if len(quoted) < 1 {
+		return -1
+	}
+	start := int(quoted[0]&0x0f) * 4
+	if len(quoted) < start+6 {
+		return -1
+	}
+	return int(binary.BigEndian.Uint16(quoted[start+4:]))
+}
+
+type routeMsg struct {
+	hops []string
+	err  error
+}
+
+func (m *model) traceRouteCmd(destination string, hops int, delay time.Duration) tea.Cmd {
+	return m.clock.Tick(delay, func(time.Time) tea.Msg {
+		route, err := traceRoute(destination, hops)
+		return routeMsg{route, err}
+	})
+}
+
+// Point the targets of the hops at the addresses of a new trace of the route.
+// Hops that didn't answer keep their address. The probes of a hop whose
+// address changed show it as a new address of the hop.
+func (m *model) updateRoute(msg routeMsg) tea.Cmd {
+	if msg.err != nil {
+		m.status = fmt.Sprintf("Tracing the route to %s failed: %v", m.routeDestination, msg.err)
+	}
+	for i, hop := range msg.hops {
+		if t, ok := m.hopTargets[i]; ok && hop != "" {
+			t.hop = hop
+		}
+	}
+	return m.traceRouteCmd(m.routeDestination, m.maxHop()+1, m.routeRefresh)
+}
+
+func (m *model) maxHop() int {
+	last := 0
+	for i := range m.hopTargets {
+		last = max(last, i)
+	}
+	return last
+}
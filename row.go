@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// A cell of a chart row, a glyph drawn in a foreground or background color,
+// where an empty color is the terminal's own
+type cell struct {
+	glyph      string
+	color      lipgloss.Color
+	background bool
+}
+
+// Lost samples are a purple X
+var lostCell = cell{"X", "#600060", true}
+
+// Render a row of cells as a single line with one escape sequence per run of
+// cells that look the same in the terminal, rather than styling every cell on
+// its own, which makes frames large and slow over slow links
+func renderRow(cells []cell) string {
+	profile := lipgloss.ColorProfile()
+	var row strings.Builder
+	// The sequence in effect and whether it colors the background
+	current, background := "", false
+	for _, c := range cells {
+		sequence := ""
+		if c.color != "" {
+			sequence = profile.Color(string(c.color)).Sequence(c.background)
+		}
+		if sequence != current || (sequence != "" && c.background != background) {
+			switch {
+			case sequence == "":
+				row.WriteString("\x1b[0m")
+			case current != "" && c.background != background:
+				// Clear the other color before switching between foreground and
+				// background
+				row.WriteString("\x1b[0;" + sequence + "m")
+			default:
+				row.WriteString("\x1b[" + sequence + "m")
+			}
+			current, background = sequence, c.background
+		}
+		row.WriteString(c.glyph)
+	}
+	if current != "" {
+		row.WriteString("\x1b[0m")
+	}
+	return row.String()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Number of the most recent samples of a target whose metadata is kept
+const maxSampleMeta = 1 << 16
+
+// What a probe found out about a sample besides its latency
+type sampleMeta struct {
+	// The address that answered, or was asked
+	ip string
+	// Timings of the stages of HTTP probes
+	stages []float64
+	// Time to live of the reply, 0 when unknown
+	ttl int
+	// Why the sample was lost, such as timeout or refused
+	errClass string
+	// Size of the body of HTTP responses, and the milliseconds from its
+	// first byte until it was read
+	size     int64
+	transfer float64
+	// Whether the reply seems to have been made by something other than the
+	// target, as the send time it echoed disagreed with the clock
+	proxied bool
+	// Players on a game server, and how many it has room for, which is 0
+	// when unknown
+	players    int
+	maxPlayers int
+	// Milliseconds after which a reply came that was first counted as lost,
+	// 0 when none did
+	late float64
+}
+
+// Tell why a probe failed, in a word
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	case errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	return "error"
+}
+
+// Keep the metadata of the newest sample of the target, starting afresh
+// when samples were added without metadata, such as by a backfill
+func (t *target) appendMeta(meta sampleMeta) {
+	n := t.counter - 1
+	if t.metaFrom+len(t.meta) != n {
+		clear(t.meta)
+		t.meta = t.meta[:0]
+		t.metaFrom = n
+	}
+	t.meta = append(t.meta, meta)
+	kept := len(t.meta)
+	t.meta = trimHistory(t.meta, maxSampleMeta)
+	t.metaFrom += kept - len(t.meta)
+}
+
+// Replace the metadata of the nth sample ever appended to the target, if
+// it's still kept
+func (t *target) setMeta(n int, meta sampleMeta) {
+	if n >= t.metaFrom && n < t.metaFrom+len(t.meta) {
+		t.meta[n-t.metaFrom] = meta
+	}
+}
+
+// Get the metadata of the nth sample ever appended to the target
+func (t *target) metaOf(n int) (sampleMeta, bool) {
+	if n < t.metaFrom || n >= t.metaFrom+len(t.meta) {
+		return sampleMeta{}, false
+	}
+	return t.meta[n-t.metaFrom], true
+}
+
+// Describe the metadata, such as "from 192.0.2.1, TTL 57"
+func (meta sampleMeta) String() string {
+	var parts []string
+	if meta.errClass != "" {
+		parts = append(parts, meta.errClass)
+	}
+	if meta.ip != "" {
+		parts = append(parts, "from "+meta.ip)
+	}
+	if meta.ttl > 0 {
+		parts = append(parts, fmt.Sprintf("TTL %d", meta.ttl))
+	}
+	if meta.size > 0 {
+		parts = append(parts, formatBytes(meta.size))
+	}
+	if meta.maxPlayers > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d players", meta.players, meta.maxPlayers))
+	}
+	if meta.proxied {
+		parts = append(parts, "proxied reply suspected")
+	}
+	if meta.late > 0 {
+		parts = append(parts, fmt.Sprintf("late, %.0f ms", meta.late))
+	}
+	return strings.Join(parts, ", ")
+}
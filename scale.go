@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The latencies that the ends of the color gradient stand for
+type scale struct {
+	min float64
+	max float64
+}
+
+func (s scale) String() string {
+	return fmt.Sprintf("%g-%g ms", s.min, s.max)
+}
+
+// Parse a scale given as <min>-<max> in milliseconds
+func parseScale(value string) (scale, error) {
+	low, high, ok := strings.Cut(strings.TrimSuffix(value, "ms"), "-")
+	min, minErr := strconv.ParseFloat(strings.TrimSpace(low), 64)
+	max, maxErr := strconv.ParseFloat(strings.TrimSpace(high), 64)
+	if !ok || minErr != nil || maxErr != nil || min <= 0 || max <= min {
+		return scale{}, fmt.Errorf("scale %q is not of the form <min>-<max> in milliseconds, such as 1-20", value)
+	}
+	return scale{min, max}, nil
+}
+
+// Percentiles of recent latencies that the ends of a scale stand for
+type percentileRange struct {
+	low  float64
+	high float64
+}
+
+// Parse percentiles given as <low>-<high>, such as 1-99
+func parsePercentileRange(value string) (percentileRange, error) {
+	low, high, ok := strings.Cut(value, "-")
+	l, lowErr := strconv.ParseFloat(strings.TrimSpace(low), 64)
+	h, highErr := strconv.ParseFloat(strings.TrimSpace(high), 64)
+	if !ok || lowErr != nil || highErr != nil || l < 0 || h > 100 || h <= l {
+		return percentileRange{}, fmt.Errorf("-scale-percentiles %q is not of the form <low>-<high> with 0 <= low < high <= 100, such as 1-99", value)
+	}
+	return percentileRange{l, h}, nil
+}
+
+// Number of samples of a target between updates of the scales spanning
+// percentiles
+const percentileScaleRefresh = 8
+
+// How far in proportion an end of a scale spanning percentiles moves before
+// the charts are recolored, so colors don't shift with every sample
+const percentileScaleSlack = 0.05
+
+// Span the scales over the percentiles of the latencies shown live, leaving
+// outliers to the colors of the ends
+func (m *model) updatePercentileScales() {
+	recent := func(s *stream) []float64 {
+		data := s.latencyData[max(0, len(s.latencyData)-m.windowWidth*m.zoom):]
+		sorted := make([]float64, 0, len(data))
+		for _, latency := range data {
+			if latency > 0 {
+				sorted = append(sorted, latency)
+			}
+		}
+		slices.Sort(sorted)
+		return sorted
+	}
+	update := func(current *scale, sorted []float64) bool {
+		next := scale{percentile(sorted, m.scalePercentiles.low), percentile(sorted, m.scalePercentiles.high)}
+		if len(sorted) == 0 || next.max <= next.min {
+			return false
+		}
+		moved := func(from, to float64) bool {
+			return math.Abs(to-from) > percentileScaleSlack*from
+		}
+		if current.max > 0 && !moved(current.min, next.min) && !moved(current.max, next.max) {
+			return false
+		}
+		*current = next
+		return true
+	}
+	var shared []float64
+	for _, t := range m.targets {
+		sorted := recent(t.stream)
+		if update(&t.percentileScale, sorted) && m.independentScales {
+			m.gradientUpdate = true
+		}
+		shared = append(shared, sorted...)
+	}
+	for _, d := range m.differentials {
+		if update(&d.percentileScale, recent(d.stream)) && m.independentScales {
+			m.gradientUpdate = true
+		}
+	}
+	slices.Sort(shared)
+	if update(&m.percentileScale, shared) && !m.independentScales {
+		m.gradientUpdate = true
+	}
+}
+
+// Parse a scale pinned to a stream, given as <stream>=<min>-<max>
+func parsePinnedScale(value string, scales map[string]scale) error {
+	name, span, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("-scale %q is not of the form <stream>=<min>-<max>", value)
+	}
+	s, err := parseScale(span)
+	if err != nil {
+		return err
+	}
+	scales[name] = s
+	return nil
+}
+
+// Pin the scales to the streams they name, by address, group or label.
+// Differences are named diff.
+func (m *model) pinScales(scales map[string]scale) error {
+	used := make(map[string]bool)
+	pin := func(st *stream, names ...string) {
+		for _, name := range names {
+			if s, ok := scales[name]; ok && name != "" {
+				st.pinned = &s
+				used[name] = true
+				return
+			}
+		}
+	}
+	for _, t := range m.targets {
+		pin(t.stream, t.address, t.label, t.group)
+	}
+	for _, d := range m.differentials {
+		pin(d.stream, "diff", d.label)
+	}
+	for name := range scales {
+		if !used[name] {
+			return fmt.Errorf("-scale %s matches no stream", name)
+		}
+	}
+	return nil
+}
+
+// Get the scale of the stream, which is shared by every stream unless
+// pinned or scales are independent. The shared scale spans every latency
+// seen unless it is fixed.
+func (m *model) streamScale(s *stream) scale {
+	if s.pinned != nil {
+		return *s.pinned
+	}
+	if m.independentScales {
+		if m.scalePercentiles != nil && s.percentileScale.max > 0 {
+			return s.percentileScale
+		}
+		return scale{s.minLatency, s.maxLatency}
+	}
+	if m.fixedScale != nil {
+		return *m.fixedScale
+	}
+	if m.scalePercentiles != nil && m.percentileScale.max > 0 {
+		return m.percentileScale
+	}
+	return scale{m.minLatency, m.maxLatency}
+}
+
+// Forget the latencies seen before the samples the charts show live, so a
+// single spike doesn't wash out the colors for the rest of the session
+func (m *model) resetScale(now time.Time) {
+	recent := func(s *stream) []float64 {
+		return s.latencyData[max(0, len(s.latencyData)-m.windowWidth*m.zoom):]
+	}
+	m.minLatency, m.maxLatency = math.MaxFloat64, 0.001
+	for _, t := range m.targets {
+		// As in processLatency, latencies of no time would collapse the
+		// logarithmic scale
+		for _, latency := range recent(t.stream) {
+			if latency > 0 {
+				m.minLatency = math.Min(m.minLatency, latency)
+				m.maxLatency = math.Max(m.maxLatency, latency)
+			}
+		}
+	}
+	streams := make([]*stream, 0, len(m.targets)+len(m.differentials))
+	for _, t := range m.targets {
+		streams = append(streams, t.stream)
+	}
+	for _, d := range m.differentials {
+		streams = append(streams, d.stream)
+	}
+	for _, s := range streams {
+		s.minLatency, s.maxLatency = math.MaxFloat64, 0.001
+		for _, latency := range recent(s) {
+			if latency > 0 {
+				s.minLatency = math.Min(s.minLatency, latency)
+				s.maxLatency = math.Max(s.maxLatency, latency)
+			}
+		}
+	}
+	m.scaleResetAt = now
+	m.gradientUpdate = true
+	m.redraw = true
+}
+
+// Render the legend of the shared scale, or of the focused stream's scale
+// when scales are independent
+func (m *model) renderScaleLegend() string {
+	focused := m.targets[m.focus]
+	sc := m.streamScale(focused.stream)
+	title := fmt.Sprintf("Latency Legend (%s, shared scale):", m.legendUnit(sc))
+	if m.fixedScale != nil {
+		title = fmt.Sprintf("Latency Legend (%s, fixed scale):", m.legendUnit(sc))
+	}
+	if m.independentScales || focused.pinned != nil {
+		title = fmt.Sprintf("Latency Legend (%s, scale of %s):", m.legendUnit(sc), focused.label)
+	}
+	if p := m.scalePercentiles; p != nil && focused.pinned == nil && (m.independentScales || m.fixedScale == nil) {
+		title = strings.TrimSuffix(title, "):") + fmt.Sprintf(" over %s to %s):", percentileLabel(p.low), percentileLabel(p.high))
+	}
+	return lipgloss.JoinVertical(lipgloss.Top, title, m.renderLegend(sc))
+}
+
+// Tell how much time a cell of each chart covers, so a screenshot explains
+// itself
+func (m *model) renderSpanLegend() string {
+	spans := []string{"raw ≈ " + formatSpan(m.interval*time.Duration(m.zoom))}
+	for i, count := range m.aggregateCounts {
+		spans = append(spans, fmt.Sprintf("aggregated %s ≈ %s", m.aggregateNames[i], formatSpan(m.interval*time.Duration(count*m.zoom))))
+	}
+	return "Each cell covers " + strings.Join(spans, ", ")
+}
+
+// Format a span of time briefly, such as 32s or 17min
+func formatSpan(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < 10*time.Second:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.0fs", d.Seconds())
+	case d < time.Hour:
+		return fmt.Sprintf("%.0fmin", d.Minutes())
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%.1fh", d.Hours())
+	}
+	return fmt.Sprintf("%.1fd", d.Hours()/24)
+}
+
+// The legend of a scale is in one unit, microseconds only when the whole
+// scale is below a millisecond
+func (m *model) legendUnit(sc scale) string {
+	return m.latencyFormat.unitOf(sc.max)
+}
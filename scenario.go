@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Most hops to look for the first hop on the internet within
+const traceHops = 8
+
+// A scenario sets up targets and settings that suit a common question, for
+// users who don't know what to ping
+type scenario struct {
+	description string
+	// Get the arguments to run with, printing what was found along the way
+	args func() ([]string, error)
+}
+
+var scenarios = map[string]scenario{
+	"wifi-vs-wan": {"Tell whether slowness is in the local network, at the ISP or beyond it", wifiVsWan},
+	"public-dns":  {"Compare public DNS resolvers, by ping and by connecting to their DNS port", publicDNS},
+}
+
+// Get the arguments of the named scenario, followed by the given arguments
+// so they can override it
+func scenarioArgs(args []string) []string {
+	if len(args) == 0 || scenarios[args[0]].args == nil {
+		names := make([]string, 0, len(scenarios))
+		for name := range scenarios {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("Usage: pingback scenario <name> [<flag>...]")
+		for _, name := range names {
+			fmt.Printf("  %-12s  %s\n", name, scenarios[name].description)
+		}
+		os.Exit(1)
+	}
+	scenarioArgs, err := scenarios[args[0]].args()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return append(scenarioArgs, args[1:]...)
+}
+
+// Ping the gateway, the first hop of the ISP and public DNS servers, showing
+// how much slower the internet is than the gateway
+func wifiVsWan() ([]string, error) {
+	gateway, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Gateway:", gateway)
+	args := []string{"-delay=500", "-address=" + gateway}
+	hop, err := firstPublicHop("1.1.1.1", gateway)
+	if err != nil {
+		fmt.Println("Leaving out the first hop of the ISP:", err)
+	} else {
+		fmt.Println("First hop of the ISP:", hop)
+		args = append(args, "-address="+hop)
+	}
+	return append(args, "-address=1.1.1.1", "-address=8.8.8.8", "-diff=1.1.1.1,"+gateway), nil
+}
+
+func publicDNS() ([]string, error) {
+	return []string{"-delay=1000", "-probes=icmp,tcp:53",
+		"-address=1.1.1.1", "-address=8.8.8.8", "-address=9.9.9.9"}, nil
+}
+
+// Get the gateway of the default route from the routing table of linux
+func defaultGateway() (string, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", fmt.Errorf("finding the gateway is only supported on Linux: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		gateway, err := hex.DecodeString(fields[2])
+		if err != nil || len(gateway) != 4 {
+			continue
+		}
+		// The table is in host byte order
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(gateway))
+		return ip.String(), nil
+	}
+	return "", errors.New("there is no default route")
+}
+
+// Find the first hop on the way to the destination that is on the internet
+// past the gateway, which belongs to the ISP
+func firstPublicHop(destination, gateway string) (string, error) {
+	hops, err := traceRoute(destination, traceHops)
+	if err != nil {
+		return "", err
+	}
+	for _, hop := range hops {
+		if hop != "" && hop != gateway && isPublic(hop) {
+			return hop, nil
+		}
+	}
+	if len(hops) < traceHops {
+		return "", fmt.Errorf("%s was reached without passing a hop on the internet", destination)
+	}
+	return "", fmt.Errorf("no hop within %d hops is on the internet", traceHops)
+}
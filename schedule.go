@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Spread the targets evenly across the interval so their probes don't queue
+// up behind each other
+func (m *model) staggerTargets(epoch time.Time) {
+	for i, t := range m.targets {
+		t.offset = m.interval * time.Duration(i) / time.Duration(len(m.targets))
+		t.nextPing = epoch.Add(t.offset)
+	}
+}
+
+func (m *model) schedulePing(t *target) tea.Cmd {
+	if m.replay != nil {
+		// The samples come from the recording instead
+		return nil
+	}
+	return m.clock.Tick(t.nextPing.Sub(m.clock.Now()), func(time.Time) tea.Msg {
+		return pingDueMsg{t}
+	})
+}
+
+// How long past its deadline a probe may run before the watchdog abandons it
+const probeGrace = 2 * time.Second
+
+type probeWatchdogMsg struct {
+	target *target
+	id     int
+}
+
+// Check on the probe once it should have ended, in case it ignored its
+// deadline, such as when stuck in a system call
+func (m *model) watchdogCmd(t *target, id int) tea.Cmd {
+	return m.clock.Tick(m.interval+probeGrace, func(time.Time) tea.Msg {
+		return probeWatchdogMsg{t, id}
+	})
+}
+
+// Mark the probe as done, unless it already was, releasing its context
+func (m *model) finishProbe(t *target, id int) bool {
+	if id != t.probeID || t.cancelProbe == nil {
+		return false
+	}
+	t.cancelProbe()
+	t.cancelProbe = nil
+	return true
+}
+
+// Advance to the next slot of the target, skipping slots that were missed
+// entirely so a slow probe doesn't cause a burst of catch-up probes
+func (m *model) advanceSchedule(t *target, sent, now time.Time) {
+	t.skew = sent.Sub(t.nextPing)
+	interval := m.currentInterval()
+	t.nextPing = t.nextPing.Add(interval)
+	if late := now.Sub(t.nextPing); late > interval {
+		t.nextPing = t.nextPing.Add(late.Truncate(interval))
+	}
+}
+
+func (m *model) renderDebug() string {
+	width := 0
+	for _, t := range m.targets {
+		width = max(width, len(t.address))
+	}
+	lines := []string{fmt.Sprintf("Schedule (every %v):", m.interval)}
+	for _, t := range m.targets {
+		line := fmt.Sprintf("  %-*s  offset %-8v  last sent %v late",
+			width, t.address, t.offset, t.skew.Round(time.Microsecond))
+		if t.restarts > 0 {
+			line += fmt.Sprintf("  %d stuck probes restarted", t.restarts)
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
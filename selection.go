@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var selectionStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#edd03a"))
+
+// A selected time range, where the anchor stays put and the end moves
+type selection struct {
+	anchor time.Time
+	end    time.Time
+}
+
+func (s selection) bounds() (time.Time, time.Time) {
+	if s.end.Before(s.anchor) {
+		return s.end, s.anchor
+	}
+	return s.anchor, s.end
+}
+
+func searchTime(timestamps []time.Time, at time.Time) int {
+	i, _ := slices.BinarySearchFunc(timestamps, at, func(t, target time.Time) int {
+		return t.Compare(target)
+	})
+	return i
+}
+
+// Get the samples of a stream taken within the selection
+func (s *stream) selected(sel selection) ([]float64, []time.Time) {
+	from, to := sel.bounds()
+	start := searchTime(s.timestamps, from)
+	end := searchTime(s.timestamps, to.Add(1))
+	return s.latencyData[start:end], s.timestamps[start:end]
+}
+
+// Select by clicking and dragging over the columns of the streams
+func (m *model) updateMouse(msg tea.MouseMsg) {
+	pressed := msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft
+	if !pressed && !m.dragging {
+		return
+	}
+	times := m.displayedTimes(m.targets[m.focus].stream)
+	if len(times) == 0 {
+		return
+	}
+	at := times[max(0, min(msg.X, len(times)-1))]
+	if pressed {
+		m.selection = &selection{at, at}
+		m.dragging = true
+		return
+	}
+	m.selection.end = at
+	m.dragging = msg.Action != tea.MouseActionRelease
+}
+
+// Start a selection at the newest displayed sample
+func (m *model) startSelection() {
+	focused := m.targets[m.focus]
+	start, end := m.displayedRange(len(focused.timestamps), 1, focused.counter-len(focused.timestamps))
+	if start == end {
+		return
+	}
+	at := focused.timestamps[end-1]
+	m.selection = &selection{at, at}
+}
+
+// Move the end of the selection by a number of columns
+func (m *model) moveSelection(columns int) {
+	if m.selection == nil {
+		m.startSelection()
+		if m.selection == nil {
+			return
+		}
+	}
+	timestamps := m.targets[m.focus].timestamps
+	i := searchTime(timestamps, m.selection.end) + columns*m.zoom
+	m.selection.end = timestamps[max(0, min(i, len(timestamps)-1))]
+}
+
+// Render a guide under the columns of a series of the stream that cover
+// the selection, so the same instant can be found in the rows of every time
+// scale. The series has the given number of samples to an element, its
+// first element has the given index, and its newest columns are shown up to
+// the width. A target without a sample within the selection is guided to
+// the one sent just before it.
+func (m *model) renderGuide(s *stream, length, samplesPerElement, firstIndex, width int) string {
+	from, to := m.selection.bounds()
+	first := s.counter - len(s.timestamps)
+	selectedFrom, selectedTo := first+searchTime(s.timestamps, from), first+searchTime(s.timestamps, to.Add(1))
+	if selectedFrom == selectedTo {
+		selectedFrom--
+	}
+	start, end := m.displayedRange(length, samplesPerElement, firstIndex)
+	columns := (end - start + m.zoom - 1) / m.zoom
+	var guide strings.Builder
+	for c := max(0, columns-width); c < columns; c++ {
+		// The samples of the column, numbered like the selected ones
+		columnFrom := (firstIndex + start + c*m.zoom) * samplesPerElement
+		columnTo := (firstIndex + min(start+(c+1)*m.zoom, end)) * samplesPerElement
+		if columnFrom < selectedTo && columnTo > selectedFrom {
+			guide.WriteString("▔")
+		} else {
+			guide.WriteString(" ")
+		}
+	}
+	return selectionStyle.Render(guide.String())
+}
+
+func (m *model) renderSelection() string {
+	from, to := m.selection.bounds()
+	times := m.displayedTimes(m.targets[m.focus].stream)
+	var bar strings.Builder
+	for i, t := range times {
+		if !t.After(to) && (i+1 == len(times) || times[i+1].After(from)) {
+			bar.WriteString("▀")
+		} else {
+			bar.WriteString(" ")
+		}
+	}
+
+	lines := []string{
+		selectionStyle.Render(bar.String()),
+		fmt.Sprintf("Selection %s - %s (%v), e exports it:",
+			m.timeFormat.format(from), m.timeFormat.format(to), to.Sub(from).Round(time.Second)),
+	}
+	width := 0
+	for _, t := range m.targets {
+		width = max(width, len(t.address))
+	}
+	for _, t := range m.targets {
+		data, _ := t.selected(*m.selection)
+		stats := summarize(data)
+		f, unit := m.latencyFormat, m.latencyFormat.unitOf(stats.max)
+		line := fmt.Sprintf("  %-*s  samples %d  loss %.1f%%  min %s  avg %s  median %s  p95 %s  max %s %s",
+			width, t.address, stats.count, stats.lossPercent(),
+			f.number(stats.min, unit, 1), f.number(stats.mean, unit, 1), f.number(stats.median, unit, 1),
+			f.number(stats.p95, unit, 1), f.number(stats.max, unit, 1), unit)
+		if times := t.timestamps; from.Equal(to) && stats.count == 1 {
+			// A single sample, clicked on
+			if meta, ok := t.metaOf(t.counter - len(times) + searchTime(times, from)); ok && meta.String() != "" {
+				line += "  " + meta.String()
+			}
+		}
+		if o, ok := m.objective(t); ok && stats.count > 0 {
+			if o.met(data) {
+				line += slaMetStyle.Render("  SLA met")
+			} else {
+				line += slaBreachedStyle.Render("  SLA breached")
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// Write the selected samples of every target to a CSV file
+func (m *model) exportSelection() (string, error) {
+	start, _ := m.selection.bounds()
+	name := fmt.Sprintf("pingback-%s.csv", start.Format("20060102-150405"))
+	file, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	// Latencies are in microseconds only when asked for, as a column of
+	// mixed units would be misread
+	unit, column := "ms", "rtt_ms"
+	if m.latencyFormat.unit == "us" {
+		unit, column = "µs", "rtt_us"
+	}
+	w.Write([]string{"target", "timestamp", column, "lost", "event", "label"})
+	for _, t := range m.targets {
+		data, timestamps := t.selected(*m.selection)
+		for i, latency := range data {
+			rtt := ""
+			if !math.IsNaN(latency) {
+				rtt = m.latencyFormat.number(latency, unit, 3)
+			}
+			w.Write([]string{t.address, m.timeFormat.format(timestamps[i]), rtt,
+				strconv.FormatBool(math.IsNaN(latency)), "", ""})
+		}
+	}
+	from, to := m.selection.bounds()
+	for _, e := range m.events {
+		if !e.time.Before(from) && !e.time.After(to) {
+			w.Write([]string{e.target, m.timeFormat.format(e.time), "", "", eventKindNames[e.kind], e.label})
+		}
+	}
+	w.Flush()
+	return name, w.Error()
+}
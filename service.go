@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// A file written by the installer
+type serviceFile struct {
+	path    string
+	content string
+}
+
+// What installing the service takes: files to write, then commands to run
+type servicePlan struct {
+	files    []serviceFile
+	commands [][]string
+}
+
+func runService(args []string) {
+	if len(args) > 0 && args[0] == "install" {
+		runServiceInstall(args[1:])
+		return
+	}
+	fmt.Println("Usage: pingback service install [-name <name>] [-user <user>] [-config <config>] [-dry-run] [-- <flags>]")
+	os.Exit(1)
+}
+
+func runServiceInstall(args []string) {
+	flags := flag.NewFlagSet("service install", flag.ExitOnError)
+	name := flags.String("name", "pingback", "Name of the service")
+	username := flags.String("user", defaultServiceUser(), "User to run the service as, created if missing on Linux")
+	configPath := flags.String("config", defaultConfigPath(), "Config to install for the service")
+	dryRun := flags.Bool("dry-run", false, "Print the files and commands instead of installing")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pingback service install [-name <name>] [-user <user>] [-config <config>] [-dry-run] [-- <flags>]")
+		fmt.Fprintln(flags.Output(), "Installs a service that runs Pingback headless with the config and the flags after --, starting it at boot")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	config, err := os.ReadFile(*configPath)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if config == nil && flags.NArg() == 0 {
+		fmt.Printf("%s doesn't exist, so give the flags of the service after --, such as -- -address=1.1.1.1\n", *configPath)
+		os.Exit(1)
+	}
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var plan servicePlan
+	switch runtime.GOOS {
+	case "linux":
+		plan = systemdPlan(*name, *username, executable, config, flags.Args())
+	case "darwin":
+		plan = launchdPlan(*name, *username, executable, config, flags.Args())
+	default:
+		fmt.Printf("Installing a service isn't supported on %s, where Pingback doesn't run\n", runtime.GOOS)
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		for _, file := range plan.files {
+			fmt.Printf("# %s\n%s\n", file.path, file.content)
+		}
+		for _, command := range plan.commands {
+			fmt.Println(strings.Join(command, " "))
+		}
+		return
+	}
+	if os.Geteuid() != 0 {
+		fmt.Println("Installing a service needs root, run it with sudo or see what it does with -dry-run")
+		os.Exit(1)
+	}
+	for _, file := range plan.files {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0o755); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(file.path, []byte(file.content), 0o644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Wrote", file.path)
+	}
+	for _, command := range plan.commands {
+		fmt.Println(strings.Join(command, " "))
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Installed and started %s\n", *name)
+}
+
+func defaultServiceUser() string {
+	if runtime.GOOS == "darwin" {
+		return "nobody"
+	}
+	return "pingback"
+}
+
+// The arguments of the service, which reads the installed config if there is
+// one and records the session, unless the flags given say otherwise. Samples
+// aren't written to stdout, where they would fill the journal.
+func serviceArgs(executable, installedConfig, session string, config []byte, extra []string) []string {
+	args := []string{executable, "-headless", "-quiet", "-record", session}
+	if config != nil {
+		args = append(args, "-config", installedConfig)
+	}
+	return append(args, extra...)
+}
+
+// A systemd unit running as an unprivileged user, granted CAP_NET_RAW to
+// send ICMP and see inbound pings without root. Only the service gets it,
+// ping sockets aren't opened to every user of the system.
+func systemdPlan(name, username, executable string, config []byte, extra []string) servicePlan {
+	var plan servicePlan
+	installedConfig := "/etc/pingback/" + name + ".toml"
+	if config != nil {
+		plan.files = append(plan.files, serviceFile{installedConfig, string(config)})
+	}
+	quoted := make([]string, 0, len(extra)+4)
+	// The state directory of the unit, which systemd makes writable to it
+	session := "/var/lib/pingback/" + name + ".jsonl"
+	for _, arg := range serviceArgs(executable, installedConfig, session, config, extra) {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+	unit := fmt.Sprintf(`[Unit]
+Description=Pingback latency monitor
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+ExecStart=%s
+User=%s
+AmbientCapabilities=CAP_NET_RAW
+StateDirectory=pingback
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`, strings.Join(quoted, " "), username)
+	plan.files = append(plan.files, serviceFile{"/etc/systemd/system/" + name + ".service", unit})
+	if _, err := user.Lookup(username); err != nil {
+		plan.commands = append(plan.commands, []string{"useradd", "--system", "--no-create-home", "--shell", "/usr/sbin/nologin", username})
+	}
+	plan.commands = append(plan.commands,
+		[]string{"systemctl", "daemon-reload"},
+		[]string{"systemctl", "enable", "--now", name + ".service"})
+	return plan
+}
+
+// Quote an argument of ExecStart, where % and $ are special to systemd
+func systemdQuote(arg string) string {
+	arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+	if arg == "" || strings.ContainsAny(arg, " \t\"'\\;") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
+// A launchd daemon, where ICMP needs no privileges
+func launchdPlan(name, username, executable string, config []byte, extra []string) servicePlan {
+	var plan servicePlan
+	installedConfig := "/usr/local/etc/pingback/" + name + ".toml"
+	if config != nil {
+		plan.files = append(plan.files, serviceFile{installedConfig, string(config)})
+	}
+	stateDir := "/usr/local/var/pingback"
+	var arguments strings.Builder
+	for _, arg := range serviceArgs(executable, installedConfig, stateDir+"/"+name+".jsonl", config, extra) {
+		fmt.Fprintf(&arguments, "\t\t<string>%s</string>\n", xmlEscape(arg))
+	}
+	path := "/Library/LaunchDaemons/" + name + ".plist"
+	plan.files = append(plan.files, serviceFile{path, fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>UserName</key>
+	<string>%s</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+</dict>
+</plist>
+`, xmlEscape(name), arguments.String(), xmlEscape(username))})
+	plan.commands = append(plan.commands,
+		[]string{"mkdir", "-p", stateDir},
+		[]string{"chown", username, stateDir},
+		[]string{"launchctl", "load", "-w", path})
+	return plan
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
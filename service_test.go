@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServiceRecordsSession(t *testing.T) {
+	plan := systemdPlan("home", "pingback", "/usr/bin/pingback", nil, []string{"-address=1.1.1.1"})
+	unit := plan.files[len(plan.files)-1].content
+	want := "ExecStart=/usr/bin/pingback -headless -quiet -record /var/lib/pingback/home.jsonl -address=1.1.1.1\n"
+	if !strings.Contains(unit, want) {
+		t.Errorf("the unit doesn't record the session in its state directory:\n%s", unit)
+	}
+
+	plan = launchdPlan("home", "nobody", "/usr/local/bin/pingback", []byte("addresses = [\"1.1.1.1\"]\n"), nil)
+	daemon := plan.files[len(plan.files)-1].content
+	for _, want := range []string{"<string>-record</string>", "<string>/usr/local/var/pingback/home.jsonl</string>", "<string>/usr/local/etc/pingback/home.toml</string>"} {
+		if !strings.Contains(daemon, want) {
+			t.Errorf("the daemon has no argument %s:\n%s", want, daemon)
+		}
+	}
+	if !slices.ContainsFunc(plan.commands, func(command []string) bool {
+		return slices.Equal(command, []string{"chown", "nobody", "/usr/local/var/pingback"})
+	}) {
+		t.Errorf("the directory of the session isn't made writable to the daemon: %q", plan.commands)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := []struct{ arg, want string }{
+		{"-address=1.1.1.1", "-address=1.1.1.1"},
+		{"-alert-cmd=notify $HOST", `"-alert-cmd=notify $$HOST"`},
+		{"100%", "100%%"},
+		{"", `""`},
+	}
+	for _, test := range tests {
+		if got := systemdQuote(test.arg); got != test.want {
+			t.Errorf("%q was quoted as %s, want %s", test.arg, got, test.want)
+		}
+	}
+}
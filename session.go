@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// A record of a recorded session, stored as one JSON object per line. A
+// record either holds a single sample, summarizes several samples of a
+// target when Count is set, or holds an event such as an annotation or the
+// metadata of a target when Event is set.
+type record struct {
+	Time      time.Time         `json:"timestamp"`
+	Event     string            `json:"event,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Label     string            `json:"label,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	RTT       *float64          `json:"rtt_ms,omitempty"`
+	Lost      bool              `json:"lost"`
+	Count     int               `json:"count,omitempty"`
+	LostCount int               `json:"lost_count,omitempty"`
+	Min       *float64          `json:"min_ms,omitempty"`
+	Max       *float64          `json:"max_ms,omitempty"`
+	// Metadata of a single sample
+	IP     string             `json:"ip,omitempty"`
+	TTL    int                `json:"ttl,omitempty"`
+	Error  string             `json:"error,omitempty"`
+	Stages map[string]float64 `json:"stages_ms,omitempty"`
+	// Size of the body of an HTTP response, and the time it took to read
+	Bytes    int64   `json:"bytes,omitempty"`
+	Transfer float64 `json:"transfer_ms,omitempty"`
+	// Players on a game server, and how many it has room for
+	Players    *int `json:"players,omitempty"`
+	MaxPlayers int  `json:"max_players,omitempty"`
+	// Whether the round trip told by the send time the reply echoed
+	// disagreed with the one measured by the clock
+	Proxied bool `json:"proxied,omitempty"`
+	// The round trip time of a reply that came after the sample was
+	// recorded as lost, in a record that replaces the earlier one
+	Late *float64 `json:"late_ms,omitempty"`
+}
+
+func sampleRecord(target string, at time.Time, latency float64, meta sampleMeta) record {
+	rec := record{Time: at, Target: target, Lost: math.IsNaN(latency), IP: meta.ip, TTL: meta.ttl, Error: meta.errClass,
+		Bytes: meta.size, Transfer: meta.transfer, Proxied: meta.proxied}
+	if !rec.Lost {
+		rec.RTT = &latency
+	}
+	if meta.maxPlayers > 0 {
+		rec.Players, rec.MaxPlayers = &meta.players, meta.maxPlayers
+	}
+	if meta.late > 0 {
+		rec.Late = &meta.late
+	}
+	if meta.stages != nil {
+		rec.Stages = make(map[string]float64)
+		for i, stage := range httpStages {
+			// Stages that didn't happen are left out
+			if !math.IsNaN(meta.stages[i]) {
+				rec.Stages[stage] = meta.stages[i]
+			}
+		}
+	}
+	return rec
+}
+
+// Get the metadata of a sample record
+func (r record) meta() sampleMeta {
+	meta := sampleMeta{ip: r.IP, ttl: r.TTL, errClass: r.Error, size: r.Bytes, transfer: r.Transfer, proxied: r.Proxied}
+	if r.Players != nil {
+		meta.players, meta.maxPlayers = *r.Players, r.MaxPlayers
+	}
+	if r.Late != nil {
+		meta.late = *r.Late
+	}
+	if r.Stages != nil {
+		meta.stages = make([]float64, len(httpStages))
+		for i, stage := range httpStages {
+			meta.stages[i] = math.NaN()
+			if ms, ok := r.Stages[stage]; ok {
+				meta.stages[i] = ms
+			}
+		}
+	}
+	return meta
+}
+
+// Events are written without the fields of samples
+func (r record) MarshalJSON() ([]byte, error) {
+	type plain record
+	if r.Event == "" {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		Time     time.Time         `json:"timestamp"`
+		Event    string            `json:"event"`
+		Target   string            `json:"target,omitempty"`
+		Label    string            `json:"label,omitempty"`
+		Metadata map[string]string `json:"metadata,omitempty"`
+	}{r.Time, r.Event, r.Target, r.Label, r.Metadata})
+}
+
+// Get the latency of the record, NaN when lost
+func (r record) latency() float64 {
+	if r.RTT == nil {
+		return math.NaN()
+	}
+	return *r.RTT
+}
+
+// Get the samples of the record. The samples of a summary are approximated
+// by its minimum, its maximum and the rest at its median.
+func (r record) samples() []float64 {
+	if r.Count == 0 {
+		return []float64{r.latency()}
+	}
+	samples := make([]float64, r.Count)
+	received := r.Count - r.LostCount
+	for i := range samples {
+		switch {
+		case i >= received:
+			samples[i] = math.NaN()
+		case i == 0 && r.Min != nil:
+			samples[i] = *r.Min
+		case i == received-1 && r.Max != nil:
+			samples[i] = *r.Max
+		default:
+			samples[i] = r.latency()
+		}
+	}
+	return samples
+}
+
+type recorder struct {
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func openRecorder(path string) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{file, bufio.NewWriter(file)}, nil
+}
+
+// Append a record, flushing right away so little is lost if pingback dies
+func (r *recorder) write(rec record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	r.writer.Write(append(line, '\n'))
+	return r.writer.Flush()
+}
+
+func (r *recorder) close() error {
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+func readSession(path string) ([]record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if rec.Late != nil && replaceLost(records, rec) {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// Replace the lost sample that a late reply came for with the record of the
+// reply, so it isn't counted twice. The reply is recorded soon after the
+// loss, so only the records since a little before it are searched.
+func replaceLost(records []record, late record) bool {
+	since := late.Time.Add(-2 * lateReplyWait)
+	for i := len(records) - 1; i >= 0 && records[i].Time.After(since); i-- {
+		if rec := records[i]; rec.Event == "" && rec.Target == late.Target && rec.Time.Equal(late.Time) {
+			records[i] = late
+			return true
+		}
+	}
+	return false
+}
+
+// Write the records to the session in time order, replacing it atomically
+func writeSession(path string, records []record) error {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if info, err := os.Stat(path); err == nil {
+		file.Chmod(info.Mode())
+	}
+	r := &recorder{file, bufio.NewWriter(file)}
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			r.close()
+			return err
+		}
+		r.writer.Write(append(line, '\n'))
+	}
+	if err := r.close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Write a sample to the output of headless mode, quitting when it can't be
+// written to
+func (m *model) writeOutput(rec record) tea.Cmd {
+	if m.output == nil {
+		return nil
+	}
+	if err := m.output.write(rec); err != nil {
+		m.err = err
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m *model) record(rec record) {
+	if m.recorder == nil {
+		return
+	}
+	if err := m.recorder.write(rec); err != nil {
+		m.status = "Recording stopped: " + err.Error()
+		m.recorder.close()
+		m.recorder = nil
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+	"time"
+)
+
+// How long to wait on exit for alert commands that are still running
+const shutdownTimeout = 3 * time.Second
+
+// Wait for the alert commands to finish, giving up after the timeout so a
+// hung command can't keep pingback from exiting
+func (m *model) waitForAlerts(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		m.alerts.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Print the statistics of every target over the session, leaving out
+// backfilled samples
+func (m *model) printSummary(w io.Writer, now time.Time) {
+	fmt.Fprintf(w, "Pinged for %v:\n", now.Sub(m.started).Round(time.Second))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	summaries := make([]summary, len(m.targets))
+	slowest := 0.0
+	for i, t := range m.targets {
+		var data []float64
+		for j, at := range t.timestamps {
+			if !at.Before(m.started) {
+				data = append(data, t.latencyData[j])
+			}
+		}
+		summaries[i] = summarize(data)
+		if !math.IsNaN(summaries[i].max) {
+			slowest = max(slowest, summaries[i].max)
+		}
+	}
+	// One unit for the whole table, so the columns compare
+	unit := m.latencyFormat.unitOf(slowest)
+	fmt.Fprintln(tw, "  target\tsent\tloss %\tmin\tmedian\tp95\tmax "+unit)
+	for i, t := range m.targets {
+		stats := summaries[i]
+		fmt.Fprintf(tw, "  %s\t%d\t%.1f", t.label, stats.count, stats.lossPercent())
+		for _, latency := range []float64{stats.min, stats.median, stats.p95, stats.max} {
+			fmt.Fprint(tw, "\t"+m.latencyFormat.number(latency, unit, 2))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Number of recent samples objectives are checked over
+const slaWindow = 120
+
+var (
+	slaMetStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#31f199"))
+	slaBreachedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#d23105"))
+	// Colors of the band of the objective, from met to breached
+	slaGradient = []lipgloss.Color{"#31f199", "#d23105"}
+)
+
+// The latency and loss a target is expected to stay within, where a zero
+// latency or negative loss is no objective
+type objective struct {
+	percentile float64
+	latency    float64
+	loss       float64
+}
+
+func (o objective) String() string {
+	var parts []string
+	if o.latency > 0 {
+		parts = append(parts, fmt.Sprintf("p%g < %g ms", o.percentile, o.latency))
+	}
+	if o.loss >= 0 {
+		parts = append(parts, fmt.Sprintf("loss < %g%%", o.loss))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Check whether the samples meet the objective
+func (o objective) met(data []float64) bool {
+	stats := summarize(data)
+	if o.loss >= 0 && stats.lossPercent() > o.loss {
+		return false
+	}
+	if o.latency > 0 && stats.count > stats.lost {
+		var replies []float64
+		for _, latency := range data {
+			if !math.IsNaN(latency) {
+				replies = append(replies, latency)
+			}
+		}
+		sort.Float64s(replies)
+		if percentile(replies, o.percentile) > o.latency {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe the latency percentile and loss of the samples
+func (m *model) measure(p float64, data []float64) string {
+	stats := summarize(data)
+	return fmt.Sprintf("p%g %s, loss %.1f%%", p, m.latencyFormat.format(percentile(replies(data), p)), stats.lossPercent())
+}
+
+// Load objectives from a CSV file with a header naming its columns: target,
+// and any of latency_ms, percentile (95 by default) and loss_percent
+func loadObjectives(path string) (map[string]objective, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["target"]; !ok {
+		return nil, fmt.Errorf("%s has no target column", path)
+	}
+	field := func(row []string, name string, fallback float64) (float64, error) {
+		i, ok := columns[name]
+		if !ok || i >= len(row) || strings.TrimSpace(row[i]) == "" {
+			return fallback, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(row[i], "%")), 64)
+	}
+	objectives := make(map[string]objective)
+	for line, row := range rows[1:] {
+		var o objective
+		var errs [3]error
+		o.latency, errs[0] = field(row, "latency_ms", 0)
+		o.percentile, errs[1] = field(row, "percentile", 95)
+		o.loss, errs[2] = field(row, "loss_percent", -1)
+		for _, err := range errs {
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line+2, err)
+			}
+		}
+		if o.percentile <= 0 || o.percentile > 100 {
+			return nil, fmt.Errorf("%s:%d: percentile must be between 0 and 100", path, line+2)
+		}
+		objectives[strings.TrimSpace(row[columns["target"]])] = o
+	}
+	return objectives, nil
+}
+
+// Check that each objective names the address or group of a target, as a
+// typo would otherwise leave a target unchecked
+func (m *model) checkObjectives(path string) error {
+	for name := range m.objectives {
+		if !slices.ContainsFunc(m.targets, func(t *target) bool { return t.address == name || t.group == name }) {
+			return fmt.Errorf("%s: %s matches no target", path, name)
+		}
+	}
+	return nil
+}
+
+// Get the objective of the target, where probes of an address share the
+// objective of the address
+func (m *model) objective(t *target) (objective, bool) {
+	if o, ok := m.objectives[t.address]; ok {
+		return o, true
+	}
+	o, ok := m.objectives[t.group]
+	return o, ok
+}
+
+// Check the objective of the target over its recent samples, alerting when
+// it starts or stops being met
+func (m *model) trackSLA(t *target, now time.Time) tea.Cmd {
+	o, ok := m.objective(t)
+	if !ok {
+		return nil
+	}
+	if len(t.latencyData) < slaWindow {
+		m.appendObjective(t, math.NaN())
+		return nil
+	}
+	window := t.latencyData[len(t.latencyData)-slaWindow:]
+	breached := !o.met(window)
+	state := 0.0
+	if breached {
+		state = 1
+	}
+	m.appendObjective(t, state)
+	if breached {
+		m.raiseBanner(t, "sla", "SLA "+o.String()+" breached", m.measure(o.percentile, window), now)
+	} else {
+		m.resolveBanner(t, "sla", now)
+	}
+	if breached == t.slaBreached {
+		return nil
+	}
+	t.slaBreached = breached
+	event, label := "sla_met", t.label+" SLA met"
+	if breached {
+		event, label = "sla_breach", t.label+" SLA breached"
+	}
+	m.addEvent(slaEvent, t.address, label, now)
+	return m.runAlert(append(os.Environ(),
+		"PINGBACK_EVENT="+event,
+		"PINGBACK_TARGETS="+t.address,
+		"PINGBACK_OBJECTIVE="+o.String(),
+	))
+}
+
+// Render the objective of the target, colored by whether it is met
+func (m *model) renderObjective(t *target) string {
+	o, ok := m.objective(t)
+	if !ok {
+		return ""
+	}
+	if t.slaBreached {
+		return slaBreachedStyle.Render("SLA " + o.String() + " breached")
+	}
+	return slaMetStyle.Render("SLA " + o.String())
+}
+
+// Append whether the objective was breached at the latest sample, keeping as
+// many as there are samples
+func (m *model) appendObjective(t *target, breached float64) {
+	t.slaData = append(t.slaData, breached)
+	t.slaData = t.slaData[max(0, len(t.slaData)-len(t.latencyData)):]
+}
+
+// Render a band under the charts of the target, green where its objective was
+// met and red where it was breached, so breaches can be told apart in the
+// history
+func (m *model) renderObjectiveBand(t *target) string {
+	o, _ := m.objective(t)
+	data := m.displayedColumns(t.slaData, 1, t.counter-len(t.slaData))
+	cells := make([]cell, len(data))
+	for i, breached := range data {
+		cells[i] = cell{" ", "", false}
+		if !math.IsNaN(breached) {
+			cells[i] = gradientCell(slaGradient, breached)
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, "SLA "+o.String()+":", renderRow(cells))
+}
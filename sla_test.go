@@ -0,0 +1,69 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadObjectives(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want map[string]objective
+		err  string
+	}{
+		{
+			name: "defaults",
+			csv:  "target,latency_ms,percentile,loss_percent\nexample.com,50,99,0.5\n192.168.1.1,5,,\n",
+			want: map[string]objective{
+				"example.com": {percentile: 99, latency: 50, loss: 0.5},
+				"192.168.1.1": {percentile: 95, latency: 5, loss: -1},
+			},
+		},
+		{
+			name: "columns in any order and case",
+			csv:  "Loss_Percent, Target\n1%,example.com\n",
+			want: map[string]objective{"example.com": {percentile: 95, loss: 1}},
+		},
+		{name: "empty", csv: "", err: "is empty"},
+		{name: "no target column", csv: "latency_ms\n50\n", err: "has no target column"},
+		{name: "not a number", csv: "target,latency_ms\nexample.com,fast\n", err: ":2:"},
+		{name: "percentile out of range", csv: "target,percentile\nexample.com,101\n", err: "percentile must be between 0 and 100"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sla.csv")
+			if err := os.WriteFile(path, []byte(test.csv), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			objectives, err := loadObjectives(path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(objectives, test.want) {
+				t.Errorf("got %v, want %v", objectives, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckObjectives(t *testing.T) {
+	m, _ := newTestModel(t, []string{"10.0.0.1", "10.0.0.2"}, 0, []int{4})
+	m.objectives = map[string]objective{"10.0.0.1": {percentile: 95, latency: 5, loss: -1}}
+	if err := m.checkObjectives("sla.csv"); err != nil {
+		t.Errorf("an objective of a target failed: %v", err)
+	}
+	m.objectives["10.0.0.3"] = objective{percentile: 95, latency: 5, loss: -1}
+	if err := m.checkObjectives("sla.csv"); err == nil || !strings.Contains(err.Error(), "10.0.0.3 matches no target") {
+		t.Errorf("got %v, want the objective of 10.0.0.3 to match no target", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+const (
+	soundRate     = 22050
+	clickDuration = 40 * time.Millisecond
+	// Pitch of the lowest and highest latency seen so far
+	lowPitch  = 220.0
+	highPitch = 1760.0
+)
+
+// Find a command that plays WAV audio
+func findPlayer() []string {
+	for _, player := range [][]string{{"paplay"}, {"aplay", "-q"}, {"afplay"}} {
+		if _, err := exec.LookPath(player[0]); err == nil {
+			return player
+		}
+	}
+	return nil
+}
+
+// Map the latency to a pitch between the lowest and highest latency seen so
+// far, on the same logarithmic scale as the colors
+func (m *model) pitch(latency float64) float64 {
+	if m.minLatency >= m.maxLatency {
+		return lowPitch
+	}
+	ratio := math.Log(math.Max(latency, m.minLatency)/m.minLatency) / math.Log(m.maxLatency/m.minLatency)
+	return lowPitch * math.Pow(highPitch/lowPitch, math.Min(ratio, 1))
+}
+
+// Play a short click, pitched by the latency of the reply
+func (m *model) clickCmd(latency float64) tea.Cmd {
+	if !m.sound || m.player == nil || math.IsNaN(latency) {
+		return nil
+	}
+	wav := tone(m.pitch(latency), clickDuration)
+	player := m.player
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		if player[0] == "afplay" {
+			// afplay can't read from standard input
+			file, err := os.CreateTemp("", "pingback-*.wav")
+			if err != nil {
+				return nil
+			}
+			defer os.Remove(file.Name())
+			file.Write(wav)
+			file.Close()
+			cmd = exec.Command(player[0], file.Name())
+		} else {
+			cmd = exec.Command(player[0], player[1:]...)
+			cmd.Stdin = bytes.NewReader(wav)
+		}
+		_ = cmd.Run()
+		return nil
+	}
+}
+
+// Generate a sine tone as 16 bit mono WAV, fading out so it clicks rather
+// than beeps
+func tone(frequency float64, duration time.Duration) []byte {
+	samples := int(duration.Seconds() * soundRate)
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+2*samples))
+	b.WriteString("WAVEfmt ")
+	for _, field := range []any{
+		uint32(16), uint16(1), uint16(1), uint32(soundRate), uint32(2 * soundRate), uint16(2), uint16(16),
+	} {
+		binary.Write(&b, binary.LittleEndian, field)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(2*samples))
+	for i := 0; i < samples; i++ {
+		envelope := 1 - float64(i)/float64(samples)
+		value := math.Sin(2*math.Pi*frequency*float64(i)/soundRate) * envelope * 0.5
+		binary.Write(&b, binary.LittleEndian, int16(value*math.MaxInt16))
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// How long each direction of a speed test transfers for
+const speedTestDuration = 10 * time.Second
+
+type speedTestMsg struct {
+	direction string
+	bytes     int64
+	elapsed   time.Duration
+	err       error
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// An endless source of zeros to upload
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Start a speed test, marking it on the timeline, so the effect of load on
+// the latency can be seen
+func (m *model) startSpeedTest(now time.Time) tea.Cmd {
+	if m.speedTestURL == "" {
+		m.status = "No speed test URL, set one with -speedtest-url"
+		return nil
+	}
+	if m.speedTesting {
+		return nil
+	}
+	m.speedTesting = true
+	m.addEvent(speedTestEvent, "", "speed test", now)
+	return speedTestCmd(m.ctx, "down", m.speedTestURL)
+}
+
+// Transfer for the duration of the test, and measure how much got through.
+// Downloads get the URL and uploads post an endless body to it.
+func speedTestCmd(ctx context.Context, direction, url string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, speedTestDuration)
+		defer cancel()
+		method := http.MethodGet
+		var body io.Reader
+		upload := &countingReader{reader: zeros{}}
+		if direction == "up" {
+			method, body = http.MethodPost, upload
+		}
+		request, err := http.NewRequestWithContext(ctx, method, url, body)
+		if err != nil {
+			return speedTestMsg{direction: direction, err: err}
+		}
+
+		start := time.Now()
+		response, err := http.DefaultClient.Do(request)
+		if direction == "up" {
+			if err == nil {
+				response.Body.Close()
+			} else if !errors.Is(err, context.DeadlineExceeded) {
+				return speedTestMsg{direction: direction, err: err}
+			}
+			return speedTestMsg{direction, upload.count, time.Since(start), nil}
+		}
+		if err != nil {
+			return speedTestMsg{direction: direction, err: err}
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return speedTestMsg{direction: direction, err: fmt.Errorf("got %s", response.Status)}
+		}
+		n, err := io.Copy(io.Discard, response.Body)
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return speedTestMsg{direction: direction, err: err}
+		}
+		return speedTestMsg{direction, n, time.Since(start), nil}
+	}
+}
+
+// Mark the end of a direction of the speed test with its throughput, and
+// start the upload once the download is done
+func (m *model) finishSpeedTest(msg speedTestMsg, now time.Time) tea.Cmd {
+	if msg.err != nil {
+		m.speedTesting = false
+		m.addEvent(speedTestEvent, "", "speed test "+msg.direction+" failed", now)
+		m.status = fmt.Sprintf("Speed test %s failed: %v", msg.direction, msg.err)
+		return nil
+	}
+	mbits := float64(msg.bytes) * 8 / 1e6 / msg.elapsed.Seconds()
+	m.addEvent(speedTestEvent, "", fmt.Sprintf("%.1f Mbit/s %s", mbits, msg.direction), now)
+	m.status = fmt.Sprintf("Speed test %s: %.1f Mbit/s", msg.direction, mbits)
+	if msg.direction == "down" && m.speedTestUploadURL != "" {
+		return speedTestCmd(m.ctx, "up", m.speedTestUploadURL)
+	}
+	m.speedTesting = false
+	return nil
+}
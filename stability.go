@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var stabilityGradient = []lipgloss.Color{"#ffffff", "#8000ff"}
+
+// Append the standard deviation over the most recent samples of the stream
+func (m *model) appendStddev(s *stream) {
+	s.stddevData = append(s.stddevData, relativeStddev(s.latencyData[max(0, len(s.latencyData)-m.stddevWindow):]))
+}
+
+// The standard deviation of the replies in the window relative to their
+// mean, so that a link swinging between 10 and 70 ms stands out from a
+// steady one at 40 ms whatever the latencies. NaN without replies.
+func relativeStddev(window []float64) float64 {
+	mean := summarize(window).mean
+	if math.IsNaN(mean) || mean <= 0 {
+		return math.NaN()
+	}
+	return standardDeviation(window) / mean
+}
+
+func (m *model) stddevTitle(s *stream) string {
+	stddev := standardDeviation(s.latencyData[max(0, len(s.latencyData)-m.stddevWindow):])
+	if math.IsNaN(stddev) {
+		return fmt.Sprintf("Stddev (last %d):", m.stddevWindow)
+	}
+	return fmt.Sprintf("Stddev (last %d), now %s:", m.stddevWindow, m.latencyFormat.format(stddev))
+}
+
+// Render the rolling standard deviations of a stream, where windows without
+// replies are left blank
+func (m *model) renderStddevStream(data []float64) string {
+	cells := make([]cell, len(data))
+	for i, stddev := range data {
+		cells[i] = cell{" ", "", false}
+		if !math.IsNaN(stddev) {
+			cells[i] = gradientCell(stabilityGradient, stddev)
+		}
+	}
+	return renderRow(cells)
+}
+
+func renderStddevLegend() string {
+	entries := make([]string, 0, 11)
+	for percent := 0; percent <= 100; percent += 10 {
+		entries = append(entries, fmt.Sprintf("%s %-4d",
+			renderRow([]cell{gradientCell(stabilityGradient, float64(percent)/100)}), percent))
+	}
+	return lipgloss.JoinVertical(lipgloss.Top, "Stddev Legend (% of mean):",
+		lipgloss.JoinHorizontal(lipgloss.Top, entries...))
+}
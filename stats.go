@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary statistics of a series of latencies, where NaN is a lost packet
+type summary struct {
+	count  int
+	lost   int
+	min    float64
+	mean   float64
+	median float64
+	p95    float64
+	max    float64
+	jitter float64
+}
+
+func (s summary) lossPercent() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return 100 * float64(s.lost) / float64(s.count)
+}
+
+// Get the pth percentile of sorted data by linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return lerp(sorted[lower], sorted[lower+1], rank-float64(lower))
+}
+
+func summarize(data []float64) summary {
+	result := summary{count: len(data)}
+	received := make([]float64, 0, len(data))
+	sum, jitterSum, jitterCount := 0.0, 0.0, 0
+	previous := math.NaN()
+	for _, v := range data {
+		if math.IsNaN(v) {
+			result.lost++
+			continue
+		}
+		received = append(received, v)
+		sum += v
+		if !math.IsNaN(previous) {
+			jitterSum += math.Abs(v - previous)
+			jitterCount++
+		}
+		previous = v
+	}
+	if len(received) == 0 {
+		result.min, result.mean, result.median, result.p95, result.max, result.jitter =
+			math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN()
+		return result
+	}
+	sort.Float64s(received)
+	result.min = received[0]
+	result.max = received[len(received)-1]
+	result.mean = sum / float64(len(received))
+	result.median = percentile(received, 50)
+	result.p95 = percentile(received, 95)
+	result.jitter = math.NaN()
+	if jitterCount > 0 {
+		result.jitter = jitterSum / float64(jitterCount)
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// The 256 colors as xterm draws them: the 16 ANSI colors, which most
+// terminals stay close to, a 6×6×6 cube and 24 grays
+var xtermColors = func() [256][3]float64 {
+	colors := [256][3]float64{
+		{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+		{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+		{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+		{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+	}
+	levels := []float64{0, 95, 135, 175, 215, 255}
+	for i := range 216 {
+		colors[16+i] = [3]float64{levels[i/36], levels[i/6%6], levels[i%6]}
+	}
+	for i := range 24 {
+		gray := float64(8 + 10*i)
+		colors[232+i] = [3]float64{gray, gray, gray}
+	}
+	return colors
+}()
+
+// Number of points a gradient is sampled at to find the terminal colors
+// along it
+const gradientSamples = 256
+
+// The distinct terminal colors along each gradient, by profile and colors
+var terminalGradients = make(map[string][]lipgloss.Color)
+
+// Get the distinct colors that the terminal can show along the gradient,
+// from its low end to its high end, or nil on terminals with true color.
+// Converting every color of the gradient on its own would give runs of the
+// same color of uneven lengths, or a muddy gray for latencies far apart, so
+// the gradient is split into one bucket of equal size per distinct color.
+func terminalGradient(colors []lipgloss.Color) []lipgloss.Color {
+	profile := lipgloss.ColorProfile()
+	if profile != termenv.ANSI256 && profile != termenv.ANSI {
+		return nil
+	}
+	key := fmt.Sprint(profile, colors)
+	if steps, ok := terminalGradients[key]; ok {
+		return steps
+	}
+	var steps []lipgloss.Color
+	for i := range gradientSamples {
+		color := interpolateGradient(colors, float64(i)/(gradientSamples-1))
+		// The 16 ANSI colors are left out of the 256, as themes change them
+		step := lipgloss.Color(strconv.Itoa(nearestXtermColor(color, 16, 256)))
+		if profile == termenv.ANSI {
+			step = lipgloss.Color(strconv.Itoa(nearestXtermColor(color, 0, 16)))
+		}
+		// Colors the gradient comes back to keep their first bucket
+		if !slices.Contains(steps, step) {
+			steps = append(steps, step)
+		}
+	}
+	terminalGradients[key] = steps
+	return steps
+}
+
+// Set the colors the terminal can show, as given by -colors, rather than
+// what lipgloss detects from the environment
+func applyColors(name string) error {
+	profiles := map[string]termenv.Profile{
+		"truecolor": termenv.TrueColor,
+		"256":       termenv.ANSI256,
+		"16":        termenv.ANSI,
+	}
+	if name == "auto" {
+		return nil
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown -colors %q, expected truecolor, 256, 16 or auto", name)
+	}
+	lipgloss.SetColorProfile(profile)
+	return nil
+}
+
+// Get the channels of a #rrggbb color from 0 to 255. Unlike its RGBA
+// method, this doesn't go through the color profile of the terminal, which
+// would blend colors it has already rounded.
+func hexRGB(color lipgloss.Color) (float64, float64, float64) {
+	value, _ := strconv.ParseUint(strings.TrimPrefix(string(color), "#"), 16, 32)
+	return float64(value >> 16 & 0xff), float64(value >> 8 & 0xff), float64(value & 0xff)
+}
+
+// Find the xterm color from first up to end closest to the color by the
+// redmean distance, which weighs the channels the way the eye does better
+// than plain RGB. The conversion of lipgloss can turn saturated colors gray.
+func nearestXtermColor(color lipgloss.Color, first, end int) int {
+	r, g, b := hexRGB(color)
+	nearest, best := first, math.Inf(1)
+	for i := first; i < end; i++ {
+		xterm := xtermColors[i]
+		mean := (r + xterm[0]) / 2
+		dr, dg, db := r-xterm[0], g-xterm[1], b-xterm[2]
+		distance := (2+mean/256)*dr*dr + 4*dg*dg + (2+(255-mean)/256)*db*db
+		if distance < best {
+			nearest, best = i, distance
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How timestamps are shown to the user and written to alerts
+type timeFormat struct {
+	name     string
+	location *time.Location
+}
+
+func parseTimeFormat(name, timezone string) (timeFormat, error) {
+	switch name {
+	case "iso8601", "local", "unix":
+	default:
+		return timeFormat{}, fmt.Errorf("unknown time format %q, expected iso8601, local or unix", name)
+	}
+	location := time.Local
+	if timezone != "" {
+		var err error
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return timeFormat{}, err
+		}
+	}
+	return timeFormat{name, location}, nil
+}
+
+// Get the time zone of the timestamps, the local one unless configured
+// otherwise, which the zero value isn't
+func (f timeFormat) zone() *time.Location {
+	if f.location == nil {
+		return time.Local
+	}
+	return f.location
+}
+
+func (f timeFormat) format(t time.Time) string {
+	t = t.In(f.zone())
+	switch f.name {
+	case "iso8601":
+		return t.Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	default:
+		return t.Format(time.DateTime)
+	}
+}
+
+// Format the time down to the microsecond, for exports of samples that may
+// be less than a second apart
+func (f timeFormat) formatPrecise(t time.Time) string {
+	t = t.In(f.zone())
+	switch f.name {
+	case "iso8601":
+		return t.Format(time.RFC3339Nano)
+	case "unix":
+		micros := t.UnixMicro()
+		return fmt.Sprintf("%d.%06d", micros/1e6, micros%1e6)
+	default:
+		return t.Format("2006-01-02 15:04:05.000000")
+	}
+}
+
+// Label the first and last displayed sample with their time
+func (m *model) renderTimeAxis(timestamps []time.Time) string {
+	if len(timestamps) == 0 {
+		return ""
+	}
+	first := m.timeFormat.format(timestamps[0])
+	last := m.timeFormat.format(timestamps[len(timestamps)-1])
+	gap := len(timestamps) - len(first) - len(last)
+	if gap < 1 {
+		return strings.Repeat(" ", max(0, len(timestamps)-len(last))) + last
+	}
+	return first + strings.Repeat(" ", gap) + last
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)
+	utc := func(name string) timeFormat {
+		f, err := parseTimeFormat(name, "UTC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	tests := []struct {
+		format timeFormat
+		want   string
+	}{
+		{utc("iso8601"), "2024-01-01T12:30:00Z"},
+		{utc("local"), "2024-01-01 12:30:00"},
+		{utc("unix"), "1704112200"},
+		// The zero value shows local times
+		{timeFormat{}, at.Local().Format(time.DateTime)},
+	}
+	for _, test := range tests {
+		if got := test.format.format(at); got != test.want {
+			t.Errorf("%q in %v formatted %v as %q, want %q", test.format.name, test.format.location, at, got, test.want)
+		}
+	}
+	if _, err := parseTimeFormat("rfc822", ""); err == nil {
+		t.Error("parsed an unknown time format")
+	}
+}
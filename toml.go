@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A parser for the subset of TOML the config uses: tables, arrays of tables,
+// strings, numbers, booleans, arrays and inline tables. Tables decode to
+// map[string]any, arrays to []any, integers to int64 and floats to float64.
+type tomlParser struct {
+	text string
+	pos  int
+	line int
+}
+
+func parseTOML(text string) (map[string]any, error) {
+	p := &tomlParser{text: text, line: 1}
+	root := make(map[string]any)
+	table := root
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.text) {
+			return root, nil
+		}
+		var err error
+		if strings.HasPrefix(p.text[p.pos:], "[[") {
+			p.pos += 2
+			table, err = p.header(root, true)
+		} else if p.text[p.pos] == '[' {
+			p.pos++
+			table, err = p.header(root, false)
+		} else {
+			err = p.keyValue(table)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+		p.skipSpace(false)
+		if p.pos < len(p.text) && p.text[p.pos] != '\n' {
+			return nil, fmt.Errorf("line %d: expected a new line", p.line)
+		}
+	}
+}
+
+// Skip whitespace and comments, and new lines too when asked to
+func (p *tomlParser) skipSpace(newLines bool) {
+	for p.pos < len(p.text) {
+		switch c := p.text[p.pos]; {
+		case c == '#':
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '\n' && newLines:
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) consume(s string) bool {
+	if strings.HasPrefix(p.text[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// Parse a possibly dotted key
+func (p *tomlParser) key() ([]string, error) {
+	var parts []string
+	for {
+		p.skipSpace(false)
+		var part string
+		if p.pos < len(p.text) && (p.text[p.pos] == '"' || p.text[p.pos] == '\'') {
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			part = value.(string)
+		} else {
+			start := p.pos
+			for p.pos < len(p.text) && (isBareKeyChar(rune(p.text[p.pos]))) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, fmt.Errorf("expected a key")
+			}
+			part = p.text[start:p.pos]
+		}
+		parts = append(parts, part)
+		p.skipSpace(false)
+		if !p.consume(".") {
+			return parts, nil
+		}
+	}
+}
+
+func isBareKeyChar(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-')
+}
+
+// Find or create the table at the end of a dotted key
+func descend(table map[string]any, keys []string) (map[string]any, error) {
+	for _, key := range keys {
+		switch next := table[key].(type) {
+		case nil:
+			created := make(map[string]any)
+			table[key] = created
+			table = created
+		case map[string]any:
+			table = next
+		case []any:
+			if len(next) == 0 {
+				return nil, fmt.Errorf("%s is an empty array, not a table", key)
+			}
+			last, ok := next[len(next)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", key)
+		}
+	}
+	return table, nil
+}
+
+func (p *tomlParser) header(root map[string]any, array bool) (map[string]any, error) {
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !p.consume(closing) {
+		return nil, fmt.Errorf("expected %s", closing)
+	}
+	parent, err := descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	name := keys[len(keys)-1]
+	if !array {
+		return descend(parent, []string{name})
+	}
+	tables, ok := parent[name].([]any)
+	if parent[name] != nil && !ok {
+		return nil, fmt.Errorf("%s is not an array of tables", name)
+	}
+	table := make(map[string]any)
+	parent[name] = append(tables, table)
+	return table, nil
+}
+
+func (p *tomlParser) keyValue(table map[string]any) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if !p.consume("=") {
+		return fmt.Errorf("expected = after %s", strings.Join(keys, "."))
+	}
+	p.skipSpace(false)
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	table, err = descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	name := keys[len(keys)-1]
+	if _, ok := table[name]; ok {
+		return fmt.Errorf("%s is defined twice", strings.Join(keys, "."))
+	}
+	table[name] = value
+	return nil
+}
+
+func (p *tomlParser) value() (any, error) {
+	if p.pos >= len(p.text) {
+		return nil, fmt.Errorf("expected a value")
+	}
+	switch p.text[p.pos] {
+	case '"':
+		return p.basicString()
+	case '\'':
+		p.pos++
+		end := strings.IndexAny(p.text[p.pos:], "'\n")
+		if end < 0 || p.text[p.pos+end] != '\'' {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		s := p.text[p.pos : p.pos+end]
+		p.pos += end + 1
+		return s, nil
+	case '[':
+		p.pos++
+		var values []any
+		for {
+			p.skipSpace(true)
+			if p.consume("]") {
+				return values, nil
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			p.skipSpace(true)
+			if !p.consume(",") {
+				p.skipSpace(true)
+				if !p.consume("]") {
+					return nil, fmt.Errorf("expected , or ] in array")
+				}
+				return values, nil
+			}
+		}
+	case '{':
+		p.pos++
+		table := make(map[string]any)
+		p.skipSpace(false)
+		if p.consume("}") {
+			return table, nil
+		}
+		for {
+			if err := p.keyValue(table); err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if p.consume("}") {
+				return table, nil
+			}
+			if !p.consume(",") {
+				return nil, fmt.Errorf("expected , or } in inline table")
+			}
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.text) && !strings.ContainsRune(" \t\r\n,]}#", rune(p.text[p.pos])) {
+		p.pos++
+	}
+	word := p.text[start:p.pos]
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	clean := strings.ReplaceAll(word, "_", "")
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if digits, ok := strings.CutPrefix(clean, prefix); ok {
+			if i, err := strconv.ParseInt(digits, base, 64); err == nil && !strings.HasPrefix(digits, "-") && !strings.HasPrefix(digits, "+") {
+				return i, nil
+			}
+			return nil, fmt.Errorf("invalid value %q", word)
+		}
+	}
+	// Decimal numbers can't start with a zero, unlike their fraction
+	if digits := strings.TrimLeft(clean, "+-"); len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		return nil, fmt.Errorf("invalid value %q, numbers can't have leading zeros", word)
+	}
+	if i, err := strconv.ParseInt(clean, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", word)
+}
+
+func (p *tomlParser) basicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\n':
+			return "", fmt.Errorf("unterminated string")
+		case '\\':
+			if p.pos+1 >= len(p.text) {
+				return "", fmt.Errorf("unterminated string")
+			}
+			escape := p.text[p.pos+1]
+			p.pos += 2
+			switch escape {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(escape)
+			case 'u', 'U':
+				size := 4
+				if escape == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.text) {
+					return "", fmt.Errorf("invalid escape")
+				}
+				code, err := strconv.ParseUint(p.text[p.pos:p.pos+size], 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape")
+				}
+				b.WriteRune(rune(code))
+				p.pos += size
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", escape)
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestTOMLNumbers(t *testing.T) {
+	tests := []struct {
+		value string
+		want  any
+	}{
+		{"0", int64(0)},
+		{"-0", int64(0)},
+		{"42", int64(42)},
+		{"+42", int64(42)},
+		{"1_000", int64(1000)},
+		{"0x1f", int64(31)},
+		{"0o17", int64(15)},
+		{"0b101", int64(5)},
+		{"0.5", 0.5},
+		{"-1.5e3", -1500.0},
+		// Decimal numbers have no leading zeros, nor a base but their own
+		{"010", nil},
+		{"-07", nil},
+		{"00.5", nil},
+		{"0x-1", nil},
+		{"0b12", nil},
+	}
+	for _, test := range tests {
+		values, err := parseTOML("n = " + test.value + "\n")
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%s parsed as %v", test.value, values["n"])
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.value, err)
+		} else if values["n"] != test.want {
+			t.Errorf("%s parsed as %#v, want %#v", test.value, values["n"], test.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A full history is let grow by this fraction of its length before its
+// oldest samples are dropped
+const historySlack = 16
+
+// Drop the oldest samples of a history that outgrew its limit. They're
+// dropped in batches by moving the rest down within the same array, so a
+// full history neither grows nor leaves old arrays to the collector.
+func trimHistory[T any](history []T, limit int) []T {
+	if len(history) <= limit+limit/historySlack {
+		return history
+	}
+	n := copy(history, history[len(history)-limit:])
+	// Let go of what the dropped samples refer to, such as the stages of
+	// HTTP probes
+	clear(history[n:])
+	return history[:n]
+}
+
+// Make room for the samples up front, so the history doesn't grow while
+// probing
+func reserveHistory[T any](history []T, samples int) []T {
+	return slices.Grow(history, max(0, samples-len(history)))
+}
+
+// Make room in the histories of every stream and in the metadata of every
+// target for the samples up front
+func (m *model) reserve(samples int) {
+	for _, s := range m.namedStreams() {
+		s.latencyData = reserveHistory(s.latencyData, samples)
+		s.timestamps = reserveHistory(s.timestamps, samples)
+		if m.lossWindow > 0 {
+			s.lossData = reserveHistory(s.lossData, samples)
+		}
+		if m.stddevWindow > 0 {
+			s.stddevData = reserveHistory(s.stddevData, samples)
+		}
+	}
+	for _, t := range m.targets {
+		t.meta = reserveHistory(t.meta, min(samples, maxSampleMeta))
+	}
+}
+
+// The GC percent that GOGC sets, -1 when it turns the collector off
+func defaultGCPercent() int {
+	value := os.Getenv("GOGC")
+	if value == "off" {
+		return -1
+	}
+	if percent, err := strconv.Atoi(value); err == nil {
+		return percent
+	}
+	return 100
+}
+
+// Pin pingback to the CPUs and tune how many of them run Go code at once
+// and how often garbage is collected, for keeping up with hundreds of
+// targets at short intervals
+func tuneRuntime(cpuList string, procs, gcPercent, memoryLimit int) error {
+	if procs < 0 {
+		return fmt.Errorf("-gomaxprocs must not be negative")
+	}
+	if memoryLimit < 0 {
+		return fmt.Errorf("-memory-limit must not be negative")
+	}
+	if cpuList != "" {
+		cpus, err := parseCPUList(cpuList)
+		if err != nil {
+			return err
+		}
+		if err := setAffinity(cpus); err != nil {
+			return fmt.Errorf("pinning to CPUs %s: %w", cpuList, err)
+		}
+		// Go only counts the CPUs it may run on at startup
+		if procs == 0 {
+			procs = len(cpus)
+		}
+	}
+	if procs > 0 {
+		runtime.GOMAXPROCS(procs)
+	}
+	debug.SetGCPercent(gcPercent)
+	if memoryLimit > 0 {
+		debug.SetMemoryLimit(int64(memoryLimit) << 20)
+	}
+	return nil
+}
+
+// Parse a list of CPUs like the kernel writes them, such as 0-3,8
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(list, ",") {
+		low, high, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.Atoi(low)
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(high)
+		}
+		if err != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("CPU list %q is not of the form 0-3,8", list)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	wireguardCheckInterval = 5 * time.Second
+	// WireGuard renews the handshake every two minutes while there is traffic,
+	// and drops the session after three without one
+	wireguardStallAge = 3 * time.Minute
+)
+
+type wireguardPeer struct {
+	key       string
+	endpoint  string
+	address   string
+	handshake time.Time
+	stalled   bool
+}
+
+// Name the peer by the address it is pinged at, or by its key when it isn't
+// pinged
+func (peer *wireguardPeer) name() string {
+	if peer.address == "" {
+		return peer.key[:8] + "…"
+	}
+	return peer.address
+}
+
+type wireguardMsg struct {
+	iface string
+	peers []*wireguardPeer
+	err   error
+}
+
+// Read the peers of a WireGuard interface with `wg show <interface> dump`.
+// The address of a peer is the first of its allowed IPs that is a single
+// host, which is pinged across the tunnel. Routes such as 0.0.0.0/0 or a
+// subnet behind the peer don't tell where the peer itself is, so a peer with
+// only those isn't pinged.
+func wireguardPeers(ctx context.Context, iface string) ([]*wireguardPeer, error) {
+	output, err := exec.CommandContext(ctx, "wg", "show", iface, "dump").Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("wg show %s: %s", iface, strings.TrimSpace(string(exit.Stderr)))
+		}
+		return nil, fmt.Errorf("wg show %s: %w", iface, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var peers []*wireguardPeer
+	// The first line describes the interface itself
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			continue
+		}
+		peer := &wireguardPeer{key: fields[0], endpoint: fields[2]}
+		for _, allowed := range strings.Split(fields[3], ",") {
+			if prefix, err := netip.ParsePrefix(allowed); err == nil && prefix.IsSingleIP() {
+				peer.address = prefix.Addr().String()
+				break
+			}
+		}
+		if seconds, _ := strconv.ParseInt(fields[4], 10, 64); seconds > 0 {
+			peer.handshake = time.Unix(seconds, 0)
+		}
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
+func (m *model) checkWireguardCmd(ctx context.Context, iface string, delay time.Duration) tea.Cmd {
+	return m.clock.Tick(delay, func(time.Time) tea.Msg {
+		peers, err := wireguardPeers(ctx, iface)
+		return wireguardMsg{iface, peers, err}
+	})
+}
+
+// Track the handshakes of the peers, flagging peers whose handshake is so old
+// that the tunnel is up but dead
+func (m *model) trackWireguard(msg wireguardMsg, now time.Time) tea.Cmd {
+	if msg.err != nil {
+		m.status = msg.err.Error()
+		return nil
+	}
+	known := make(map[string]*wireguardPeer)
+	for _, peer := range m.wireguard[msg.iface] {
+		known[peer.key] = peer
+	}
+	var cmds []tea.Cmd
+	for _, peer := range msg.peers {
+		if previous, ok := known[peer.key]; ok {
+			peer.stalled = previous.stalled
+		}
+		stalled := now.Sub(peer.handshake) > wireguardStallAge
+		for _, t := range m.targets {
+			if t.address != peer.address {
+				continue
+			}
+			if stalled {
+				m.raiseBanner(t, "wireguard", "No handshake", msg.iface+" peer "+peer.endpoint, peer.handshake)
+			} else {
+				m.resolveBanner(t, "wireguard", now)
+			}
+		}
+		if stalled == peer.stalled {
+			continue
+		}
+		peer.stalled = stalled
+		event := "wireguard_resumed"
+		label := fmt.Sprintf("%s %s handshake", msg.iface, peer.name())
+		if stalled {
+			event = "wireguard_stall"
+			label = fmt.Sprintf("%s %s stalled", msg.iface, peer.name())
+		}
+		m.addEvent(wireguardEvent, peer.address, label, now)
+		cmds = append(cmds, m.runAlert(append(os.Environ(),
+			"PINGBACK_EVENT="+event,
+			"PINGBACK_TARGETS="+peer.address,
+			"PINGBACK_INTERFACE="+msg.iface,
+			"PINGBACK_PEER="+peer.key,
+		)))
+	}
+	m.wireguard[msg.iface] = msg.peers
+	return tea.Batch(append(cmds, m.checkWireguardCmd(m.ctx, msg.iface, wireguardCheckInterval))...)
+}
+
+func (m *model) renderWireguard(now time.Time) string {
+	var lines []string
+	for _, iface := range m.wireguardInterfaces {
+		lines = append(lines, fmt.Sprintf("WireGuard %s:", iface))
+		for _, peer := range m.wireguard[iface] {
+			age := "never"
+			if !peer.handshake.IsZero() {
+				age = now.Sub(peer.handshake).Round(time.Second).String() + " ago"
+			}
+			state := ""
+			if peer.stalled {
+				state = lipgloss.NewStyle().Foreground(lipgloss.Color("#d23105")).Render("  stalled")
+			}
+			address := peer.address
+			if address == "" {
+				address = "not pinged"
+			}
+			lines = append(lines, fmt.Sprintf("  %s…  %-15s  %-21s  handshake %s%s",
+				peer.key[:8], address, peer.endpoint, age, state))
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}